@@ -0,0 +1,207 @@
+// Package calendars: named sets of times (holidays, maintenance
+// windows) which suppress or allow scheduled runs of jobs
+package calendars
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// NOTE: A calendar contains a time if it is on one of its dates,
+// in one of its weekly ranges or in one of its periods. Dates and
+// weekly ranges are in local time of the program
+
+const (
+	DateLayout = time.DateOnly
+	// Times of weekly ranges, "24:00" is the end of the day
+	ClockLayout = "15:04"
+)
+
+var nameRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+type Calendar struct {
+	Description string `json:"description,omitempty"`
+	// Whole days "2006-01-02"
+	Dates []string `json:"dates,omitempty"`
+	// Ranges of time on days of the week
+	Weekly []WeeklyRange `json:"weekly,omitempty"`
+	// Periods with exact bounds (e.g. events of .ics files)
+	Periods []Period `json:"periods,omitempty"`
+}
+
+// WeeklyRange is a range of time on the days (sun, mon, ..., all
+// days if empty). If From is after To, the range ends on the next day
+
+type WeeklyRange struct {
+	Days []string `json:"days,omitempty"`
+	From string   `json:"from"`
+	To   string   `json:"to"`
+}
+
+// Period is [Start, End)
+
+type Period struct {
+	Summary string    `json:"summary,omitempty"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+}
+
+// ValidateName checks the name of a calendar
+
+func ValidateName(name string) error {
+	if !nameRegex.MatchString(name) {
+		return fmt.Errorf("invalid calendar name %q", name)
+	}
+	return nil
+}
+
+func (c *Calendar) Validate() error {
+	for _, d := range c.Dates {
+		if _, err := time.Parse(DateLayout, d); err != nil {
+			return fmt.Errorf("invalid date %q, expected YYYY-MM-DD", d)
+		}
+	}
+
+	for _, w := range c.Weekly {
+		for _, d := range w.Days {
+			if _, exists := weekdays[strings.ToLower(d)]; !exists {
+				return fmt.Errorf("invalid day of week %q, expected sun, mon, ..., sat", d)
+			}
+		}
+		from, err := parseClock(w.From)
+		if err != nil {
+			return err
+		}
+		to, err := parseClock(w.To)
+		if err != nil {
+			return err
+		}
+		if from == to {
+			return fmt.Errorf("weekly range %s-%s is empty", w.From, w.To)
+		}
+	}
+
+	for _, p := range c.Periods {
+		if !p.End.After(p.Start) {
+			return fmt.Errorf("period %s - %s ends before it starts", p.Start, p.End)
+		}
+	}
+
+	if len(c.Dates) == 0 && len(c.Weekly) == 0 && len(c.Periods) == 0 {
+		return errors.New("calendar is empty")
+	}
+	return nil
+}
+
+// Contains reports whether t is in the calendar
+
+func (c *Calendar) Contains(t time.Time) bool {
+	t = t.Local()
+
+	if slices.Contains(c.Dates, t.Format(DateLayout)) {
+		return true
+	}
+
+	for _, w := range c.Weekly {
+		if w.contains(t) {
+			return true
+		}
+	}
+
+	for _, p := range c.Periods {
+		if !t.Before(p.Start) && t.Before(p.End) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w WeeklyRange) contains(t time.Time) bool {
+	from, err := parseClock(w.From)
+	if err != nil {
+		return false
+	}
+	to, err := parseClock(w.To)
+	if err != nil {
+		return false
+	}
+
+	clock := time.Duration(t.Hour())*time.Hour +
+		time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+
+	if from < to {
+		return w.onDay(t.Weekday()) && clock >= from && clock < to
+	}
+	// The range crosses midnight: the evening of its day
+	// or the morning of the next day
+	if clock >= from {
+		return w.onDay(t.Weekday())
+	}
+	return clock < to && w.onDay((t.Weekday()+6)%7)
+}
+
+func (w WeeklyRange) onDay(d time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, name := range w.Days {
+		if weekdays[strings.ToLower(name)] == d {
+			return true
+		}
+	}
+	return false
+}
+
+// parseClock returns the time of the day "15:04"
+
+func parseClock(s string) (time.Duration, error) {
+	if s == "24:00" {
+		return 24 * time.Hour, nil
+	}
+	t, err := time.Parse(ClockLayout, s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// ParseWeeklyRange parses "sat,sun 00:00-24:00" or "22:00-06:00"
+// (every day)
+
+func ParseWeeklyRange(s string) (WeeklyRange, error) {
+	var w WeeklyRange
+
+	fields := strings.Fields(s)
+	switch len(fields) {
+	case 1:
+	case 2:
+		w.Days = strings.Split(fields[0], ",")
+		fields = fields[1:]
+	default:
+		return w, fmt.Errorf("invalid weekly range %q, expected [DAYS] HH:MM-HH:MM", s)
+	}
+
+	from, to, found := strings.Cut(fields[0], "-")
+	if !found {
+		return w, fmt.Errorf("invalid weekly range %q, expected [DAYS] HH:MM-HH:MM", s)
+	}
+	w.From, w.To = from, to
+
+	c := Calendar{Weekly: []WeeklyRange{w}}
+	return w, c.Validate()
+}
@@ -0,0 +1,171 @@
+package calendars
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalendarContains(t *testing.T) {
+	c := &Calendar{
+		Dates: []string{"2026-12-25"},
+		Weekly: []WeeklyRange{
+			{Days: []string{"sat", "sun"}, From: "00:00", To: "24:00"},
+			// The range crosses midnight
+			{Days: []string{"wed"}, From: "22:00", To: "02:00"},
+		},
+		Periods: []Period{{
+			Start: time.Date(2026, 3, 2, 10, 0, 0, 0, time.Local),
+			End:   time.Date(2026, 3, 2, 12, 0, 0, 0, time.Local),
+		}},
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+
+	at := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2026, month, day, hour, min, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		name string
+		t    time.Time
+		want bool
+	}{
+		{"date", at(12, 25, 15, 0), true},
+		{"day before the date", at(12, 24, 23, 59), false},
+		{"saturday", at(3, 7, 9, 0), true},
+		{"monday", at(3, 9, 9, 0), false},
+		{"wednesday night", at(3, 4, 23, 0), true},
+		{"thursday morning", at(3, 5, 1, 30), true},
+		{"thursday after the range", at(3, 5, 2, 0), false},
+		{"tuesday morning", at(3, 3, 1, 0), false},
+		{"period", at(3, 2, 11, 0), true},
+		{"end of the period", at(3, 2, 12, 0), false},
+	}
+	for _, tt := range tests {
+		if got := c.Contains(tt.t); got != tt.want {
+			t.Errorf("%s: Contains(%s) = %v, want %v", tt.name, tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestCalendarValidate(t *testing.T) {
+	for _, c := range []*Calendar{
+		{},
+		{Dates: []string{"25.12.2026"}},
+		{Weekly: []WeeklyRange{{Days: []string{"funday"}, From: "00:00", To: "01:00"}}},
+		{Weekly: []WeeklyRange{{From: "25:00", To: "01:00"}}},
+		{Weekly: []WeeklyRange{{From: "01:00", To: "01:00"}}},
+	} {
+		if err := c.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded, want an error", c)
+		}
+	}
+}
+
+func TestParseWeeklyRange(t *testing.T) {
+	w, err := ParseWeeklyRange("sat,sun 00:00-24:00")
+	if err != nil || len(w.Days) != 2 || w.From != "00:00" || w.To != "24:00" {
+		t.Errorf("ParseWeeklyRange = %+v, %v", w, err)
+	}
+	if w, err := ParseWeeklyRange("22:00-06:00"); err != nil || len(w.Days) != 0 {
+		t.Errorf("ParseWeeklyRange of every day = %+v, %v", w, err)
+	}
+
+	for _, s := range []string{"", "sat", "sat 22:00", "sat sun 01:00-02:00", "fri 1:00-25:00"} {
+		if _, err := ParseWeeklyRange(s); err == nil {
+			t.Errorf("ParseWeeklyRange(%q) succeeded, want an error", s)
+		}
+	}
+}
+
+const testICS = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Christmas\r\n" +
+	"DTSTART;VALUE=DATE:20261225\r\n" +
+	"DTEND;VALUE=DATE:20261227\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:New Year\r\n" +
+	"DTSTART;VALUE=DATE:20250101\r\n" +
+	"RRULE:FREQ=YEARLY;COUNT=3\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Database\r\n" +
+	"  migration\r\n" +
+	"DTSTART:20260302T100000Z\r\n" +
+	"DURATION:PT2H\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Standup\r\n" +
+	"DTSTART;TZID=Europe/Berlin:20260302T093000\r\n" +
+	"DTEND;TZID=Europe/Berlin:20260302T094500\r\n" +
+	"RRULE:FREQ=DAILY\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseICS(t *testing.T) {
+	c, skipped, err := ParseICS(strings.NewReader(testICS))
+	if err != nil {
+		t.Fatalf("ParseICS failed: %v", err)
+	}
+	if skipped != 1 {
+		t.Errorf("Skipped %d events, want 1", skipped)
+	}
+
+	wantDates := []string{"2025-01-01", "2026-01-01", "2026-12-25", "2026-12-26", "2027-01-01"}
+	if strings.Join(c.Dates, ",") != strings.Join(wantDates, ",") {
+		t.Errorf("Dates = %v, want %v", c.Dates, wantDates)
+	}
+
+	if len(c.Periods) != 1 {
+		t.Fatalf("Periods = %v, want 1", c.Periods)
+	}
+	p := c.Periods[0]
+	if p.Summary != "Database migration" ||
+		!p.Start.Equal(time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)) ||
+		p.End.Sub(p.Start) != 2*time.Hour {
+		t.Errorf("Period = %+v", p)
+	}
+
+	if _, _, err := ParseICS(strings.NewReader("BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n")); err == nil {
+		t.Error("ParseICS without events succeeded, want an error")
+	}
+}
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calendars.json")
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open of a missing file failed: %v", err)
+	}
+	if err := s.Set("bad name", &Calendar{Dates: []string{"2026-01-01"}}); err == nil {
+		t.Error("Set with an invalid name succeeded")
+	}
+	if err := s.Set("holidays", &Calendar{Dates: []string{"2026-01-01"}}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	s, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	in, err := s.Contains("holidays", time.Date(2026, 1, 1, 12, 0, 0, 0, time.Local))
+	if err != nil || !in {
+		t.Errorf("Contains = %v, %v, want true", in, err)
+	}
+	if _, err := s.Contains("missing", time.Now()); err != ErrCalendarNotFound {
+		t.Errorf("Contains of a missing calendar = %v, want ErrCalendarNotFound", err)
+	}
+
+	if err := s.Delete("holidays"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if list := s.List(); len(list) != 0 {
+		t.Errorf("List after Delete = %v", list)
+	}
+}
@@ -0,0 +1,245 @@
+package calendars
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NOTE: Import of iCalendar (.ics) files. All-day events become
+// dates, other events become periods. Yearly recurring events (e.g.
+// holidays) are expanded for icsYears years, events with other
+// recurrence rules are skipped
+
+const icsYears = 10
+
+const (
+	icsDateLayout     = "20060102"
+	icsDateTimeLayout = "20060102T150405"
+)
+
+type icsProperty struct {
+	params map[string]string
+	value  string
+}
+
+type icsEvent map[string]icsProperty
+
+// ParseICS reads events of the .ics file to a calendar, it returns
+// the number of skipped events (with unsupported recurrences)
+
+func ParseICS(r io.Reader) (*Calendar, int, error) {
+	lines, err := unfoldICS(r)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var events []icsEvent
+	var event icsEvent
+	for _, line := range lines {
+		name, prop, ok := parseICSLine(line)
+		if !ok {
+			continue
+		}
+		switch {
+		case name == "BEGIN" && strings.EqualFold(prop.value, "VEVENT"):
+			event = icsEvent{}
+		case name == "END" && strings.EqualFold(prop.value, "VEVENT"):
+			if event != nil {
+				events = append(events, event)
+			}
+			event = nil
+		case event != nil:
+			event[name] = prop
+		}
+	}
+
+	if len(events) == 0 {
+		return nil, 0, errors.New("no events in the calendar file")
+	}
+
+	c := &Calendar{}
+	skipped := 0
+	for _, e := range events {
+		ok, err := c.addICSEvent(e)
+		if err != nil {
+			return nil, 0, err
+		}
+		if !ok {
+			skipped++
+		}
+	}
+
+	slices.Sort(c.Dates)
+	c.Dates = slices.Compact(c.Dates)
+	return c, skipped, nil
+}
+
+func (c *Calendar) addICSEvent(e icsEvent) (bool, error) {
+	start, allDay, err := parseICSTime(e["DTSTART"])
+	if err != nil {
+		return false, fmt.Errorf("event %q: DTSTART: %w", e["SUMMARY"].value, err)
+	}
+
+	var end time.Time
+	switch {
+	case e["DTEND"].value != "":
+		if end, _, err = parseICSTime(e["DTEND"]); err != nil {
+			return false, fmt.Errorf("event %q: DTEND: %w", e["SUMMARY"].value, err)
+		}
+	case e["DURATION"].value != "":
+		d, err := parseICSDuration(e["DURATION"].value)
+		if err != nil {
+			return false, fmt.Errorf("event %q: DURATION: %w", e["SUMMARY"].value, err)
+		}
+		end = start.Add(d)
+	case allDay:
+		end = start.AddDate(0, 0, 1)
+	default:
+		// An instant, nothing to suppress
+		return false, nil
+	}
+	if !end.After(start) {
+		return false, nil
+	}
+
+	years := 1
+	if rule := e["RRULE"].value; rule != "" {
+		if years = yearlyOccurrences(rule, start); years == 0 {
+			return false, nil
+		}
+	}
+
+	summary := e["SUMMARY"].value
+	for y := range years {
+		from, to := start.AddDate(y, 0, 0), end.AddDate(y, 0, 0)
+		if allDay {
+			for d := from; d.Before(to); d = d.AddDate(0, 0, 1) {
+				c.Dates = append(c.Dates, d.Format(DateLayout))
+			}
+			continue
+		}
+		c.Periods = append(c.Periods, Period{Summary: summary, Start: from, End: to})
+	}
+	return true, nil
+}
+
+// yearlyOccurrences returns the number of occurrences of the yearly
+// rule (COUNT, UNTIL or icsYears from now), 0 if the rule is not
+// a plain yearly one
+
+func yearlyOccurrences(rule string, start time.Time) int {
+	parts := map[string]string{}
+	for _, part := range strings.Split(rule, ";") {
+		k, v, _ := strings.Cut(part, "=")
+		parts[strings.ToUpper(k)] = v
+	}
+
+	if !strings.EqualFold(parts["FREQ"], "YEARLY") {
+		return 0
+	}
+	for k, v := range parts {
+		switch k {
+		case "FREQ", "COUNT", "UNTIL":
+		case "INTERVAL":
+			if v != "1" {
+				return 0
+			}
+		default:
+			return 0
+		}
+	}
+
+	if count, err := strconv.Atoi(parts["COUNT"]); err == nil && count > 0 {
+		return count
+	}
+
+	last := time.Now().Year() + icsYears
+	if until := parts["UNTIL"]; len(until) >= 8 {
+		if t, err := time.Parse(icsDateLayout, until[:8]); err == nil {
+			last = t.Year()
+		}
+	}
+	return max(last-start.Year()+1, 0)
+}
+
+func parseICSTime(p icsProperty) (time.Time, bool, error) {
+	v := p.value
+	if p.params["VALUE"] == "DATE" || len(v) == len(icsDateLayout) {
+		t, err := time.ParseInLocation(icsDateLayout, v, time.Local)
+		return t, true, err
+	}
+
+	if strings.HasSuffix(v, "Z") {
+		t, err := time.ParseInLocation(icsDateTimeLayout, strings.TrimSuffix(v, "Z"), time.UTC)
+		return t, false, err
+	}
+
+	loc := time.Local
+	if tzid := p.params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation(icsDateTimeLayout, v, loc)
+	return t, false, err
+}
+
+var icsDurationRegex = regexp.MustCompile(`^P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+func parseICSDuration(s string) (time.Duration, error) {
+	m := icsDurationRegex.FindStringSubmatch(strings.TrimPrefix(s, "+"))
+	if m == nil {
+		return 0, fmt.Errorf("unsupported duration %q", s)
+	}
+
+	var d time.Duration
+	for i, unit := range []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second} {
+		if m[i+1] != "" {
+			n, _ := strconv.Atoi(m[i+1])
+			d += time.Duration(n) * unit
+		}
+	}
+	return d, nil
+}
+
+// parseICSLine splits "NAME;PARAM=value:VALUE"
+
+func parseICSLine(line string) (string, icsProperty, bool) {
+	head, value, found := strings.Cut(line, ":")
+	if !found {
+		return "", icsProperty{}, false
+	}
+
+	fields := strings.Split(head, ";")
+	prop := icsProperty{params: map[string]string{}, value: value}
+	for _, param := range fields[1:] {
+		k, v, _ := strings.Cut(param, "=")
+		prop.params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+	}
+	return strings.ToUpper(fields[0]), prop, true
+}
+
+// unfoldICS joins continuation lines (starting with
+// a space or a tab) with the previous line
+
+func unfoldICS(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
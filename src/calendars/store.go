@@ -0,0 +1,153 @@
+package calendars
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrCalendarNotFound = errors.New("calendar not found")
+
+type Store struct {
+	mu        sync.RWMutex
+	path      string
+	calendars map[string]*Calendar
+}
+
+// Open loads the calendars file, a missing file is an empty store
+
+func Open(path string) (*Store, error) {
+	s := &Store{
+		path:      path,
+		calendars: map[string]*Calendar{},
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &s.calendars); err != nil {
+		return nil, fmt.Errorf("calendars file %s: %w", path, err)
+	}
+	for name, c := range s.calendars {
+		if err := c.Validate(); err != nil {
+			return nil, fmt.Errorf("calendar %s: %w", name, err)
+		}
+	}
+
+	return s, nil
+}
+
+// Set creates or replaces the calendar and saves the file
+
+func (s *Store) Set(name string, c *Calendar) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, existed := s.calendars[name]
+	s.calendars[name] = c
+	if err := s.save(); err != nil {
+		if existed {
+			s.calendars[name] = prev
+		} else {
+			delete(s.calendars, name)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *Store) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, exists := s.calendars[name]
+	if !exists {
+		return ErrCalendarNotFound
+	}
+	delete(s.calendars, name)
+	if err := s.save(); err != nil {
+		s.calendars[name] = prev
+		return err
+	}
+	return nil
+}
+
+// Contains reports whether t is in the calendar
+
+func (s *Store) Contains(name string, t time.Time) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, exists := s.calendars[name]
+	if !exists {
+		return false, ErrCalendarNotFound
+	}
+	return c.Contains(t), nil
+}
+
+// Exists reports whether the calendar is in the store
+
+func (s *Store) Exists(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, exists := s.calendars[name]
+	return exists
+}
+
+// Info is a calendar with its name and whether it contains
+// the current time
+
+type Info struct {
+	Name string `json:"name"`
+	*Calendar
+	Active bool `json:"active"`
+}
+
+// List returns calendars sorted by name
+
+func (s *Store) List() []Info {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	infos := make([]Info, 0, len(s.calendars))
+	for name, c := range s.calendars {
+		infos = append(infos, Info{Name: name, Calendar: c, Active: c.Contains(now)})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
+// WARN: BEFORE CALLING THIS, PLS THINK ABOUT TAKE STORE MUTEX
+
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.calendars, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, s.path)
+}
@@ -0,0 +1,377 @@
+// Package client: client of the web API of the running program
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"cronshroom/calendars"
+	"cronshroom/extjob"
+	"cronshroom/storage"
+)
+
+const requestTimeout = 30 * time.Second
+
+type Client struct {
+	baseURL string
+	http    *http.Client
+}
+
+// New returns the client of the program listening on baseURL
+// (e.g. http://localhost:3777) or on the Unix socket if it is set
+
+func New(baseURL, socket string) *Client {
+	c := &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		http:    &http.Client{Timeout: requestTimeout},
+	}
+
+	if socket != "" {
+		c.baseURL = "http://cronshroom"
+		c.http.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		}
+	}
+
+	return c
+}
+
+// APIError is a response with an error status
+
+type APIError struct {
+	Status  int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return http.StatusText(e.Status)
+	}
+	return e.Message
+}
+
+// NOTE: Jobs
+
+// JobRequest is a job for /api/change_job
+
+type JobRequest struct {
+	Name          string `json:"name"`
+	Description   string `json:"description"`
+	Command       string `json:"command"`
+	Cron          string `json:"cron"`
+	Timeout       uint   `json:"timeout"`
+	MaxRetries    uint   `json:"maxRetries"`
+	RetryInterval uint   `json:"retryInterval"`
+	// One VAR=value per line
+	Env string `json:"env"`
+	// Empty - the user of the program
+	User  string `json:"user,omitempty"`
+	Group string `json:"group,omitempty"`
+	// nil - no limits
+	Limits *storage.JobLimits `json:"limits,omitempty"`
+	// nil - retries of any failure every RetryInterval seconds
+	Retry *storage.RetryConfig `json:"retry,omitempty"`
+	// ignore (default), run_once or run_all
+	MisfirePolicy  string `json:"misfirePolicy,omitempty"`
+	MisfireMaxRuns uint   `json:"misfireMaxRuns,omitempty"`
+	// Seconds of the window of the stable offset and of the random delay
+	StartSpread uint `json:"startSpread,omitempty"`
+	StartJitter uint `json:"startJitter,omitempty"`
+	// Concurrency pool, empty - only the global limit
+	Pool         string `json:"pool,omitempty"`
+	Priority     int    `json:"priority,omitempty"`
+	QueueMaxWait uint   `json:"queueMaxWait,omitempty"`
+	// Names of calendars
+	ExcludeCalendars []string `json:"excludeCalendars,omitempty"`
+	OnlyCalendars    []string `json:"onlyCalendars,omitempty"`
+}
+
+func (c *Client) Jobs() (storage.Jobs, error) {
+	var db storage.Database
+	if err := c.get("/api/get_database", nil, &db); err != nil {
+		return nil, err
+	}
+	return db.Jobs, nil
+}
+
+func (c *Client) Job(name string) (*storage.Job, error) {
+	var j storage.Job
+	err := c.get("/api/get_job", url.Values{"name": {name}}, &j)
+	if err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+func (c *Client) SetJob(req JobRequest) error {
+	return c.post("/api/change_job", req, nil)
+}
+
+func (c *Client) DeleteJob(name string) error {
+	return c.post("/api/delete_job", map[string]string{"name": name}, nil)
+}
+
+func (c *Client) ToggleJob(name string) error {
+	return c.post("/api/toggle_job", map[string]string{"name": name}, nil)
+}
+
+// RunJob starts the job out of schedule, returns the id of the run
+
+func (c *Client) RunJob(name string) (uint64, error) {
+	var resp struct {
+		RunID uint64 `json:"run_id"`
+	}
+	err := c.post("/api/exec_job", map[string]string{"name": name}, &resp)
+	return resp.RunID, err
+}
+
+// JobLog returns the last lines of the log file of the job (the
+// whole file if lines is 0) and the size of the file
+
+func (c *Client) JobLog(name string, lines uint) ([]byte, int64, error) {
+	query := url.Values{"name": {name}}
+	if lines > 0 {
+		query.Set("lines", strconv.FormatUint(uint64(lines), 10))
+	}
+	return c.getText("/api/job_log", query)
+}
+
+// JobLogFrom returns the log file of the job from the offset,
+// the size of the file is the offset of the next request
+
+func (c *Client) JobLogFrom(name string, offset int64) ([]byte, int64, error) {
+	return c.getText("/api/job_log", url.Values{
+		"name":   {name},
+		"offset": {strconv.FormatInt(offset, 10)},
+	})
+}
+
+// NOTE: Runs
+
+func (c *Client) Runs(job string) ([]storage.Run, error) {
+	var query url.Values
+	if job != "" {
+		query = url.Values{"job": {job}}
+	}
+
+	var runs []storage.Run
+	err := c.get("/api/list_runs", query, &runs)
+	return runs, err
+}
+
+func (c *Client) Run(id uint64) (storage.Run, error) {
+	var run storage.Run
+	err := c.get(
+		"/api/get_run",
+		url.Values{"id": {strconv.FormatUint(id, 10)}},
+		&run,
+	)
+	return run, err
+}
+
+func (c *Client) CancelRun(id uint64) error {
+	return c.post("/api/cancel_run", map[string]uint64{"id": id}, nil)
+}
+
+// Queue returns the state of concurrency limits
+
+func (c *Client) Queue() (extjob.QueueState, error) {
+	var queue extjob.QueueState
+	err := c.get("/api/get_queue", nil, &queue)
+	return queue, err
+}
+
+// NOTE: Pause
+
+// PauseRequest is a pause for /api/pause, until (RFC 3339) and
+// duration (seconds) are empty - until it is resumed
+
+type PauseRequest struct {
+	Until         string `json:"until,omitempty"`
+	Duration      uint   `json:"duration,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+	CancelRunning bool   `json:"cancelRunning,omitempty"`
+}
+
+// Pause pauses the scheduler, returns the number of canceled runs
+
+func (c *Client) Pause(req PauseRequest) (int, error) {
+	var resp struct {
+		Canceled int `json:"canceled"`
+	}
+	err := c.post("/api/pause", req, &resp)
+	return resp.Canceled, err
+}
+
+func (c *Client) Resume() error {
+	return c.post("/api/resume", struct{}{}, nil)
+}
+
+func (c *Client) PauseState() (storage.PauseState, error) {
+	var state storage.PauseState
+	err := c.get("/api/get_pause", nil, &state)
+	return state, err
+}
+
+// NOTE: Calendars
+
+func (c *Client) Calendars() ([]calendars.Info, error) {
+	var infos []calendars.Info
+	err := c.get("/api/list_calendars", nil, &infos)
+	return infos, err
+}
+
+func (c *Client) SetCalendar(name string, calendar *calendars.Calendar) error {
+	return c.post("/api/set_calendar", map[string]any{"name": name, "calendar": calendar}, nil)
+}
+
+// ImportResult is the number of dates and periods of the imported
+// calendar and the number of skipped events
+
+type ImportResult struct {
+	Dates   int `json:"dates"`
+	Periods int `json:"periods"`
+	Skipped int `json:"skipped"`
+}
+
+// ImportCalendar creates or replaces the calendar with events of the .ics file
+
+func (c *Client) ImportCalendar(name, description string, ics []byte) (ImportResult, error) {
+	var result ImportResult
+	query := url.Values{"name": {name}, "description": {description}}
+	resp, err := c.http.Post(
+		c.baseURL+"/api/import_calendar?"+query.Encode(),
+		"text/calendar",
+		bytes.NewReader(ics),
+	)
+	if err != nil {
+		return result, err
+	}
+	return result, decodeResponse(resp, &result)
+}
+
+func (c *Client) DeleteCalendar(name string) error {
+	return c.post("/api/delete_calendar", map[string]string{"name": name}, nil)
+}
+
+// NOTE: Log
+
+type LogEntry struct {
+	// Set only for entries of the log store
+	ID      uint64         `json:"id,omitempty"`
+	Time    string         `json:"time"`
+	Level   string         `json:"level"`
+	Message string         `json:"message"`
+	Attrs   map[string]any `json:"attrs,omitempty"`
+}
+
+// LastLog returns the last log entries kept by the program
+
+func (c *Client) LastLog() ([]LogEntry, error) {
+	var entries []LogEntry
+	err := c.get("/api/last_log", nil, &entries)
+	return entries, err
+}
+
+// LogPage is a page of the log store search from the newest entry
+
+type LogPage struct {
+	Entries []LogEntry `json:"entries"`
+	// Cursor of the next (older) page, 0 if there are no more entries
+	NextCursor uint64 `json:"next_cursor,omitempty"`
+}
+
+// SearchLogs searches the log store, parameters are the ones of /api/logs
+
+func (c *Client) SearchLogs(params url.Values) (*LogPage, error) {
+	var page LogPage
+	if err := c.get("/api/logs", params, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// NOTE: Requests
+
+func (c *Client) get(path string, query url.Values, out any) error {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	resp, err := c.http.Get(u)
+	if err != nil {
+		return err
+	}
+	return decodeResponse(resp, out)
+}
+
+// getText returns the text response and its X-Log-Size header
+
+func (c *Client) getText(path string, query url.Values) ([]byte, int64, error) {
+	resp, err := c.http.Get(c.baseURL + path + "?" + query.Encode())
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, 0, decodeResponse(resp, nil)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	size, _ := strconv.ParseInt(resp.Header.Get("X-Log-Size"), 10, 64)
+	return data, size, nil
+}
+
+func (c *Client) post(path string, body any, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.http.Post(
+		c.baseURL+path,
+		"application/json",
+		bytes.NewReader(data),
+	)
+	if err != nil {
+		return err
+	}
+	return decodeResponse(resp, out)
+}
+
+func decodeResponse(resp *http.Response, out any) error {
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &APIError{
+			Status:  resp.StatusCode,
+			Message: strings.TrimSpace(string(message)),
+		}
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid response of %s: %w", resp.Request.URL.Path, err)
+	}
+	return nil
+}
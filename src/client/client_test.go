@@ -0,0 +1,208 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cronshroom/gui"
+	"cronshroom/storage"
+	"cronshroom/utils"
+)
+
+// newTestServer serves the web API of the database
+
+func newTestServer(t *testing.T) (*storage.Database, http.Handler) {
+	t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := storage.New()
+	j, err := storage.ShellJob("test job", "echo ok", "0 0 * * * *", 0, 0, 0)
+	if err != nil {
+		t.Fatalf("ShellJob failed: %v", err)
+	}
+	db.SetJob(j, "job")
+
+	srv := gui.CreateWebServer(
+		"0",
+		logger,
+		logger,
+		db,
+		nil,
+		utils.NewHealth(time.Second, 3),
+		nil,
+		context.Background(),
+	)
+	return db, srv.Handler
+}
+
+func waitRun(t *testing.T, c *Client, id uint64) storage.Run {
+	t.Helper()
+
+	for range 100 {
+		run, err := c.Run(id)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if run.Status != storage.RunRunning {
+			return run
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	t.Fatalf("Run %d is not finished", id)
+	return storage.Run{}
+}
+
+func TestClient(t *testing.T) {
+	_, handler := newTestServer(t)
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	c := New(ts.URL+"/", "")
+
+	jobs, err := c.Jobs()
+	if err != nil {
+		t.Fatalf("Jobs failed: %v", err)
+	}
+	if len(jobs) != 1 || jobs["job"] == nil {
+		t.Fatalf("Unexpected jobs: %v", jobs)
+	}
+
+	j, err := c.Job("job")
+	if err != nil {
+		t.Fatalf("Job failed: %v", err)
+	}
+	if j.Description != "test job" || j.Config.Command != "echo ok" {
+		t.Errorf("Unexpected job: %+v", j)
+	}
+
+	id, err := c.RunJob("job")
+	if err != nil {
+		t.Fatalf("RunJob failed: %v", err)
+	}
+	if run := waitRun(t, c, id); run.Status != storage.RunOK || run.Job != "job" {
+		t.Errorf("Unexpected run: %+v", run)
+	}
+	runs, err := c.Runs("job")
+	if err != nil {
+		t.Fatalf("Runs failed: %v", err)
+	}
+	if len(runs) != 1 || runs[0].ID != id {
+		t.Errorf("Unexpected runs: %+v", runs)
+	}
+
+	if _, err := c.Pause(PauseRequest{Reason: "upgrade"}); err != nil {
+		t.Fatalf("Pause failed: %v", err)
+	}
+	state, err := c.PauseState()
+	if err != nil {
+		t.Fatalf("PauseState failed: %v", err)
+	}
+	if !state.Paused || state.Reason != "upgrade" {
+		t.Errorf("Unexpected pause state: %+v", state)
+	}
+	if err := c.Resume(); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	if state, err := c.PauseState(); err != nil || state.Paused {
+		t.Errorf("Scheduler is not resumed: %+v, %v", state, err)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	_, handler := newTestServer(t)
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	c := New(ts.URL, "")
+
+	tests := []struct {
+		name   string
+		call   func() error
+		status int
+	}{
+		{
+			name:   "missing job",
+			call:   func() error { _, err := c.Job("missing"); return err },
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "run of a missing job",
+			call:   func() error { _, err := c.RunJob("missing"); return err },
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "missing run",
+			call:   func() error { _, err := c.Run(1000); return err },
+			status: http.StatusNotFound,
+		},
+		{
+			name: "invalid job",
+			call: func() error {
+				return c.SetJob(JobRequest{Name: "bad", Command: "echo", Cron: "* * *"})
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "pause in the past",
+			call:   func() error { _, err := c.Pause(PauseRequest{Until: "2001-01-01T00:00:00Z"}); return err },
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "resume of the running scheduler",
+			call:   c.Resume,
+			status: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		err := tt.call()
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			t.Fatalf("%s: expected APIError, got %v", tt.name, err)
+		}
+		if apiErr.Status != tt.status || apiErr.Message == "" {
+			t.Errorf("%s: expected status %d with a message, got %d %q",
+				tt.name, tt.status, apiErr.Status, apiErr.Message,
+			)
+		}
+	}
+
+	// The program is not running
+	ts.Close()
+	if _, err := c.Jobs(); err == nil {
+		t.Fatal("Expected error of a stopped server")
+	} else if errors.As(err, new(*APIError)) {
+		t.Errorf("Connection error is reported as APIError: %v", err)
+	}
+}
+
+func TestClientSocket(t *testing.T) {
+	_, handler := newTestServer(t)
+
+	socket := filepath.Join(t.TempDir(), "cronshroom.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	srv := &http.Server{Handler: handler}
+	go func() { _ = srv.Serve(l) }()
+	defer func() { _ = srv.Close() }()
+
+	c := New("http://ignored:1", socket)
+	j, err := c.Job("job")
+	if err != nil {
+		t.Fatalf("Job over the socket failed: %v", err)
+	}
+	if j.Config.Command != "echo ok" {
+		t.Errorf("Unexpected job: %+v", j)
+	}
+}
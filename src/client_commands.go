@@ -0,0 +1,1017 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"cronshroom/calendars"
+	"cronshroom/client"
+	"cronshroom/extjob"
+	"cronshroom/storage"
+
+	"github.com/jessevdk/go-flags"
+)
+
+// NOTE: Client commands, they talk to the running program
+// over the web API (port or Unix socket)
+
+type clientOpts struct {
+	URL  string `long:"url" description:"Address of the running program (default: http://localhost:<port>, or the --socket if it is set)"`
+	JSON bool   `long:"json" description:"Print the output as JSON"`
+}
+
+func addClientCommands(parser *flags.Parser, fo *flagOpts) {
+	co := &clientOpts{}
+	// AddGroup returns an error only if the data is not a struct pointer
+	_, _ = parser.AddGroup("Client Options", "Options of the jobs, runs, scheduler, logs and calendars commands", co)
+
+	jobs, _ := parser.AddCommand(
+		"jobs",
+		"Manage jobs of the running program",
+		"List, show, create, delete, toggle and run jobs of the running program",
+		&struct{}{},
+	)
+	_, _ = jobs.AddCommand("list", "List jobs", "List jobs sorted by name", &jobsListCommand{fo: fo, co: co})
+	_, _ = jobs.AddCommand("get", "Show a job", "Show the job with all its fields", &jobsGetCommand{fo: fo, co: co})
+	_, _ = jobs.AddCommand(
+		"set",
+		"Create or replace a job",
+		"Create the job or replace the existing one with the same name, the job is enabled",
+		&jobsSetCommand{fo: fo, co: co},
+	)
+	_, _ = jobs.AddCommand("delete", "Delete a job", "Delete the job", &jobsDeleteCommand{fo: fo, co: co})
+	_, _ = jobs.AddCommand("toggle", "Enable or disable a job", "Disable the enabled job or enable the disabled one", &jobsToggleCommand{fo: fo, co: co})
+	_, _ = jobs.AddCommand(
+		"run",
+		"Run a job now",
+		"Run the job out of schedule and print the id of the run. "+
+			"With --wait the command waits for the end of the run, prints its output and fails if the run fails",
+		&jobsRunCommand{fo: fo, co: co},
+	)
+	_, _ = jobs.AddCommand(
+		"log",
+		"Show the log file of a job",
+		"Show the last lines of the log file of the job (the program must be started with --job-log-dir), "+
+			"with --follow print new lines until interrupted",
+		&jobsLogCommand{fo: fo, co: co},
+	)
+
+	runs, _ := parser.AddCommand(
+		"runs",
+		"Show and cancel runs of jobs",
+		"Runs are kept in memory of the running program: all running ones and the last finished ones",
+		&struct{}{},
+	)
+	_, _ = runs.AddCommand("list", "List runs", "List runs from the newest", &runsListCommand{fo: fo, co: co})
+	_, _ = runs.AddCommand("logs", "Show the output of a run", "Show stdout and stderr of the run", &runsLogsCommand{fo: fo, co: co})
+	_, _ = runs.AddCommand("cancel", "Cancel a run", "Stop the running program of the run", &runsCancelCommand{fo: fo, co: co})
+	_, _ = runs.AddCommand(
+		"queue",
+		"Show the queue of runs",
+		"Show slots of concurrency limits (the program must be started with --max-concurrent-runs or --pool) and runs waiting for them",
+		&runsQueueCommand{fo: fo, co: co},
+	)
+
+	scheduler, _ := parser.AddCommand(
+		"scheduler",
+		"Pause and resume the scheduler",
+		"While the scheduler is paused (maintenance mode), scheduled runs of all jobs are skipped, "+
+			"statuses of jobs are not changed and jobs can still be run by hand",
+		&struct{}{},
+	)
+	_, _ = scheduler.AddCommand(
+		"pause",
+		"Pause the scheduler",
+		"Pause the scheduler until it is resumed, for the duration or until the time. "+
+			"Running runs finish unless --cancel-running is set",
+		&schedulerPauseCommand{fo: fo, co: co},
+	)
+	_, _ = scheduler.AddCommand("resume", "Resume the scheduler", "Resume the paused scheduler", &schedulerResumeCommand{fo: fo, co: co})
+	_, _ = scheduler.AddCommand("status", "Show whether the scheduler is paused", "Show whether the scheduler is paused, since and until when and why", &schedulerStatusCommand{fo: fo, co: co})
+
+	cals, _ := parser.AddCommand(
+		"calendars",
+		"Manage calendars of the running program",
+		"Calendars are dates, weekly ranges and periods which suppress (exclude) or allow (only) scheduled runs of jobs",
+		&struct{}{},
+	)
+	_, _ = cals.AddCommand("list", "List calendars", "List calendars sorted by name, ACTIVE - the calendar contains the current time", &calendarsListCommand{fo: fo, co: co})
+	_, _ = cals.AddCommand("set", "Create or replace a calendar", "Create or replace the calendar from dates and weekly ranges", &calendarsSetCommand{fo: fo, co: co})
+	_, _ = cals.AddCommand(
+		"import",
+		"Import a calendar from an .ics file",
+		"Create or replace the calendar with events of the iCalendar file: all-day events are dates, other events are periods. "+
+			"Yearly recurring events are expanded, events with other recurrences are skipped",
+		&calendarsImportCommand{fo: fo, co: co},
+	)
+	_, _ = cals.AddCommand("delete", "Delete a calendar", "Delete the calendar", &calendarsDeleteCommand{fo: fo, co: co})
+
+	logs, _ := parser.AddCommand(
+		"logs",
+		"Show the log of the running program",
+		"Show the last entries of the log of the running program",
+		&struct{}{},
+	)
+	_, _ = logs.AddCommand(
+		"tail",
+		"Show the last log entries",
+		"Show the last log entries, with --follow print new ones until interrupted",
+		&logsTailCommand{fo: fo, co: co},
+	)
+	_, _ = logs.AddCommand(
+		"search",
+		"Search the log history",
+		"Search the log store of the running program from the newest entry. "+
+			"If there are more entries, the cursor of the next page is printed to stderr",
+		&logsSearchCommand{fo: fo, co: co},
+	)
+}
+
+func newClient(fo *flagOpts, co *clientOpts) *client.Client {
+	if co.URL != "" {
+		return client.New(co.URL, "")
+	}
+	if fo.SocketPath != "" {
+		return client.New("", fo.SocketPath)
+	}
+	return client.New(fmt.Sprintf("http://localhost:%d", fo.WebServerPort), "")
+}
+
+func printJSON(v any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(v)
+}
+
+func newTable() *tabwriter.Writer {
+	return tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+}
+
+// NOTE: jobs
+
+type jobArgs struct {
+	Name string `positional-arg-name:"NAME" description:"Job name"`
+}
+
+type jobsListCommand struct {
+	fo *flagOpts
+	co *clientOpts
+}
+
+func (c *jobsListCommand) Execute(args []string) error {
+	jobs, err := newClient(c.fo, c.co).Jobs()
+	if err != nil {
+		return err
+	}
+
+	if c.co.JSON {
+		return printJSON(jobs)
+	}
+
+	names := make([]string, 0, len(jobs))
+	for jk := range jobs {
+		names = append(names, jk)
+	}
+	sort.Strings(names)
+
+	t := newTable()
+	fmt.Fprintln(t, "NAME\tSTATUS\tCRON\tSTART DELAY\tCALENDARS\tCOMMAND\tDESCRIPTION")
+	for _, jk := range names {
+		j := jobs[jk]
+		fmt.Fprintf(t, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			jk,
+			j.Config.Status,
+			j.Config.CronExpression,
+			startDelay(jk, &j.Config),
+			jobCalendars(&j.Config),
+			oneLine(j.Config.Command),
+			oneLine(j.Description),
+		)
+	}
+	return t.Flush()
+}
+
+// startDelay formats the delay of scheduled runs: the stable
+// offset and the range of the random delay, "-" without a delay
+
+func startDelay(jk string, c *storage.JobConfig) string {
+	if c.StartSpread == 0 && c.StartJitter == 0 {
+		return "-"
+	}
+
+	s := c.StartOffset(jk).Round(time.Second).String()
+	if c.StartJitter > 0 {
+		s += fmt.Sprintf("+0..%ds", c.StartJitter)
+	}
+	return s
+}
+
+// jobCalendars formats calendars of the job: "not:NAME" - exclude,
+// "only:NAME" - only during, "-" without calendars
+
+func jobCalendars(c *storage.JobConfig) string {
+	var names []string
+	for _, name := range c.ExcludeCalendars {
+		names = append(names, "not:"+name)
+	}
+	for _, name := range c.OnlyCalendars {
+		names = append(names, "only:"+name)
+	}
+	if len(names) == 0 {
+		return "-"
+	}
+	return strings.Join(names, ",")
+}
+
+type jobsGetCommand struct {
+	fo   *flagOpts
+	co   *clientOpts
+	Args jobArgs `positional-args:"yes" required:"yes"`
+}
+
+func (c *jobsGetCommand) Execute(args []string) error {
+	j, err := newClient(c.fo, c.co).Job(c.Args.Name)
+	if err != nil {
+		return err
+	}
+
+	if c.co.JSON {
+		return printJSON(j)
+	}
+
+	t := newTable()
+	fmt.Fprintf(t, "name\t%s\n", c.Args.Name)
+	fmt.Fprintf(t, "type\t%s\n", j.Type)
+	fmt.Fprintf(t, "description\t%s\n", j.Description)
+	fmt.Fprintf(t, "command\t%s\n", j.Config.Command)
+	fmt.Fprintf(t, "cron_expression\t%s\n", j.Config.CronExpression)
+	fmt.Fprintf(t, "status\t%s\n", j.Config.Status)
+	fmt.Fprintf(t, "timeout\t%d\n", j.Config.Timeout)
+	fmt.Fprintf(t, "max_retries\t%d\n", j.Config.MaxRetries)
+	fmt.Fprintf(t, "retry_interval\t%d\n", j.Config.RetryInterval)
+	for _, kv := range j.Config.Environ() {
+		fmt.Fprintf(t, "env\t%s\n", kv)
+	}
+	if j.Config.User != "" {
+		fmt.Fprintf(t, "user\t%s\n", j.Config.User)
+	}
+	if j.Config.Group != "" {
+		fmt.Fprintf(t, "group\t%s\n", j.Config.Group)
+	}
+	if l := j.Config.Limits; l != nil {
+		data, _ := json.Marshal(l)
+		fmt.Fprintf(t, "limits\t%s\n", data)
+	}
+	if j.Config.MisfirePolicy != "" {
+		fmt.Fprintf(t, "misfire_policy\t%s\n", j.Config.MisfirePolicy)
+	}
+	if j.Config.MisfireMaxRuns != 0 {
+		fmt.Fprintf(t, "misfire_max_runs\t%d\n", j.Config.MisfireMaxRuns)
+	}
+	if j.Config.StartSpread != 0 || j.Config.StartJitter != 0 {
+		fmt.Fprintf(t, "start_spread\t%d\n", j.Config.StartSpread)
+		fmt.Fprintf(t, "start_jitter\t%d\n", j.Config.StartJitter)
+		fmt.Fprintf(t, "start_delay\t%s\n", startDelay(c.Args.Name, &j.Config))
+	}
+	if len(j.Config.ExcludeCalendars) > 0 {
+		fmt.Fprintf(t, "exclude_calendars\t%s\n", strings.Join(j.Config.ExcludeCalendars, ","))
+	}
+	if len(j.Config.OnlyCalendars) > 0 {
+		fmt.Fprintf(t, "only_calendars\t%s\n", strings.Join(j.Config.OnlyCalendars, ","))
+	}
+	if j.Config.Pool != "" {
+		fmt.Fprintf(t, "pool\t%s\n", j.Config.Pool)
+	}
+	if j.Config.Priority != 0 {
+		fmt.Fprintf(t, "priority\t%d\n", j.Config.Priority)
+	}
+	if j.Config.QueueMaxWait != 0 {
+		fmt.Fprintf(t, "queue_max_wait\t%d\n", j.Config.QueueMaxWait)
+	}
+	if r := j.Config.Retry; r != nil {
+		data, _ := json.Marshal(r)
+		fmt.Fprintf(t, "retry\t%s\n", data)
+	}
+	fmt.Fprintf(t, "updated_at\t%s\n", time.Unix(j.Metadata.UpdatedAt, 0).Format(time.DateTime))
+	return t.Flush()
+}
+
+type jobsSetCommand struct {
+	fo            *flagOpts
+	co            *clientOpts
+	Description   string   `long:"description" description:"Job description"`
+	Command       string   `long:"command" description:"Shell command" required:"true"`
+	Cron          string   `long:"cron" description:"Cron expression (sec min hour dom month dow [year])" required:"true"`
+	Timeout       uint     `long:"timeout" description:"Timeout in seconds, no timeout - 0 value" default:"0"`
+	MaxRetries    uint     `long:"max-retries" description:"Max retries on failure" default:"0"`
+	RetryInterval uint     `long:"retry-interval" description:"Interval between retries in seconds" default:"0"`
+	Env           []string `long:"env" description:"Environment variable VAR=value, can be repeated"`
+	User          string   `long:"user" description:"User to run the job as, the program must be run as root"`
+	Group         string   `long:"group" description:"Group to run the job as (default: the primary group of the user)"`
+	Memory        uint64   `long:"memory" description:"Address space limit in bytes, no limit - 0 value" default:"0"`
+	CPU           uint64   `long:"cpu" description:"CPU time limit in seconds, no limit - 0 value" default:"0"`
+	OpenFiles     uint64   `long:"open-files" description:"Open files limit, no limit - 0 value" default:"0"`
+	Nice          int      `long:"nice" description:"Nice level from -20 to 19" default:"0"`
+	Cgroup        string   `long:"cgroup" description:"Path of the cgroup v2 directory to run the job in"`
+	CgroupMemory  string   `long:"cgroup-memory-max" description:"memory.max of the cgroup"`
+	CgroupCPU     string   `long:"cgroup-cpu-max" description:"cpu.max of the cgroup, e.g. \"50000 100000\""`
+	Backoff       string   `long:"backoff" description:"Backoff of retries: fixed - every retry-interval, exponential - the interval is doubled every retry" choice:"fixed" choice:"exponential" default:"fixed"`
+	MaxInterval   uint     `long:"max-retry-interval" description:"Maximum interval of exponential backoff in seconds, no maximum - 0 value" default:"0"`
+	Jitter        float64  `long:"jitter" description:"Part of the retry interval (0..1) added or subtracted at random" default:"0"`
+	ExitCodes     []int    `long:"retry-exit-code" description:"Retry only this exit code, can be repeated"`
+	SkipExitCodes []int    `long:"skip-exit-code" description:"Do not retry this exit code, can be repeated"`
+	SkipTimeout   bool     `long:"skip-timeout" description:"Do not retry runs stopped by the timeout"`
+	Misfire       string   `long:"misfire-policy" description:"Occurrences missed while the program was not running: ignore, run_once - run the job once, run_all - run every occurrence" choice:"ignore" choice:"run_once" choice:"run_all" default:"ignore"`
+	MisfireMax    uint     `long:"misfire-max-runs" description:"Maximum number of runs of missed occurrences with run_all (default: 10)" default:"0"`
+	StartSpread   uint     `long:"start-spread" description:"Window in seconds of the stable start delay of the job (by its name), no delay - 0 value" default:"0"`
+	StartJitter   uint     `long:"start-jitter" description:"Maximum random start delay in seconds added on every run, no delay - 0 value" default:"0"`
+	Pool          string   `long:"pool" description:"Concurrency pool of the job (defined by --pool of the program), empty - only the global limit"`
+	Priority      int      `long:"priority" description:"Priority of queued runs, higher go first" default:"0"`
+	QueueMaxWait  uint     `long:"queue-max-wait" description:"Seconds a run waits in the queue before it is skipped, no maximum - 0 value" default:"0"`
+	Exclude       []string `long:"exclude-calendar" description:"Skip scheduled runs in the calendar, can be repeated"`
+	Only          []string `long:"only-calendar" description:"Skip scheduled runs out of the calendar (out of all of them if repeated)"`
+	Args          jobArgs  `positional-args:"yes" required:"yes"`
+}
+
+func (c *jobsSetCommand) Execute(args []string) error {
+	limits := &storage.JobLimits{
+		Memory:          c.Memory,
+		CPU:             c.CPU,
+		OpenFiles:       c.OpenFiles,
+		Nice:            c.Nice,
+		Cgroup:          c.Cgroup,
+		CgroupMemoryMax: c.CgroupMemory,
+		CgroupCPUMax:    c.CgroupCPU,
+	}
+	if *limits == (storage.JobLimits{}) {
+		limits = nil
+	}
+
+	misfire := c.Misfire
+	if misfire == storage.MisfireIgnore {
+		misfire = ""
+	}
+
+	var retry *storage.RetryConfig
+	if c.Backoff != extjob.BackoffFixed || c.MaxInterval != 0 || c.Jitter != 0 ||
+		len(c.ExitCodes) > 0 || len(c.SkipExitCodes) > 0 || c.SkipTimeout {
+		retry = &storage.RetryConfig{
+			Backoff:       c.Backoff,
+			MaxInterval:   c.MaxInterval,
+			Jitter:        c.Jitter,
+			ExitCodes:     c.ExitCodes,
+			SkipExitCodes: c.SkipExitCodes,
+			SkipTimeout:   c.SkipTimeout,
+		}
+	}
+
+	err := newClient(c.fo, c.co).SetJob(client.JobRequest{
+		Name:             c.Args.Name,
+		Description:      c.Description,
+		Command:          c.Command,
+		Cron:             c.Cron,
+		Timeout:          c.Timeout,
+		MaxRetries:       c.MaxRetries,
+		RetryInterval:    c.RetryInterval,
+		Env:              strings.Join(c.Env, "\n"),
+		User:             c.User,
+		Group:            c.Group,
+		Limits:           limits,
+		Retry:            retry,
+		MisfirePolicy:    misfire,
+		MisfireMaxRuns:   c.MisfireMax,
+		StartSpread:      c.StartSpread,
+		StartJitter:      c.StartJitter,
+		Pool:             c.Pool,
+		Priority:         c.Priority,
+		QueueMaxWait:     c.QueueMaxWait,
+		ExcludeCalendars: c.Exclude,
+		OnlyCalendars:    c.Only,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Job %s is saved\n", c.Args.Name)
+	return nil
+}
+
+type jobsDeleteCommand struct {
+	fo   *flagOpts
+	co   *clientOpts
+	Args jobArgs `positional-args:"yes" required:"yes"`
+}
+
+func (c *jobsDeleteCommand) Execute(args []string) error {
+	if err := newClient(c.fo, c.co).DeleteJob(c.Args.Name); err != nil {
+		return err
+	}
+
+	fmt.Printf("Job %s is deleted\n", c.Args.Name)
+	return nil
+}
+
+type jobsToggleCommand struct {
+	fo   *flagOpts
+	co   *clientOpts
+	Args jobArgs `positional-args:"yes" required:"yes"`
+}
+
+func (c *jobsToggleCommand) Execute(args []string) error {
+	cl := newClient(c.fo, c.co)
+	if err := cl.ToggleJob(c.Args.Name); err != nil {
+		return err
+	}
+
+	j, err := cl.Job(c.Args.Name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Job %s status: %s\n", c.Args.Name, j.Config.Status)
+	return nil
+}
+
+type jobsRunCommand struct {
+	fo   *flagOpts
+	co   *clientOpts
+	Wait bool    `short:"w" long:"wait" description:"Wait for the end of the run and print its output"`
+	Args jobArgs `positional-args:"yes" required:"yes"`
+}
+
+func (c *jobsRunCommand) Execute(args []string) error {
+	cl := newClient(c.fo, c.co)
+
+	runID, err := cl.RunJob(c.Args.Name)
+	if err != nil {
+		return err
+	}
+
+	if !c.Wait {
+		if c.co.JSON {
+			return printJSON(map[string]uint64{"run_id": runID})
+		}
+		fmt.Printf("Job %s is started, run %d\n", c.Args.Name, runID)
+		return nil
+	}
+
+	for {
+		run, err := cl.Run(runID)
+		if err != nil {
+			return err
+		}
+
+		if run.Status != storage.RunRunning {
+			if err := printRun(run, c.co.JSON); err != nil {
+				return err
+			}
+			if run.Status != storage.RunOK {
+				return fmt.Errorf("run %d: %s, exit code %d", run.ID, run.Status, run.ExitCode)
+			}
+			return nil
+		}
+
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// NOTE: runs
+
+type runArgs struct {
+	ID uint64 `positional-arg-name:"ID" description:"Run id"`
+}
+
+type runsListCommand struct {
+	fo  *flagOpts
+	co  *clientOpts
+	Job string `long:"job" description:"Show only runs of the job"`
+}
+
+func (c *runsListCommand) Execute(args []string) error {
+	runs, err := newClient(c.fo, c.co).Runs(c.Job)
+	if err != nil {
+		return err
+	}
+
+	if c.co.JSON {
+		return printJSON(runs)
+	}
+
+	t := newTable()
+	fmt.Fprintln(t, "ID\tJOB\tSTATUS\tATTEMPT\tSTARTED\tDURATION\tEXIT CODE")
+	for _, run := range runs {
+		duration, exitCode := "-", "-"
+		if !run.FinishedAt.IsZero() {
+			duration = run.FinishedAt.Sub(run.StartedAt).Round(time.Millisecond).String()
+			exitCode = fmt.Sprint(run.ExitCode)
+		}
+		fmt.Fprintf(t, "%d\t%s\t%s\t%d/%d\t%s\t%s\t%s\n",
+			run.ID,
+			run.Job,
+			run.Status,
+			run.Attempt,
+			run.Attempts,
+			run.StartedAt.Local().Format(time.DateTime),
+			duration,
+			exitCode,
+		)
+	}
+	return t.Flush()
+}
+
+type runsQueueCommand struct {
+	fo *flagOpts
+	co *clientOpts
+}
+
+func (c *runsQueueCommand) Execute(args []string) error {
+	queue, err := newClient(c.fo, c.co).Queue()
+	if err != nil {
+		return err
+	}
+
+	if c.co.JSON {
+		return printJSON(queue)
+	}
+
+	limit := "-"
+	if queue.Limit > 0 {
+		limit = fmt.Sprint(queue.Limit)
+	}
+
+	t := newTable()
+	fmt.Fprintln(t, "POOL\tRUNNING\tSIZE\tQUEUED")
+	fmt.Fprintf(t, "*\t%d\t%s\t%d\n", queue.Running, limit, len(queue.Queued))
+	for _, p := range queue.Pools {
+		fmt.Fprintf(t, "%s\t%d\t%d\t%d\n", p.Name, p.Running, p.Size, p.Queued)
+	}
+	if err := t.Flush(); err != nil {
+		return err
+	}
+
+	if len(queue.Queued) == 0 {
+		return nil
+	}
+
+	fmt.Println()
+	t = newTable()
+	fmt.Fprintln(t, "JOB\tPOOL\tPRIORITY\tWAITING")
+	for _, q := range queue.Queued {
+		pool := q.Pool
+		if pool == "" {
+			pool = "-"
+		}
+		fmt.Fprintf(t, "%s\t%s\t%d\t%s\n",
+			q.Job,
+			pool,
+			q.Priority,
+			time.Since(q.QueuedAt).Round(time.Second),
+		)
+	}
+	return t.Flush()
+}
+
+type runsLogsCommand struct {
+	fo   *flagOpts
+	co   *clientOpts
+	Args runArgs `positional-args:"yes" required:"yes"`
+}
+
+func (c *runsLogsCommand) Execute(args []string) error {
+	run, err := newClient(c.fo, c.co).Run(c.Args.ID)
+	if err != nil {
+		return err
+	}
+
+	return printRun(run, c.co.JSON)
+}
+
+type runsCancelCommand struct {
+	fo   *flagOpts
+	co   *clientOpts
+	Args runArgs `positional-args:"yes" required:"yes"`
+}
+
+func (c *runsCancelCommand) Execute(args []string) error {
+	if err := newClient(c.fo, c.co).CancelRun(c.Args.ID); err != nil {
+		return err
+	}
+
+	fmt.Printf("Run %d is canceled\n", c.Args.ID)
+	return nil
+}
+
+// NOTE: scheduler
+
+type schedulerPauseCommand struct {
+	fo            *flagOpts
+	co            *clientOpts
+	For           time.Duration `long:"for" description:"Resume the scheduler after the duration, e.g. 2h or 30m"`
+	Until         string        `long:"until" description:"Resume the scheduler at the time in RFC 3339, e.g. 2026-10-19T06:00:00+02:00"`
+	Reason        string        `long:"reason" description:"Reason of the pause, shown in the status and in the web interface"`
+	CancelRunning bool          `long:"cancel-running" description:"Cancel running runs"`
+}
+
+func (c *schedulerPauseCommand) Execute(args []string) error {
+	if c.For != 0 && c.Until != "" {
+		return fmt.Errorf("--for and --until are mutually exclusive")
+	}
+	if c.For < 0 {
+		return fmt.Errorf("--for must be positive")
+	}
+
+	req := client.PauseRequest{
+		Until:         c.Until,
+		Reason:        c.Reason,
+		CancelRunning: c.CancelRunning,
+	}
+	if c.For > 0 {
+		// Round up, so a short pause is not zero (until resumed)
+		req.Duration = uint((c.For + time.Second - 1) / time.Second)
+	}
+
+	cl := newClient(c.fo, c.co)
+	canceled, err := cl.Pause(req)
+	if err != nil {
+		return err
+	}
+
+	state, err := cl.PauseState()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Scheduler is paused %s\n", pauseUntil(state))
+	if canceled > 0 {
+		fmt.Printf("%d running runs are canceled\n", canceled)
+	}
+	return nil
+}
+
+type schedulerResumeCommand struct {
+	fo *flagOpts
+	co *clientOpts
+}
+
+func (c *schedulerResumeCommand) Execute(args []string) error {
+	if err := newClient(c.fo, c.co).Resume(); err != nil {
+		return err
+	}
+
+	fmt.Println("Scheduler is resumed")
+	return nil
+}
+
+type schedulerStatusCommand struct {
+	fo *flagOpts
+	co *clientOpts
+}
+
+func (c *schedulerStatusCommand) Execute(args []string) error {
+	state, err := newClient(c.fo, c.co).PauseState()
+	if err != nil {
+		return err
+	}
+
+	if c.co.JSON {
+		return printJSON(state)
+	}
+
+	if !state.Paused {
+		fmt.Println("Scheduler is running")
+		return nil
+	}
+
+	fmt.Printf("Scheduler is paused since %s %s\n", state.Since.Format(time.DateTime), pauseUntil(state))
+	if state.Reason != "" {
+		fmt.Printf("Reason: %s\n", oneLine(state.Reason))
+	}
+	return nil
+}
+
+func pauseUntil(state storage.PauseState) string {
+	if state.Until.IsZero() {
+		return "until it is resumed"
+	}
+	return "until " + state.Until.Format(time.DateTime)
+}
+
+// printRun prints stdout of the run to stdout and stderr to stderr
+
+func printRun(run storage.Run, asJSON bool) error {
+	if asJSON {
+		return printJSON(run)
+	}
+
+	fmt.Print(run.Stdout)
+	fmt.Fprint(os.Stderr, run.Stderr)
+	return nil
+}
+
+type jobsLogCommand struct {
+	fo     *flagOpts
+	co     *clientOpts
+	Lines  uint    `short:"n" long:"lines" description:"Number of lines to show, the whole file - 0 value" default:"20"`
+	Follow bool    `short:"f" long:"follow" description:"Print new lines until interrupted"`
+	Args   jobArgs `positional-args:"yes" required:"yes"`
+}
+
+func (c *jobsLogCommand) Execute(args []string) error {
+	cl := newClient(c.fo, c.co)
+
+	data, offset, err := cl.JobLog(c.Args.Name, c.Lines)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stdout.Write(data); err != nil {
+		return err
+	}
+
+	for c.Follow {
+		time.Sleep(time.Second)
+
+		// The offset is reset by the program if the file was rotated
+		data, offset, err = cl.JobLogFrom(c.Args.Name, offset)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stdout.Write(data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// NOTE: logs
+
+type logsTailCommand struct {
+	fo     *flagOpts
+	co     *clientOpts
+	Lines  uint `short:"n" long:"lines" description:"Number of entries to show, all kept by the program - 0 value" default:"20"`
+	Follow bool `short:"f" long:"follow" description:"Print new entries until interrupted"`
+}
+
+func (c *logsTailCommand) Execute(args []string) error {
+	cl := newClient(c.fo, c.co)
+
+	entries, err := cl.LastLog()
+	if err != nil {
+		return err
+	}
+	if c.Lines > 0 && len(entries) > int(c.Lines) {
+		entries = entries[len(entries)-int(c.Lines):]
+	}
+
+	// Entries of the last printed time, the log has millisecond
+	// precision so several entries may have the same time
+	var lastTime string
+	seen := map[string]struct{}{}
+
+	print := func(entries []client.LogEntry) error {
+		for _, e := range entries {
+			key := e.Time + e.Level + e.Message + fmt.Sprint(e.Attrs)
+			switch {
+			case e.Time < lastTime:
+				continue
+			case e.Time == lastTime:
+				if _, exists := seen[key]; exists {
+					continue
+				}
+			default:
+				lastTime = e.Time
+				seen = map[string]struct{}{}
+			}
+			seen[key] = struct{}{}
+
+			if err := printLogEntry(e, c.co.JSON); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := print(entries); err != nil {
+		return err
+	}
+
+	for c.Follow {
+		time.Sleep(time.Second)
+
+		entries, err := cl.LastLog()
+		if err != nil {
+			return err
+		}
+		if err := print(entries); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type logsSearchCommand struct {
+	fo     *flagOpts
+	co     *clientOpts
+	Level  string `long:"level" description:"Minimal level" choice:"debug" choice:"info" choice:"warn" choice:"error" default:"debug"`
+	Since  string `long:"since" description:"Entries since the time (RFC 3339, e.g. 2026-01-02T15:04:05Z)"`
+	Until  string `long:"until" description:"Entries until the time (RFC 3339)"`
+	Job    string `long:"job" description:"Entries about the job"`
+	Text   string `short:"q" long:"text" description:"Entries which message contains the text (case-insensitive)"`
+	Regex  string `long:"regex" description:"Entries which message matches the regular expression"`
+	Limit  uint   `short:"n" long:"limit" description:"Number of entries" default:"100"`
+	Cursor uint64 `long:"cursor" description:"Cursor of the page, printed by the previous search"`
+}
+
+func (c *logsSearchCommand) Execute(args []string) error {
+	params := url.Values{
+		"level": {c.Level},
+		"limit": {strconv.FormatUint(uint64(c.Limit), 10)},
+	}
+	for name, value := range map[string]string{
+		"since": c.Since,
+		"until": c.Until,
+		"job":   c.Job,
+		"q":     c.Text,
+		"regex": c.Regex,
+	} {
+		if value != "" {
+			params.Set(name, value)
+		}
+	}
+	if c.Cursor > 0 {
+		params.Set("cursor", strconv.FormatUint(c.Cursor, 10))
+	}
+
+	page, err := newClient(c.fo, c.co).SearchLogs(params)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range page.Entries {
+		if err := printLogEntry(e, c.co.JSON); err != nil {
+			return err
+		}
+	}
+	if page.NextCursor > 0 {
+		fmt.Fprintf(os.Stderr, "More entries: --cursor %d\n", page.NextCursor)
+	}
+	return nil
+}
+
+// printLogEntry prints the entry as a line, JSON lines with --json
+
+func printLogEntry(e client.LogEntry, asJSON bool) error {
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetEscapeHTML(false)
+		return encoder.Encode(e)
+	}
+
+	keys := make([]string, 0, len(e.Attrs))
+	for k := range e.Attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s %s", e.Time, e.Level, e.Message)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%v", k, oneLine(fmt.Sprint(e.Attrs[k])))
+	}
+	_, err := fmt.Println(b.String())
+	return err
+}
+
+func oneLine(s string) string {
+	return strings.ReplaceAll(s, "\n", `\n`)
+}
+
+// NOTE: calendars
+
+type calendarArgs struct {
+	Name string `positional-arg-name:"NAME" description:"Calendar name"`
+}
+
+type calendarsListCommand struct {
+	fo *flagOpts
+	co *clientOpts
+}
+
+func (c *calendarsListCommand) Execute(args []string) error {
+	infos, err := newClient(c.fo, c.co).Calendars()
+	if err != nil {
+		return err
+	}
+
+	if c.co.JSON {
+		return printJSON(infos)
+	}
+
+	t := newTable()
+	fmt.Fprintln(t, "NAME\tACTIVE\tDATES\tWEEKLY\tPERIODS\tDESCRIPTION")
+	for _, info := range infos {
+		weekly := make([]string, 0, len(info.Weekly))
+		for _, w := range info.Weekly {
+			days := "*"
+			if len(w.Days) > 0 {
+				days = strings.Join(w.Days, ",")
+			}
+			weekly = append(weekly, fmt.Sprintf("%s %s-%s", days, w.From, w.To))
+		}
+		fmt.Fprintf(t, "%s\t%v\t%d\t%s\t%d\t%s\n",
+			info.Name,
+			info.Active,
+			len(info.Dates),
+			strings.Join(weekly, "; "),
+			len(info.Periods),
+			oneLine(info.Description),
+		)
+	}
+	return t.Flush()
+}
+
+type calendarsSetCommand struct {
+	fo          *flagOpts
+	co          *clientOpts
+	Description string       `long:"description" description:"Calendar description"`
+	Dates       []string     `long:"date" description:"Whole day YYYY-MM-DD, can be repeated"`
+	Weekly      []string     `long:"weekly" description:"Weekly range \"[DAYS] HH:MM-HH:MM\", e.g. \"sat,sun 00:00-24:00\" or \"22:00-06:00\" (every day), can be repeated"`
+	Args        calendarArgs `positional-args:"yes" required:"yes"`
+}
+
+func (c *calendarsSetCommand) Execute(args []string) error {
+	calendar := &calendars.Calendar{
+		Description: c.Description,
+		Dates:       c.Dates,
+	}
+	for _, s := range c.Weekly {
+		w, err := calendars.ParseWeeklyRange(s)
+		if err != nil {
+			return err
+		}
+		calendar.Weekly = append(calendar.Weekly, w)
+	}
+
+	if err := newClient(c.fo, c.co).SetCalendar(c.Args.Name, calendar); err != nil {
+		return err
+	}
+
+	fmt.Printf("Calendar %s is saved\n", c.Args.Name)
+	return nil
+}
+
+type calendarsImportCommand struct {
+	fo          *flagOpts
+	co          *clientOpts
+	Description string `long:"description" description:"Calendar description"`
+	Args        struct {
+		Name string `positional-arg-name:"NAME" description:"Calendar name"`
+		File string `positional-arg-name:"FILE" description:"iCalendar file, - for stdin"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (c *calendarsImportCommand) Execute(args []string) error {
+	var data []byte
+	var err error
+	if c.Args.File == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(c.Args.File)
+	}
+	if err != nil {
+		return err
+	}
+
+	result, err := newClient(c.fo, c.co).ImportCalendar(c.Args.Name, c.Description, data)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Calendar %s is saved: %d dates, %d periods, %d events skipped\n",
+		c.Args.Name, result.Dates, result.Periods, result.Skipped)
+	return nil
+}
+
+type calendarsDeleteCommand struct {
+	fo   *flagOpts
+	co   *clientOpts
+	Args calendarArgs `positional-args:"yes" required:"yes"`
+}
+
+func (c *calendarsDeleteCommand) Execute(args []string) error {
+	if err := newClient(c.fo, c.co).DeleteCalendar(c.Args.Name); err != nil {
+		return err
+	}
+
+	fmt.Printf("Calendar %s is deleted\n", c.Args.Name)
+	return nil
+}
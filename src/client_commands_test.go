@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cronshroom/client"
+	"cronshroom/gui"
+	"cronshroom/storage"
+	"cronshroom/utils"
+)
+
+func TestClientCommandErrors(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := storage.New()
+	srv := gui.CreateWebServer("0", logger, logger, db, nil, utils.NewHealth(time.Second, 3), nil, context.Background())
+	ts := httptest.NewServer(srv.Handler)
+	defer ts.Close()
+
+	fo := &flagOpts{}
+	co := &clientOpts{URL: ts.URL}
+
+	tests := []struct {
+		name    string
+		command interface{ Execute([]string) error }
+		status  int
+	}{
+		{"delete of a missing job", &jobsDeleteCommand{fo: fo, co: co, Args: jobArgs{Name: "missing"}}, http.StatusNotFound},
+		{"toggle of a missing job", &jobsToggleCommand{fo: fo, co: co, Args: jobArgs{Name: "missing"}}, http.StatusNotFound},
+		{"resume of the running scheduler", &schedulerResumeCommand{fo: fo, co: co}, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		err := tt.command.Execute(nil)
+		var apiErr *client.APIError
+		if !errors.As(err, &apiErr) || apiErr.Status != tt.status {
+			t.Errorf("%s: expected API error %d, got %v", tt.name, tt.status, err)
+		}
+	}
+}
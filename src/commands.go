@@ -0,0 +1,584 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cronshroom/calendars"
+	"cronshroom/exchange"
+	"cronshroom/storage"
+
+	"github.com/jessevdk/go-flags"
+)
+
+// NOTE: Subcommands. If a subcommand is passed, the parser executes
+// it instead of starting the daemon
+
+func addCommands(parser *flags.Parser, fo *flagOpts, cfg *effectiveConfig) {
+	// AddCommand returns an error only if the data
+	// is not a struct pointer, these are always valid
+	_, _ = parser.AddCommand(
+		"migrate",
+		"Copy the database between storage backends",
+		"Copy all jobs from one database file to another, e.g. from the JSON file to the bolt database and back",
+		&migrateCommand{},
+	)
+
+	backup, _ := parser.AddCommand(
+		"backup",
+		"Manage database snapshots",
+		"List, create and restore database snapshots. The database is selected by the --database and --backend options. "+
+			"The bolt database is locked by the running program, use the web API to restore it",
+		&struct{}{},
+	)
+	_, _ = backup.AddCommand(
+		"list",
+		"List database snapshots",
+		"List database snapshots from the newest to the oldest",
+		&backupListCommand{fo: fo},
+	)
+	_, _ = backup.AddCommand(
+		"create",
+		"Create a database snapshot",
+		"Create a database snapshot and remove outdated ones",
+		&backupCreateCommand{fo: fo},
+	)
+	_, _ = backup.AddCommand(
+		"restore",
+		"Restore the database from a snapshot",
+		"Restore the database from a snapshot, the current database is saved as a new snapshot first",
+		&backupRestoreCommand{fo: fo},
+	)
+
+	_, _ = parser.AddCommand(
+		"export",
+		"Export jobs to a YAML, TOML, crontab file or systemd timers",
+		"Export jobs of the database to a YAML or TOML file. If the output file exists, its comments are kept where possible. "+
+			"Jobs can be exported as a crontab or systemd .service and .timer units as well, schedules which cannot be translated are reported",
+		&exportCommand{fo: fo},
+	)
+	_, _ = parser.AddCommand(
+		"import",
+		"Import jobs from a YAML or TOML file",
+		"Import jobs from a YAML or TOML file to the database. Modes: replace - replace all jobs, add - add only new jobs, upsert - add new and overwrite existing jobs",
+		&importCommand{fo: fo},
+	)
+	_, _ = parser.AddCommand(
+		"import-crontab",
+		"Import jobs from crontab files",
+		"Import jobs from crontab files, e.g. the output of crontab -l (pass - for stdin), /etc/crontab or /etc/cron.d/*. "+
+			"Jobs are named <file name>-<line>. Lines which cannot be translated are reported and skipped",
+		&importCrontabCommand{fo: fo},
+	)
+	_, _ = parser.AddCommand(
+		"validate",
+		"Validate a JSON database file",
+		"Validate the JSON database file selected by --database without starting the program and without changing the file. "+
+			"The report lists errors and warnings (suspicious values), the command fails if there are errors (or warnings with --strict)",
+		&validateCommand{fo: fo},
+	)
+
+	config, _ := parser.AddCommand(
+		"config",
+		"Show the configuration",
+		"Options are taken from flags, CRONSHROOM_* environment variables, the config file and defaults (in this order of precedence)",
+		&struct{}{},
+	)
+	_, _ = config.AddCommand(
+		"print",
+		"Print the effective configuration",
+		"Print values of options and where each value came from: flag, env, file or default",
+		&configPrintCommand{cfg: cfg},
+	)
+
+	addClientCommands(parser, fo)
+}
+
+// openStore opens the database selected by the --database
+// and --backend options
+
+func openStore(fo *flagOpts) (storage.Store, error) {
+	dbPath, err := resolveDatabasePath(fo.DatabasePath, fo.DatabaseBackend)
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.OpenStore(fo.DatabaseBackend, dbPath)
+}
+
+// openCalendars opens the calendars file selected by the
+// --calendars-file option
+
+func openCalendars(fo *flagOpts) (*calendars.Store, error) {
+	path, err := resolveDefaultFile(fo.CalendarsFile, defaultCalendarsName)
+	if err != nil {
+		return nil, err
+	}
+
+	return calendars.Open(path)
+}
+
+// NOTE: migrate
+
+type migrateCommand struct {
+	From        string `long:"from" description:"Path to the source database file" required:"true"`
+	FromBackend string `long:"from-backend" description:"Storage backend of the source database" choice:"json" choice:"bolt" default:"json"`
+	To          string `long:"to" description:"Path to the destination database file" required:"true"`
+	ToBackend   string `long:"to-backend" description:"Storage backend of the destination database" choice:"json" choice:"bolt" default:"bolt"`
+	Force       bool   `long:"force" description:"Overwrite the destination database if it exists"`
+}
+
+func (c *migrateCommand) Execute(args []string) error {
+	if _, err := os.Stat(c.From); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(c.To); err == nil && !c.Force {
+		return fmt.Errorf(
+			"destination %s already exists, use --force to overwrite it",
+			c.To,
+		)
+	}
+
+	src, err := storage.OpenStore(c.FromBackend, c.From)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = src.Close() }()
+
+	dst, err := storage.OpenStore(c.ToBackend, c.To)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = dst.Close() }()
+
+	db, err := storage.CopyStore(dst, src)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Migrated %d jobs: %s (%s) -> %s (%s)\n",
+		len(db.Jobs),
+		c.From, c.FromBackend,
+		c.To, c.ToBackend,
+	)
+	return nil
+}
+
+// NOTE: backup
+
+func openBackups(fo *flagOpts) (*storage.Backups, storage.Store, error) {
+	backupDir, err := resolveBackupDir(fo.BackupDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	store, err := openStore(fo)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	backups, err := storage.NewBackups(store, storage.BackupOptions{
+		Dir:      backupDir,
+		Backend:  fo.DatabaseBackend,
+		MaxCount: fo.BackupMaxCount,
+		MaxAge:   time.Second * time.Duration(fo.BackupMaxAge),
+	})
+	if err != nil {
+		_ = store.Close()
+		return nil, nil, err
+	}
+
+	return backups, store, nil
+}
+
+type backupListCommand struct {
+	fo *flagOpts
+}
+
+func (c *backupListCommand) Execute(args []string) error {
+	backups, store, err := openBackups(c.fo)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	snapshots, err := backups.List()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Snapshots in %s:\n", backups.Dir())
+	for _, s := range snapshots {
+		fmt.Printf("%s\t%s\t%d bytes\n",
+			s.Name,
+			s.Time.Format(time.DateTime),
+			s.Size,
+		)
+	}
+	return nil
+}
+
+type backupCreateCommand struct {
+	fo *flagOpts
+}
+
+func (c *backupCreateCommand) Execute(args []string) error {
+	backups, store, err := openBackups(c.fo)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	snapshot, err := backups.Create()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Snapshot is created: %s\n", snapshot.Name)
+	return nil
+}
+
+type backupRestoreCommand struct {
+	fo   *flagOpts
+	Args struct {
+		Name string `positional-arg-name:"SNAPSHOT" description:"Snapshot name from the backup list"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (c *backupRestoreCommand) Execute(args []string) error {
+	backups, store, err := openBackups(c.fo)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	db, err := backups.Restore(c.Args.Name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Database is restored from %s (%d jobs)\n",
+		c.Args.Name,
+		len(db.Jobs),
+	)
+	return nil
+}
+
+// NOTE: export, import
+
+type exportCommand struct {
+	fo     *flagOpts
+	Format string `short:"f" long:"format" description:"File format (default: by the output file extension, yaml for stdout)" choice:"yaml" choice:"toml" choice:"crontab" choice:"systemd"`
+	Output string `short:"o" long:"output" description:"Output file, the directory for systemd units (default: stdout)"`
+	User   string `long:"user" description:"User column of the crontab (for /etc/crontab, /etc/cron.d/*)"`
+}
+
+func (c *exportCommand) Execute(args []string) error {
+	format := c.Format
+	if format == "" {
+		format = exchange.FormatYAML
+		if c.Output != "" {
+			var err error
+			if format, err = exchange.FormatFromPath(c.Output); err != nil {
+				return err
+			}
+		}
+	}
+
+	if format == formatSystemd && c.Output == "" {
+		return errors.New("systemd units are written to a directory, set it with --output")
+	}
+
+	store, err := openStore(c.fo)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	jobs, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	var warnings []exchange.ExportWarning
+	switch format {
+	case formatCrontab:
+		data, warnings = exchange.ExportCrontab(jobs, c.User)
+	case formatSystemd:
+		return exportSystemd(jobs, c.Output)
+	default:
+		var previous []byte
+		if c.Output != "" {
+			previous, err = os.ReadFile(c.Output)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+
+		data, err = exchange.Export(jobs, format, previous)
+		if err != nil {
+			return err
+		}
+	}
+	printExportWarnings(warnings)
+
+	if c.Output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+
+	if err := os.WriteFile(c.Output, data, 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("Exported %d jobs to %s\n", len(jobs), c.Output)
+	return nil
+}
+
+// Formats of other schedulers, export only
+const (
+	formatCrontab = "crontab"
+	formatSystemd = "systemd"
+)
+
+func exportSystemd(jobs storage.Jobs, dir string) error {
+	files, warnings := exchange.ExportSystemd(jobs)
+	printExportWarnings(warnings)
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Exported %d jobs to %s\n", len(files)/2, dir)
+	return nil
+}
+
+// Warnings go to stderr, so stdout is a valid file
+
+func printExportWarnings(warnings []exchange.ExportWarning) {
+	for _, w := range warnings {
+		fmt.Fprintln(os.Stderr, w)
+	}
+}
+
+type importCommand struct {
+	fo     *flagOpts
+	Format string `short:"f" long:"format" description:"File format (default: by the file extension)" choice:"yaml" choice:"toml"`
+	Mode   string `short:"m" long:"mode" description:"Merge mode" choice:"replace" choice:"add" choice:"upsert" default:"upsert"`
+	DryRun bool   `short:"n" long:"dry-run" description:"Only show the changes, do not write them"`
+	Args   struct {
+		File string `positional-arg-name:"FILE" description:"File to import, - for stdin"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (c *importCommand) Execute(args []string) error {
+	format := c.Format
+	if format == "" {
+		var err error
+		if format, err = exchange.FormatFromPath(c.Args.File); err != nil {
+			return err
+		}
+	}
+
+	var data []byte
+	var err error
+	if c.Args.File == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(c.Args.File)
+	}
+	if err != nil {
+		return err
+	}
+
+	store, err := openStore(c.fo)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	db, err := store.Load()
+	if err != nil {
+		return err
+	}
+
+	// Calendars of imported jobs must exist
+	calendarStore, err := openCalendars(c.fo)
+	if err != nil {
+		return err
+	}
+	db.SetCalendars(calendarStore)
+
+	imported, err := exchange.Import(data, format, db)
+	if err != nil {
+		return err
+	}
+
+	plan, err := exchange.NewPlan(db.Jobs, imported, c.Mode)
+	if err != nil {
+		return err
+	}
+	fmt.Print(plan.Diff())
+
+	if c.DryRun || plan.Empty() {
+		return nil
+	}
+
+	plan.Apply(db)
+	if err := store.Sync(db, db.Changes()); err != nil {
+		return err
+	}
+	fmt.Println("Import done")
+	return nil
+}
+
+// NOTE: import-crontab
+
+type importCrontabCommand struct {
+	fo     *flagOpts
+	System bool   `long:"system" description:"Files have the user column (default: for /etc/crontab and /etc/cron.d/*)"`
+	Mode   string `short:"m" long:"mode" description:"Merge mode" choice:"replace" choice:"add" choice:"upsert" default:"upsert"`
+	DryRun bool   `short:"n" long:"dry-run" description:"Only show the changes, do not write them"`
+	Args   struct {
+		Files []string `positional-arg-name:"FILE" description:"Crontab files, - for stdin" required:"1"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (c *importCrontabCommand) Execute(args []string) error {
+	imported := storage.Jobs{}
+	skipped := 0
+
+	for _, path := range c.Args.Files {
+		var data []byte
+		var err error
+		prefix := "crontab"
+		if path == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(path)
+			prefix = filepath.Base(path)
+		}
+		if err != nil {
+			return err
+		}
+
+		system := c.System ||
+			path == "/etc/crontab" ||
+			strings.HasPrefix(path, "/etc/cron.d/")
+
+		res := exchange.ImportCrontab(data, prefix, system)
+		for _, issue := range res.Issues {
+			fmt.Printf("%s: %s\n", path, issue)
+			if issue.Skipped {
+				skipped++
+			}
+		}
+
+		for jk, j := range res.Jobs {
+			if _, exists := imported[jk]; exists {
+				return fmt.Errorf("job %s is imported twice, rename one of the files", jk)
+			}
+			imported[jk] = j
+		}
+	}
+
+	store, err := openStore(c.fo)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	db, err := store.Load()
+	if err != nil {
+		return err
+	}
+
+	plan, err := exchange.NewPlan(db.Jobs, imported, c.Mode)
+	if err != nil {
+		return err
+	}
+	fmt.Print(plan.Diff())
+	if skipped > 0 {
+		fmt.Printf("%d lines are skipped\n", skipped)
+	}
+
+	if c.DryRun || plan.Empty() {
+		return nil
+	}
+
+	plan.Apply(db)
+	if err := store.Sync(db, db.Changes()); err != nil {
+		return err
+	}
+	fmt.Println("Import done")
+	return nil
+}
+
+// NOTE: validate
+
+type validateCommand struct {
+	fo     *flagOpts
+	Format string `short:"f" long:"format" description:"Report format" choice:"json" choice:"text" default:"json"`
+	Strict bool   `long:"strict" description:"Fail on warnings too"`
+}
+
+func (c *validateCommand) Execute(args []string) error {
+	if c.fo.DatabaseBackend != storage.BackendJSON {
+		return errors.New("only JSON database files can be validated, copy the bolt database to JSON with the migrate command")
+	}
+
+	// The default database is not created if it does not exist
+	path := c.fo.DatabasePath
+	if path == "" {
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return err
+		}
+		path = filepath.Join(configDir, defaultDatabaseName)
+	}
+
+	calendarStore, err := openCalendars(c.fo)
+	if err != nil {
+		return err
+	}
+
+	report := storage.ValidateDatabaseFile(path, calendarStore)
+
+	if c.Format == "text" {
+		for _, p := range report.Problems {
+			job := ""
+			if p.Job != "" {
+				job = fmt.Sprintf("job %q: ", p.Job)
+			}
+			fmt.Printf("%s: %s%s\n", p.Severity, job, p.Message)
+		}
+		fmt.Printf("%s: %d jobs, %d errors, %d warnings\n",
+			path, report.Jobs, report.Errors, report.Warnings,
+		)
+	} else {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "    ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	}
+
+	if report.Errors > 0 || (c.Strict && report.Warnings > 0) {
+		return fmt.Errorf("validation failed: %d errors, %d warnings",
+			report.Errors, report.Warnings,
+		)
+	}
+	return nil
+}
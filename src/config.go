@@ -0,0 +1,246 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"cronshroom/exchange"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// NOTE: Options from the config file and environment variables.
+// Precedence: flag > environment variable > config file > default
+
+const (
+	envPrefix         = "CRONSHROOM_"
+	defaultConfigName = "cronshroom-config"
+)
+
+// The destructive cleanup is taken only from flags, the path
+// of the config file is not taken from the file itself
+var (
+	envExcluded  = map[string]bool{"cleanup": true}
+	fileExcluded = map[string]bool{"cleanup": true, "config": true}
+)
+
+const (
+	sourceFlag    = "flag"
+	sourceEnv     = "env"
+	sourceFile    = "file"
+	sourceDefault = "default"
+)
+
+type effectiveOption struct {
+	Name   string `json:"name"`
+	Value  any    `json:"value"`
+	Source string `json:"source"`
+	Env    string `json:"env,omitempty"`
+}
+
+type effectiveConfig struct {
+	// Path of the loaded config file, empty if there is no file
+	File    string            `json:"file"`
+	Options []effectiveOption `json:"options"`
+}
+
+// envKey returns the environment variable of the option: --backup-dir
+// is CRONSHROOM_BACKUP_DIR
+
+func envKey(longName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(longName, "-", "_"))
+}
+
+// programOptions returns options of flagOpts, the group which
+// go-flags creates for the data of the parser
+
+func programOptions(parser *flags.Parser) []*flags.Option {
+	return parser.Group.Find("Application Options").Options()
+}
+
+// setupEnv binds program options to environment variables,
+// the parser applies them instead of defaults
+
+func setupEnv(parser *flags.Parser) {
+	for _, o := range programOptions(parser) {
+		if envExcluded[o.LongName] {
+			continue
+		}
+		o.EnvDefaultKey = envKey(o.LongName)
+	}
+}
+
+// apply sets options which are not set by flags and environment
+// variables from the config file, it must be called after the parse
+
+func (cfg *effectiveConfig) apply(parser *flags.Parser, configPath string) error {
+	path, err := resolveConfigPath(configPath)
+	if err != nil {
+		return err
+	}
+
+	values := map[string]any{}
+	if path != "" {
+		if values, err = readConfigFile(path); err != nil {
+			return fmt.Errorf("config file %s: %w", path, err)
+		}
+		cfg.File = path
+	}
+
+	known := map[string]bool{}
+	for _, o := range programOptions(parser) {
+		known[o.LongName] = !fileExcluded[o.LongName]
+	}
+	for name := range values {
+		if !known[name] {
+			return fmt.Errorf("config file %s: unknown option %q", path, name)
+		}
+	}
+
+	for _, o := range programOptions(parser) {
+		source := sourceDefault
+		_, fromEnv := os.LookupEnv(o.EnvDefaultKey)
+
+		switch value, inFile := values[o.LongName]; {
+		case o.IsSet() && !o.IsSetDefault():
+			source = sourceFlag
+		case o.EnvDefaultKey != "" && fromEnv:
+			source = sourceEnv
+		case inFile:
+			if err := setFromFile(o, value); err != nil {
+				return fmt.Errorf("config file %s: %w", path, err)
+			}
+			source = sourceFile
+		}
+
+		cfg.Options = append(cfg.Options, effectiveOption{
+			Name:   o.LongName,
+			Value:  o.Value(),
+			Source: source,
+			Env:    o.EnvDefaultKey,
+		})
+	}
+
+	return nil
+}
+
+// setFromFile sets the option from the value of the config file, every
+// element of a list is set in turn for options which can be repeated
+
+func setFromFile(o *flags.Option, value any) error {
+	list, isList := value.([]any)
+	if !isList {
+		list = []any{value}
+	} else if o.Field().Type.Kind() != reflect.Slice {
+		return fmt.Errorf("option %q must be a single value", o.LongName)
+	}
+
+	for _, v := range list {
+		s := fmt.Sprint(v)
+		if err := o.Set(&s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveConfigPath returns the path of the config file, the default
+// file in system config directory is used only if it exists
+
+func resolveConfigPath(path string) (string, error) {
+	if path != "" {
+		if _, err := os.Stat(path); err != nil {
+			return "", err
+		}
+		return path, nil
+	}
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", nil
+	}
+
+	for _, ext := range []string{".toml", ".yaml", ".yml"} {
+		path := filepath.Join(configDir, defaultConfigName+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+
+	return "", nil
+}
+
+// readConfigFile reads the file of "long-option-name: value" pairs,
+// options which can be repeated take lists of values
+
+func readConfigFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := exchange.FormatFromPath(path)
+	if err != nil {
+		return nil, errors.New("the config file must be .toml, .yaml or .yml")
+	}
+
+	values := map[string]any{}
+	switch format {
+	case exchange.FormatTOML:
+		err = toml.Unmarshal(data, &values)
+	default:
+		err = yaml.Unmarshal(data, &values)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range values {
+		elements, isList := value.([]any)
+		if !isList {
+			elements = []any{value}
+		}
+		for _, v := range elements {
+			switch v.(type) {
+			case map[string]any, []any:
+				return nil, fmt.Errorf("option %q must be a single value or a list of values", name)
+			}
+		}
+	}
+
+	return values, nil
+}
+
+// NOTE: config print
+
+type configPrintCommand struct {
+	cfg    *effectiveConfig
+	Format string `short:"f" long:"format" description:"Output format" choice:"text" choice:"json" default:"text"`
+}
+
+func (c *configPrintCommand) Execute(args []string) error {
+	cfg := c.cfg
+
+	if c.Format == "json" {
+		return printJSON(cfg)
+	}
+
+	file := cfg.File
+	if file == "" {
+		file = "none"
+	}
+	fmt.Printf("Config file: %s\n\n", file)
+
+	t := newTable()
+	fmt.Fprintln(t, "OPTION\tVALUE\tSOURCE\tENV")
+	for _, o := range cfg.Options {
+		fmt.Fprintf(t, "--%s\t%v\t%s\t%s\n", o.Name, o.Value, o.Source, o.Env)
+	}
+	return t.Flush()
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/jessevdk/go-flags"
+)
+
+func TestConfigPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	data := "port = 4000\nweb-log-max = 50\nsync-interval = 5\nhttp-log = true\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	t.Setenv("CRONSHROOM_PORT", "4100")
+	t.Setenv("CRONSHROOM_WEB_LOG_MAX", "70")
+
+	var fo flagOpts
+	parser := flags.NewParser(&fo, flags.None)
+	setupEnv(parser)
+	if _, err := parser.ParseArgs([]string{"-c", path, "-p", "4200"}); err != nil {
+		t.Fatalf("ParseArgs failed: %v", err)
+	}
+
+	cfg := &effectiveConfig{}
+	if err := cfg.apply(parser, fo.ConfigPath); err != nil {
+		t.Fatalf("apply failed: %v", err)
+	}
+
+	if fo.WebServerPort != 4200 || fo.WebLogMaxEntries != 70 ||
+		fo.DatabaseSyncInterval != 5 || !fo.HTTPLog || fo.BackupMaxCount != 30 {
+		t.Fatalf("unexpected options: %+v", fo)
+	}
+
+	sources := map[string]string{}
+	for _, o := range cfg.Options {
+		sources[o.Name] = o.Source
+	}
+	expected := map[string]string{
+		"port":             sourceFlag,
+		"web-log-max":      sourceEnv,
+		"sync-interval":    sourceFile,
+		"backup-max-count": sourceDefault,
+	}
+	for name, source := range expected {
+		if sources[name] != source {
+			t.Fatalf("source of %s: expected %s, got %s", name, source, sources[name])
+		}
+	}
+}
+
+func TestConfigUnknownOption(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("cleanup: true\n"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	var fo flagOpts
+	parser := flags.NewParser(&fo, flags.None)
+	if _, err := parser.ParseArgs([]string{"-c", path}); err != nil {
+		t.Fatalf("ParseArgs failed: %v", err)
+	}
+
+	cfg := &effectiveConfig{}
+	if err := cfg.apply(parser, fo.ConfigPath); err == nil {
+		t.Fatalf("apply failed: expected error for cleanup in the config file")
+	}
+}
+
+func TestConfigLists(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		pools   []string
+		wantErr bool
+	}{
+		{"toml list", "pool = [\"db=2\", \"api=4\"]\nredact-pattern = [\"AKIA[0-9A-Z]{16}\"]\n", []string{"db=2", "api=4"}, false},
+		{"toml single value", "pool = \"db=2\"\n", []string{"db=2"}, false},
+		{"list of a single value option", "port = [4000, 4100]\n", nil, true},
+		{"nested list", "pool = [[\"db=2\"]]\n", nil, true},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "config.toml")
+		if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+
+		var fo flagOpts
+		parser := flags.NewParser(&fo, flags.None)
+		if _, err := parser.ParseArgs([]string{"-c", path}); err != nil {
+			t.Fatalf("ParseArgs failed: %v", err)
+		}
+
+		cfg := &effectiveConfig{}
+		err := cfg.apply(parser, fo.ConfigPath)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: apply succeeded, expected an error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: apply failed: %v", tt.name, err)
+		}
+		if !slices.Equal(fo.Pools, tt.pools) {
+			t.Errorf("%s: pools = %v, want %v", tt.name, fo.Pools, tt.pools)
+		}
+	}
+
+	// YAML lists
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := "redact-pattern:\n  - 'sk_live_([0-9a-zA-Z]+)'\n  - 'AKIA[0-9A-Z]{16}'\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	var fo flagOpts
+	parser := flags.NewParser(&fo, flags.None)
+	if _, err := parser.ParseArgs([]string{"-c", path}); err != nil {
+		t.Fatalf("ParseArgs failed: %v", err)
+	}
+	if err := (&effectiveConfig{}).apply(parser, fo.ConfigPath); err != nil {
+		t.Fatalf("apply failed: %v", err)
+	}
+	if len(fo.RedactPatterns) != 2 || fo.RedactPatterns[1] != "AKIA[0-9A-Z]{16}" {
+		t.Errorf("redact patterns = %v", fo.RedactPatterns)
+	}
+}
@@ -0,0 +1,190 @@
+package exchange
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NOTE: Quartz cron expression fields as sets of values, used to write
+// the schedule in formats of other schedulers
+
+type cronBound struct {
+	name  string
+	min   int
+	max   int
+	names []string // names[i] is the name of min+i
+}
+
+var (
+	boundSecond = cronBound{name: "second", min: 0, max: 59}
+	boundMinute = cronBound{name: "minute", min: 0, max: 59}
+	boundHour   = cronBound{name: "hour", min: 0, max: 23}
+	boundDom    = cronBound{name: "day of month", min: 1, max: 31}
+	boundMonth  = cronBound{
+		name: "month", min: 1, max: 12,
+		names: []string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"},
+	}
+	// 1 - Sunday
+	boundDow = cronBound{
+		name: "day of week", min: 1, max: 7,
+		names: []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"},
+	}
+	boundYear = cronBound{name: "year", min: 1970, max: 3940}
+)
+
+var quartzSpecials = map[string]string{
+	"@yearly":  "0 0 0 1 1 ?",
+	"@monthly": "0 0 0 1 * ?",
+	"@weekly":  "0 0 0 ? * 1",
+	"@daily":   "0 0 0 * * ?",
+	"@hourly":  "0 0 * * * ?",
+}
+
+// quartzFields splits the expression to 7 fields
+// (sec min hour dom month dow year)
+
+func quartzFields(expr string) ([]string, error) {
+	expr = strings.Join(strings.Fields(expr), " ")
+	if special, exists := quartzSpecials[expr]; exists {
+		expr = special
+	}
+
+	fields := strings.Split(expr, " ")
+	switch len(fields) {
+	case 6:
+		fields = append(fields, "*")
+	case 7:
+	default:
+		return nil, fmt.Errorf("invalid cron expression %q", expr)
+	}
+
+	return fields, nil
+}
+
+// cronSet is a set of values of a field
+
+type cronSet struct {
+	bound  cronBound
+	values []bool // values[v-min]
+}
+
+func (s cronSet) all() bool {
+	for _, ok := range s.values {
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (s cronSet) only(v int) bool {
+	for i, ok := range s.values {
+		if ok != (i+s.bound.min == v) {
+			return false
+		}
+	}
+	return true
+}
+
+// runs returns [from, to] ranges of consecutive values
+
+func (s cronSet) runs() [][2]int {
+	var runs [][2]int
+	for i := 0; i < len(s.values); i++ {
+		if !s.values[i] {
+			continue
+		}
+		end := i
+		for end+1 < len(s.values) && s.values[end+1] {
+			end++
+		}
+		runs = append(runs, [2]int{i + s.bound.min, end + s.bound.min})
+		i = end
+	}
+	return runs
+}
+
+// format writes the set as a list of values and ranges,
+// value formats a single value, sep separates range ends
+
+func (s cronSet) format(sep string, value func(int) string) string {
+	parts := []string{}
+	for _, r := range s.runs() {
+		switch {
+		case r[0] == r[1]:
+			parts = append(parts, value(r[0]))
+		case r[0]+1 == r[1]:
+			parts = append(parts, value(r[0]), value(r[1]))
+		default:
+			parts = append(parts, value(r[0])+sep+value(r[1]))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// expandCronField expands values, ranges, steps and lists of the field.
+// Quartz specific characters (L, W, #) have no equivalent, they are errors
+
+func expandCronField(field string, bound cronBound) (cronSet, error) {
+	set := cronSet{
+		bound:  bound,
+		values: make([]bool, bound.max-bound.min+1),
+	}
+
+	value := func(s string) (int, error) {
+		for i, name := range bound.names {
+			if strings.EqualFold(s, name) {
+				return bound.min + i, nil
+			}
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < bound.min || n > bound.max {
+			return 0, fmt.Errorf("%s %q cannot be translated", bound.name, field)
+		}
+		return n, nil
+	}
+
+	for part := range strings.SplitSeq(field, ",") {
+		step := 1
+		if r, s, found := strings.Cut(part, "/"); found {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 {
+				return set, fmt.Errorf("%s %q cannot be translated", bound.name, field)
+			}
+			part, step = r, n
+		}
+
+		var from, to int
+		var err error
+		switch {
+		case part == "*" || part == "?":
+			from, to = bound.min, bound.max
+		case strings.Contains(part, "-"):
+			a, b, _ := strings.Cut(part, "-")
+			if from, err = value(a); err != nil {
+				return set, err
+			}
+			if to, err = value(b); err != nil {
+				return set, err
+			}
+		default:
+			if from, err = value(part); err != nil {
+				return set, err
+			}
+			to = from
+			if step > 1 {
+				to = bound.max
+			}
+		}
+
+		if from > to {
+			return set, fmt.Errorf("%s %q cannot be translated", bound.name, field)
+		}
+		for v := from; v <= to; v += step {
+			set.values[v-bound.min] = true
+		}
+	}
+
+	return set, nil
+}
@@ -0,0 +1,408 @@
+package exchange
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+
+	"cronshroom/storage"
+
+	"github.com/reugn/go-quartz/quartz"
+)
+
+// NOTE: Import of crontab files (crontab -l, /etc/crontab, /etc/cron.d/*)
+
+// CrontabIssue is a line which is not imported (Skipped)
+// or imported with a difference in behaviour
+
+type CrontabIssue struct {
+	Line    int    `json:"line"`
+	Text    string `json:"text"`
+	Message string `json:"message"`
+	Skipped bool   `json:"skipped"`
+}
+
+func (i CrontabIssue) String() string {
+	kind := "warning"
+	if i.Skipped {
+		kind = "skipped"
+	}
+	return fmt.Sprintf("line %d: %s: %s\n    %s", i.Line, kind, i.Message, i.Text)
+}
+
+type CrontabResult struct {
+	Jobs   storage.Jobs
+	Issues []CrontabIssue
+}
+
+// Macros are translated to explicit expressions,
+// quartz has no @reboot and @annually/@midnight
+
+var crontabMacros = map[string]string{
+	"@yearly":   "0 0 0 1 1 ?",
+	"@annually": "0 0 0 1 1 ?",
+	"@monthly":  "0 0 0 1 * ?",
+	"@weekly":   "0 0 0 ? * 1",
+	"@daily":    "0 0 0 * * ?",
+	"@midnight": "0 0 0 * * ?",
+	"@hourly":   "0 0 * * * ?",
+}
+
+// @reboot jobs have no schedule, they are imported disabled
+// with this placeholder expression to be run manually
+const rebootPlaceholder = "0 0 0 1 1 ?"
+
+// programUser returns the name of the user of the program and whether
+// it is root, only root can run jobs as other users
+
+var programUser = func() (string, bool) {
+	name := strconv.Itoa(os.Geteuid())
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	return name, os.Geteuid() == 0
+}
+
+var crontabDays = []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}
+
+// ImportCrontab converts a crontab to jobs named <prefix>-<line>.
+// System crontabs (/etc/crontab, /etc/cron.d/*) have the user
+// column after the schedule
+
+func ImportCrontab(data []byte, prefix string, system bool) *CrontabResult {
+	res := &CrontabResult{Jobs: storage.Jobs{}}
+	env := map[string]string{}
+
+	issue := func(line int, text, message string, skipped bool) {
+		res.Issues = append(res.Issues, CrontabIssue{
+			Line:    line,
+			Text:    text,
+			Message: message,
+			Skipped: skipped,
+		})
+	}
+
+	lineNum := 0
+	for line := range strings.Lines(string(data)) {
+		lineNum++
+		text := strings.TrimSpace(line)
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		if k, v, ok := parseCrontabEnv(text); ok {
+			env[k] = v
+			switch k {
+			case "MAILTO":
+				issue(lineNum, text, "mail is not supported, the output goes to the log", false)
+			case "SHELL":
+				issue(lineNum, text, "commands are run by bash (or sh), SHELL is only passed to the environment", false)
+			}
+			continue
+		}
+
+		entry, err := parseCrontabEntry(text, system)
+		if err != nil {
+			issue(lineNum, text, err.Error(), true)
+			continue
+		}
+
+		description := "Imported from crontab: " + text
+		if entry.user != "" {
+			if name, root := programUser(); !root && entry.user != name {
+				issue(lineNum, text, fmt.Sprintf(
+					"the program is not run as root, runs of the job as %s fail with permission denied", entry.user,
+				), false)
+			}
+		}
+
+		status := storage.StatusEnable
+		if entry.reboot {
+			status = storage.StatusDisable
+			issue(lineNum, text, "@reboot has no schedule, the job is imported disabled, run it manually", false)
+		}
+
+		name := fmt.Sprintf("%s-%d", prefix, lineNum)
+		if len(entry.expressions) > 1 {
+			issue(lineNum, text, "cron runs the job when either day of month or day of week matches, it is split into two jobs", false)
+		}
+
+		for i, expr := range entry.expressions {
+			jobName := name
+			if len(entry.expressions) > 1 {
+				jobName += []string{"-dom", "-dow"}[i]
+			}
+
+			j := &storage.Job{
+				Type:        storage.TypeShell,
+				Description: description,
+				Config: storage.JobConfig{
+					Command:        entry.command,
+					CronExpression: expr,
+					Status:         status,
+					User:           entry.user,
+				},
+				Metadata: storage.Metadata{
+					UpdatedAt: time.Now().Unix(),
+				},
+			}
+			if len(env) > 0 {
+				j.Config.Env = make(map[string]string, len(env))
+				for k, v := range env {
+					j.Config.Env[k] = v
+				}
+			}
+
+			res.Jobs[jobName] = j
+		}
+	}
+
+	return res
+}
+
+type crontabEntry struct {
+	expressions []string
+	user        string
+	command     string
+	reboot      bool
+}
+
+// parseCrontabEnv parses "NAME = value" lines, values may be quoted
+
+func parseCrontabEnv(text string) (string, string, bool) {
+	k, v, found := strings.Cut(text, "=")
+	if !found {
+		return "", "", false
+	}
+
+	k = strings.TrimSpace(k)
+	if k == "" || strings.ContainsAny(k, " \t*@") {
+		return "", "", false
+	}
+
+	v = strings.TrimSpace(v)
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+		v = v[1 : len(v)-1]
+	}
+
+	return k, v, true
+}
+
+func parseCrontabEntry(text string, system bool) (*crontabEntry, error) {
+	entry := &crontabEntry{}
+
+	var fields []string
+	rest := text
+	next := func() string {
+		rest = strings.TrimLeft(rest, " \t")
+		i := strings.IndexAny(rest, " \t")
+		if i < 0 {
+			f := rest
+			rest = ""
+			return f
+		}
+		f := rest[:i]
+		rest = rest[i:]
+		return f
+	}
+
+	if strings.HasPrefix(text, "@") {
+		macro := strings.ToLower(next())
+		if macro == "@reboot" {
+			entry.reboot = true
+			entry.expressions = []string{rebootPlaceholder}
+		} else {
+			expr, exists := crontabMacros[macro]
+			if !exists {
+				return nil, fmt.Errorf("unknown macro %s", macro)
+			}
+			entry.expressions = []string{expr}
+		}
+	} else {
+		for range 5 {
+			fields = append(fields, next())
+		}
+	}
+
+	if system {
+		entry.user = next()
+	}
+
+	command := strings.TrimSpace(rest)
+	if command == "" {
+		return nil, fmt.Errorf("expected %s followed by a command", crontabFormat(system))
+	}
+
+	// In crontab an unescaped % ends the command, the rest is stdin
+	if strings.Contains(strings.ReplaceAll(command, `\%`, ""), "%") {
+		return nil, fmt.Errorf("%% in the command (stdin of the command) is not supported, escape it as \\%%")
+	}
+	entry.command = strings.ReplaceAll(command, `\%`, "%")
+
+	if fields != nil {
+		expressions, err := translateCrontabSchedule(fields)
+		if err != nil {
+			return nil, err
+		}
+		entry.expressions = expressions
+	}
+
+	return entry, nil
+}
+
+func crontabFormat(system bool) string {
+	if system {
+		return "5 schedule fields and the user"
+	}
+	return "5 schedule fields"
+}
+
+// translateCrontabSchedule converts "min hour dom month dow" to quartz
+// expressions "sec min hour dom month dow". Quartz needs ? in one of
+// the day fields, so if both are restricted (cron runs the job when
+// either matches) the schedule is split into two expressions
+
+func translateCrontabSchedule(fields []string) ([]string, error) {
+	minute, hour, dom, month := fields[0], fields[1], fields[2], strings.ToUpper(fields[3])
+
+	days, err := parseCrontabDow(fields[4])
+	if err != nil {
+		return nil, err
+	}
+	dow := formatQuartzDow(days)
+
+	// Cron matches days by both fields (AND) if one of them
+	// starts with *, otherwise by either of them (OR)
+	domStar := strings.HasPrefix(dom, "*")
+	dowStar := strings.HasPrefix(fields[4], "*")
+
+	var expressions []string
+	expr := func(dom, dow string) {
+		expressions = append(expressions,
+			strings.Join([]string{"0", minute, hour, dom, month, dow}, " "),
+		)
+	}
+
+	switch {
+	case dom == "*" && dow == "*":
+		expr("*", "?")
+	case dow == "*":
+		if !dowStar && !domStar {
+			// Every day of week OR some days of month
+			expr("*", "?")
+		} else {
+			expr(dom, "?")
+		}
+	case dom == "*":
+		expr("?", dow)
+	case domStar || dowStar:
+		return nil, fmt.Errorf(
+			"day of month %s AND day of week %s is not supported by quartz",
+			dom, fields[4],
+		)
+	default:
+		expr(dom, "?")
+		expr("?", dow)
+	}
+
+	for _, e := range expressions {
+		if err := quartz.ValidateCronExpression(e); err != nil {
+			return nil, fmt.Errorf("cannot translate schedule: %w", err)
+		}
+	}
+
+	return expressions, nil
+}
+
+// parseCrontabDow returns days of week (0 - Sunday) of the field,
+// cron allows 0-7 where both 0 and 7 are Sunday
+
+func parseCrontabDow(field string) ([7]bool, error) {
+	var days [7]bool
+
+	value := func(s string) (int, error) {
+		for i, d := range crontabDays {
+			if strings.EqualFold(s, d) {
+				return i, nil
+			}
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 || n > 7 {
+			return 0, fmt.Errorf("invalid day of week %s", s)
+		}
+		return n, nil
+	}
+
+	for part := range strings.SplitSeq(field, ",") {
+		step := 1
+		if r, s, found := strings.Cut(part, "/"); found {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 {
+				return days, fmt.Errorf("invalid step in day of week %s", part)
+			}
+			part, step = r, n
+		}
+
+		var from, to int
+		if part == "*" {
+			from, to = 0, 7
+		} else if a, b, found := strings.Cut(part, "-"); found {
+			var err error
+			if from, err = value(a); err != nil {
+				return days, err
+			}
+			if to, err = value(b); err != nil {
+				return days, err
+			}
+		} else {
+			var err error
+			if from, err = value(part); err != nil {
+				return days, err
+			}
+			to = from
+			if step > 1 {
+				// "1/2" is "1-7/2"
+				to = 7
+			}
+		}
+
+		if from > to {
+			return days, fmt.Errorf("invalid range in day of week %s", part)
+		}
+		for d := from; d <= to; d += step {
+			days[d%7] = true
+		}
+	}
+
+	return days, nil
+}
+
+// formatQuartzDow formats days as quartz day of week field (1 - Sunday)
+
+func formatQuartzDow(days [7]bool) string {
+	var parts []string
+	for d := 0; d < 7; d++ {
+		if !days[d] {
+			continue
+		}
+		end := d
+		for end+1 < 7 && days[end+1] {
+			end++
+		}
+		switch {
+		case end == d:
+			parts = append(parts, strconv.Itoa(d+1))
+		default:
+			parts = append(parts, fmt.Sprintf("%d-%d", d+1, end+1))
+		}
+		d = end
+	}
+
+	if len(parts) == 1 && parts[0] == "1-7" {
+		return "*"
+	}
+	return strings.Join(parts, ",")
+}
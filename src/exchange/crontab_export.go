@@ -0,0 +1,210 @@
+package exchange
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"cronshroom/storage"
+)
+
+// NOTE: Export of jobs as a crontab
+
+// ExportWarning is a job which is exported with a difference
+// in behaviour or not exported at all (Skipped)
+
+type ExportWarning struct {
+	Job     string `json:"job"`
+	Message string `json:"message"`
+	Skipped bool   `json:"skipped"`
+}
+
+func (w ExportWarning) String() string {
+	kind := "warning"
+	if w.Skipped {
+		kind = "skipped"
+	}
+	return fmt.Sprintf("%s: %s: %s", w.Job, kind, w.Message)
+}
+
+var cronMacros = map[string]string{
+	"@yearly":  "@yearly",
+	"@monthly": "@monthly",
+	"@weekly":  "@weekly",
+	"@daily":   "@daily",
+	"@hourly":  "@hourly",
+}
+
+const crontabHeader = `# cronshroom jobs
+# Commands are run by /bin/sh, set SHELL=/bin/bash if they need bash
+`
+
+// ExportCrontab writes jobs as crontab lines. If user is not empty,
+// it is written in the user column (/etc/crontab, /etc/cron.d/*),
+// the user of a job is written instead of it if the job has one.
+// Jobs with schedules which crontab cannot express are written as
+// comments and reported
+
+func ExportCrontab(jobs storage.Jobs, user string) ([]byte, []ExportWarning) {
+	var b strings.Builder
+	var warnings []ExportWarning
+
+	b.WriteString(crontabHeader)
+
+	for _, jk := range sortedKeys(jobs) {
+		j := jobs[jk]
+
+		b.WriteString("\n# " + jk)
+		if j.Description != "" {
+			b.WriteString(": " + strings.ReplaceAll(j.Description, "\n", " "))
+		}
+		b.WriteString("\n")
+
+		line, err := crontabLine(jk, j, user)
+		if err != nil {
+			warnings = append(warnings, ExportWarning{Job: jk, Message: err.Error(), Skipped: true})
+			b.WriteString("# not exported: " + err.Error() + "\n")
+			continue
+		}
+
+		if j.Config.MaxRetries > 0 {
+			warnings = append(warnings, ExportWarning{Job: jk, Message: "retries are not supported by cron"})
+		}
+		if user == "" && j.Config.User != "" {
+			warnings = append(warnings, ExportWarning{
+				Job:     jk,
+				Message: fmt.Sprintf("the job runs as the owner of the crontab, not as %s", j.Config.User),
+			})
+		}
+		switch j.Config.MisfirePolicy {
+		case storage.MisfireRunOnce, storage.MisfireRunAll:
+			warnings = append(warnings, ExportWarning{Job: jk, Message: "missed occurrences are not run by cron, use anacron"})
+		}
+		if len(j.Config.ExcludeCalendars) > 0 || len(j.Config.OnlyCalendars) > 0 {
+			warnings = append(warnings, ExportWarning{Job: jk, Message: "calendars are not supported by cron, runs are not skipped"})
+		}
+		if j.Config.Pool != "" {
+			warnings = append(warnings, ExportWarning{Job: jk, Message: "concurrency pools are not supported by cron"})
+		}
+		if j.Config.StartJitter > 0 {
+			warnings = append(warnings, ExportWarning{Job: jk, Message: "the random start delay is not exported, only the spread"})
+		}
+		if j.Config.Limits != nil {
+			warnings = append(warnings, ExportWarning{Job: jk, Message: "resource limits are not supported by cron"})
+		}
+		if j.Config.Group != "" {
+			warnings = append(warnings, ExportWarning{
+				Job:     jk,
+				Message: fmt.Sprintf("the job runs with the primary group of the user, not %s", j.Config.Group),
+			})
+		}
+
+		if j.Config.Status.Configured() == storage.StatusDisable {
+			warnings = append(warnings, ExportWarning{Job: jk, Message: "the job is disabled, its line is commented out"})
+			line = "# " + line
+		}
+
+		b.WriteString(line + "\n")
+	}
+
+	return []byte(b.String()), warnings
+}
+
+func crontabLine(jk string, j *storage.Job, user string) (string, error) {
+	schedule, err := crontabSchedule(j.Config.CronExpression)
+	if err != nil {
+		return "", err
+	}
+
+	command := j.Config.Command
+	if strings.Contains(command, "\n") {
+		return "", errors.New("multiline commands are not supported by crontab")
+	}
+
+	if j.Config.Timeout > 0 {
+		command = fmt.Sprintf("timeout %d sh -c %s", j.Config.Timeout, shellQuote(command))
+	}
+
+	// The stable offset of the job, cron has no random delays
+	if offset := int(j.Config.StartOffset(jk).Seconds()); offset > 0 {
+		command = fmt.Sprintf("sleep %d; %s", offset, command)
+	}
+
+	if env := j.Config.Environ(); len(env) > 0 {
+		exports := make([]string, 0, len(env))
+		for _, kv := range env {
+			k, v, _ := strings.Cut(kv, "=")
+			exports = append(exports, k+"="+shellQuote(v))
+		}
+		command = "export " + strings.Join(exports, " ") + "; " + command
+	}
+
+	// % is the end of the command in crontab
+	command = strings.ReplaceAll(command, "%", `\%`)
+
+	fields := []string{schedule}
+	if user != "" {
+		if j.Config.User != "" {
+			user = j.Config.User
+		}
+		fields = append(fields, user)
+	}
+	fields = append(fields, command)
+
+	return strings.Join(fields, " "), nil
+}
+
+// crontabSchedule translates the quartz expression to 5 crontab fields
+
+func crontabSchedule(expr string) (string, error) {
+	if macro, exists := cronMacros[strings.TrimSpace(expr)]; exists {
+		return macro, nil
+	}
+
+	fields, err := quartzFields(expr)
+	if err != nil {
+		return "", err
+	}
+
+	sets := make([]cronSet, 7)
+	for i, bound := range []cronBound{
+		boundSecond, boundMinute, boundHour, boundDom, boundMonth, boundDow, boundYear,
+	} {
+		if sets[i], err = expandCronField(fields[i], bound); err != nil {
+			return "", err
+		}
+	}
+
+	if !sets[0].only(0) {
+		return "", fmt.Errorf("seconds %q are not supported by crontab", fields[0])
+	}
+	if !sets[6].all() {
+		return "", fmt.Errorf("years %q are not supported by crontab", fields[6])
+	}
+
+	crontabField := func(s cronSet) string {
+		if s.all() {
+			return "*"
+		}
+		return s.format("-", strconv.Itoa)
+	}
+
+	// Crontab day of week is 0-6 from Sunday, quartz one is 1-7
+	dow := crontabField(sets[5])
+	if !sets[5].all() {
+		dow = sets[5].format("-", func(v int) string { return strconv.Itoa(v - 1) })
+	}
+
+	return strings.Join([]string{
+		crontabField(sets[1]),
+		crontabField(sets[2]),
+		crontabField(sets[3]),
+		crontabField(sets[4]),
+		dow,
+	}, " "), nil
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
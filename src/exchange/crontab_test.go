@@ -0,0 +1,249 @@
+package exchange
+
+import (
+	"strings"
+	"testing"
+
+	"cronshroom/storage"
+)
+
+func TestTranslateCrontabSchedule(t *testing.T) {
+	tests := []struct {
+		name        string
+		schedule    string
+		expected    []string
+		expectError bool
+	}{
+		{
+			name:     "every minute",
+			schedule: "* * * * *",
+			expected: []string{"0 * * * * ?"},
+		},
+		{
+			name:     "steps and ranges",
+			schedule: "*/15 9-17 * jan-mar *",
+			expected: []string{"0 */15 9-17 * JAN-MAR ?"},
+		},
+		{
+			name:     "day of week names and sunday as 7",
+			schedule: "30 2 * * mon-fri,7",
+			expected: []string{"0 30 2 ? * 1-6"},
+		},
+		{
+			name:     "sunday as 0",
+			schedule: "0 0 * * 0",
+			expected: []string{"0 0 0 ? * 1"},
+		},
+		{
+			name:     "day of month",
+			schedule: "0 0 1,15 * *",
+			expected: []string{"0 0 0 1,15 * ?"},
+		},
+		{
+			name:     "both day fields are split",
+			schedule: "0 0 1 * 1",
+			expected: []string{"0 0 0 1 * ?", "0 0 0 ? * 2"},
+		},
+		{
+			name:        "day of month AND day of week",
+			schedule:    "0 0 */2 * 1",
+			expectError: true,
+		},
+		{
+			name:        "invalid day of week",
+			schedule:    "0 0 * * 8",
+			expectError: true,
+		},
+		{
+			name:        "invalid minute",
+			schedule:    "61 0 * * *",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := parseCrontabEntry(tt.schedule+" echo", false)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error, got %v", entry.expressions)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCrontabEntry failed: %v", err)
+			}
+			if len(entry.expressions) != len(tt.expected) {
+				t.Fatalf("Expected %v, got %v", tt.expected, entry.expressions)
+			}
+			for i := range tt.expected {
+				if entry.expressions[i] != tt.expected[i] {
+					t.Errorf("Expected %v, got %v", tt.expected, entry.expressions)
+				}
+			}
+		})
+	}
+}
+
+func TestImportCrontab(t *testing.T) {
+	crontab := `# m h dom mon dow user command
+SHELL=/bin/sh
+MAILTO="ops@example.com"
+PATH = /usr/local/bin:/usr/bin
+
+17 * * * * root cd / && run-parts --report /etc/cron.hourly
+@daily  root  /usr/local/bin/backup.sh
+@reboot root /usr/local/bin/warmup.sh
+0 0 * * * root echo $(date +%F)
+0 0 * *
+`
+
+	res := ImportCrontab([]byte(crontab), "crontab", true)
+
+	if len(res.Jobs) != 3 {
+		t.Fatalf("Expected 3 jobs, got %d", len(res.Jobs))
+	}
+
+	hourly := res.Jobs["crontab-6"]
+	if hourly == nil {
+		t.Fatalf("Job crontab-6 is not imported")
+	}
+	if hourly.Config.Command != "cd / && run-parts --report /etc/cron.hourly" {
+		t.Errorf("Unexpected command %q", hourly.Config.Command)
+	}
+	if hourly.Config.Env["MAILTO"] != "ops@example.com" ||
+		hourly.Config.Env["PATH"] != "/usr/local/bin:/usr/bin" {
+		t.Errorf("Unexpected env %v", hourly.Config.Env)
+	}
+
+	if res.Jobs["crontab-7"].Config.CronExpression != "0 0 0 * * ?" {
+		t.Errorf("Unexpected @daily expression %s", res.Jobs["crontab-7"].Config.CronExpression)
+	}
+	if res.Jobs["crontab-8"].Config.Status != storage.StatusDisable {
+		t.Errorf("@reboot job is not disabled")
+	}
+	if hourly.Config.User != "root" {
+		t.Errorf("Expected user root, got %q", hourly.Config.User)
+	}
+
+	for jk, j := range res.Jobs {
+		if err := storage.ValidateJob(jk, j); err != nil {
+			t.Errorf("Invalid job: %v", err)
+		}
+	}
+
+	skipped := map[int]bool{}
+	for _, issue := range res.Issues {
+		if issue.Skipped {
+			skipped[issue.Line] = true
+		}
+	}
+	if len(skipped) != 2 || !skipped[9] || !skipped[10] {
+		t.Errorf("Expected lines 9 and 10 to be skipped, got %v", res.Issues)
+	}
+}
+
+func TestExportSchedule(t *testing.T) {
+	tests := []struct {
+		expression string
+		crontab    string
+		systemd    string
+	}{
+		{
+			expression: "0 */15 9-17 ? * MON-FRI",
+			crontab:    "0,15,30,45 9-17 * * 1-5",
+			systemd:    "Mon..Fri *-*-* 09..17:00,15,30,45:00",
+		},
+		{
+			expression: "0 0 0 1,15 * ?",
+			crontab:    "0 0 1,15 * *",
+			systemd:    "*-*-01,15 00:00:00",
+		},
+		{
+			expression: "@weekly",
+			crontab:    "@weekly",
+			systemd:    "Sun *-*-* 00:00:00",
+		},
+		{
+			// Seconds and years are not supported by crontab
+			expression: "30 0 12 ? JAN,JUL SUN 2030",
+			systemd:    "Sun 2030-01,07-* 12:00:30",
+		},
+		{
+			expression: "0 0 0 L * ?",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			crontab, err := crontabSchedule(tt.expression)
+			if (err != nil) != (tt.crontab == "") {
+				t.Errorf("crontabSchedule error: %v", err)
+			}
+			if crontab != tt.crontab {
+				t.Errorf("Expected crontab %q, got %q", tt.crontab, crontab)
+			}
+
+			systemd, err := systemdCalendar(tt.expression)
+			if (err != nil) != (tt.systemd == "") {
+				t.Errorf("systemdCalendar error: %v", err)
+			}
+			if systemd != tt.systemd {
+				t.Errorf("Expected OnCalendar %q, got %q", tt.systemd, systemd)
+			}
+		})
+	}
+}
+
+func TestExportCrontabCommand(t *testing.T) {
+	j, err := storage.ShellJob("", "date +%F", "0 0 0 * * ?", 30, 0, 0)
+	if err != nil {
+		t.Fatalf("ShellJob failed: %v", err)
+	}
+	j.Config.Env = map[string]string{"NAME": "it's"}
+
+	data, warnings := ExportCrontab(storage.Jobs{"date": j}, "")
+	if len(warnings) != 0 {
+		t.Errorf("Unexpected warnings: %v", warnings)
+	}
+
+	expected := `0 0 * * * export NAME='it'\''s'; timeout 30 sh -c 'date +\%F'`
+	if !strings.Contains(string(data), expected+"\n") {
+		t.Errorf("Expected line %s in:\n%s", expected, data)
+	}
+
+	// The exported line is imported back to the same job
+	res := ImportCrontab(data, "crontab", false)
+	if len(res.Jobs) != 1 || len(res.Issues) != 0 {
+		t.Fatalf("Unexpected import: %v", res.Issues)
+	}
+}
+
+func TestImportCrontabUser(t *testing.T) {
+	defer func(f func() (string, bool)) { programUser = f }(programUser)
+	crontab := []byte("@daily root backup.sh\n@daily ops report.sh\n")
+
+	programUser = func() (string, bool) { return "root", true }
+	if res := ImportCrontab(crontab, "crontab", true); len(res.Issues) != 0 {
+		t.Errorf("Unexpected issues when the program is run as root: %v", res.Issues)
+	}
+
+	// The program which is not run as root cannot run jobs as other users
+	programUser = func() (string, bool) { return "ops", false }
+	res := ImportCrontab(crontab, "crontab", true)
+	if !hasIssue(res, 1, "not run as root") || hasIssue(res, 2, "not run as root") {
+		t.Errorf("Unexpected issues about users: %v", res.Issues)
+	}
+	if res.Jobs["crontab-1"].Config.User != "root" {
+		t.Errorf("Expected user root, got %q", res.Jobs["crontab-1"].Config.User)
+	}
+}
+
+func hasIssue(res *CrontabResult, line int, message string) bool {
+	for _, issue := range res.Issues {
+		if issue.Line == line && strings.Contains(issue.Message, message) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+// Package exchange: import and export of jobs in other formats
+package exchange
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cronshroom/storage"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// NOTE: Job set documents (YAML, TOML)
+
+const (
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+)
+
+// The document has the layout of the database without metadata
+// (timestamps are noise in reviews), so it is upgraded by the
+// same migrations as the database
+
+const documentHeader = "cronshroom jobs\nImport: cronshroom import <file>"
+
+// FormatFromPath returns the format by the file extension
+
+func FormatFromPath(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	case ".toml":
+		return FormatTOML, nil
+	default:
+		return "", fmt.Errorf("unknown jobs file format: %s", path)
+	}
+}
+
+// Export serializes jobs to the format. If previous (the content of
+// the file being overwritten) is not empty, its comments are kept for
+// jobs and fields which still exist
+
+func Export(jobs storage.Jobs, format string, previous []byte) ([]byte, error) {
+	doc, err := exportDocument(jobs)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatYAML:
+		return exportYAML(doc, previous)
+	case FormatTOML:
+		return exportTOML(doc, previous)
+	default:
+		return nil, fmt.Errorf("unknown jobs file format: %s", format)
+	}
+}
+
+// Import parses jobs from the document in the format, calendars of
+// jobs are checked in calendars of db
+
+func Import(data []byte, format string, db *storage.Database) (storage.Jobs, error) {
+	var doc map[string]any
+
+	switch format {
+	case FormatYAML:
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+	case FormatTOML:
+		if err := toml.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown jobs file format: %s", format)
+	}
+
+	if doc == nil {
+		doc = map[string]any{}
+	}
+	if _, exists := doc["version"]; !exists {
+		doc["version"] = storage.SchemaVersion
+	}
+	if _, exists := doc["jobs"]; !exists {
+		doc["jobs"] = map[string]any{}
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	data, _, err = storage.MigrateDocument(data)
+	if err != nil {
+		return nil, err
+	}
+
+	imported, err := storage.Deserialize(data)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	for jk, j := range imported.Jobs {
+		if err := storage.ValidateJob(jk, j); err != nil {
+			return nil, err
+		}
+		if err := db.CheckCalendars(&j.Config); err != nil {
+			return nil, fmt.Errorf("job %s: %w", jk, err)
+		}
+		j.Metadata.UpdatedAt = now
+	}
+
+	return imported.Jobs, nil
+}
+
+// exportDocument returns the document of jobs as an ordered tree,
+// fields go in the same order as in the database file, jobs are
+// sorted by name
+
+func exportDocument(jobs storage.Jobs) (*yaml.Node, error) {
+	configured := make(storage.Jobs, len(jobs))
+	for jk, j := range jobs {
+		c := *j
+		c.Config.Status = j.Config.Status.Configured()
+		configured[jk] = &c
+	}
+
+	data, err := json.Marshal(struct {
+		Version string       `json:"version"`
+		Jobs    storage.Jobs `json:"jobs"`
+	}{
+		Version: storage.SchemaVersion,
+		Jobs:    configured,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := jsonToNode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if jobsNode := mappingValue(doc, "jobs"); jobsNode != nil {
+		for i := 1; i < len(jobsNode.Content); i += 2 {
+			deleteMappingKey(jobsNode.Content[i], "metadata")
+		}
+	}
+
+	return doc, nil
+}
@@ -0,0 +1,178 @@
+package exchange
+
+import (
+	"strings"
+	"testing"
+
+	"cronshroom/storage"
+)
+
+func testJobs(t *testing.T) storage.Jobs {
+	t.Helper()
+
+	jobs := storage.Jobs{}
+	for _, name := range []string{"backup db", "cleanup"} {
+		j, err := storage.ShellJob("test "+name, `echo "`+name+`"`, "0 0 * * * *", 30, 3, 10)
+		if err != nil {
+			t.Fatalf("ShellJob failed: %v", err)
+		}
+		jobs[name] = j
+	}
+	jobs["cleanup"].Config.Status = storage.StatusActiveDuringDisable
+	return jobs
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	for _, format := range []string{FormatYAML, FormatTOML} {
+		t.Run(format, func(t *testing.T) {
+			jobs := testJobs(t)
+
+			data, err := Export(jobs, format, nil)
+			if err != nil {
+				t.Fatalf("Export failed: %v", err)
+			}
+			if strings.Contains(string(data), "updated_at") {
+				t.Errorf("Metadata is exported:\n%s", data)
+			}
+
+			imported, err := Import(data, format, storage.New())
+			if err != nil {
+				t.Fatalf("Import failed: %v\n%s", err, data)
+			}
+
+			plan, err := NewPlan(jobs, imported, MergeReplace)
+			if err != nil {
+				t.Fatalf("NewPlan failed: %v", err)
+			}
+			if !plan.Empty() {
+				t.Errorf("Jobs mismatch after round-trip:\n%s", plan.Diff())
+			}
+		})
+	}
+}
+
+func TestExportKeepsComments(t *testing.T) {
+	tests := []struct {
+		format   string
+		previous string
+		comments []string
+	}{
+		{
+			format: FormatYAML,
+			previous: `# our production jobs
+version: "1.2"
+jobs:
+  # owned by the DBA team
+  backup db:
+    config:
+      timeout: 30 # keep it short
+`,
+			comments: []string{"# our production jobs", "# owned by the DBA team", "# keep it short"},
+		},
+		{
+			format: FormatTOML,
+			previous: `# our production jobs
+
+version = '1.2'
+
+# owned by the DBA team
+[jobs.'backup db']
+type = 'shell'
+`,
+			comments: []string{"# our production jobs", "# owned by the DBA team\n[jobs.'backup db']"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			data, err := Export(testJobs(t), tt.format, []byte(tt.previous))
+			if err != nil {
+				t.Fatalf("Export failed: %v", err)
+			}
+			for _, c := range tt.comments {
+				if !strings.Contains(string(data), c) {
+					t.Errorf("Comment %q is lost:\n%s", c, data)
+				}
+			}
+		})
+	}
+}
+
+func TestImportInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		input  string
+	}{
+		{
+			name:   "unknown field",
+			format: FormatYAML,
+			input:  "jobs:\n  a:\n    config:\n      comand: echo\n",
+		},
+		{
+			name:   "invalid cron expression",
+			format: FormatYAML,
+			input:  "jobs:\n  a:\n    config:\n      command: echo\n      cron_expression: '* * *'\n",
+		},
+		{
+			name:   "calendars are not configured",
+			format: FormatYAML,
+			input:  "jobs:\n  a:\n    config:\n      command: echo\n      cron_expression: '0 0 * * * *'\n      exclude_calendars: [holidays]\n",
+		},
+		{
+			name:   "newer version",
+			format: FormatTOML,
+			input:  "version = '99.0'\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Import([]byte(tt.input), tt.format, storage.New()); err == nil {
+				t.Errorf("Expected error for input %s", tt.input)
+			}
+		})
+	}
+}
+
+func TestMergeModes(t *testing.T) {
+	current := testJobs(t)
+
+	imported := testJobs(t)
+	imported["backup db"].Config.Timeout = 60
+	delete(imported, "cleanup")
+	j, _ := storage.ShellJob("", "echo new", "0 0 * * * *", 0, 0, 0)
+	imported["new"] = j
+
+	tests := []struct {
+		mode    string
+		added   int
+		updated int
+		removed int
+	}{
+		{mode: MergeReplace, added: 1, updated: 1, removed: 1},
+		{mode: MergeUpsert, added: 1, updated: 1, removed: 0},
+		{mode: MergeAdd, added: 1, updated: 0, removed: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			plan, err := NewPlan(current, imported, tt.mode)
+			if err != nil {
+				t.Fatalf("NewPlan failed: %v", err)
+			}
+			if len(plan.Added) != tt.added ||
+				len(plan.Updated) != tt.updated ||
+				len(plan.Removed) != tt.removed {
+				t.Errorf("Unexpected plan:\n%s", plan.Diff())
+			}
+
+			db := storage.New()
+			db.Jobs = testJobs(t)
+			plan.Apply(db)
+			if len(db.Jobs) != 3-tt.removed {
+				t.Errorf("Expected %d jobs after apply, got %d", 3-tt.removed, len(db.Jobs))
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package exchange
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"cronshroom/storage"
+)
+
+// NOTE: Merge of imported jobs into the database
+
+const (
+	// All jobs are replaced by imported ones
+	MergeReplace = "replace"
+	// Only jobs with new names are added
+	MergeAdd = "add"
+	// New jobs are added, existing ones are overwritten
+	MergeUpsert = "upsert"
+)
+
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+type JobChange struct {
+	Name   string        `json:"name"`
+	Fields []FieldChange `json:"fields"`
+}
+
+// Plan is a set of changes of the database made by import
+
+type Plan struct {
+	Added     []string    `json:"added"`
+	Updated   []JobChange `json:"updated"`
+	Removed   []string    `json:"removed"`
+	Unchanged []string    `json:"unchanged"`
+	Skipped   []string    `json:"skipped"`
+
+	set storage.Jobs
+}
+
+// WARN: BEFORE CALLING THIS, PLS THINK ABOUT TAKE DB MUTEX (for current)
+
+func NewPlan(current, imported storage.Jobs, mode string) (*Plan, error) {
+	if mode != MergeReplace && mode != MergeAdd && mode != MergeUpsert {
+		return nil, fmt.Errorf("unknown merge mode: %s", mode)
+	}
+
+	p := &Plan{
+		Added:     []string{},
+		Updated:   []JobChange{},
+		Removed:   []string{},
+		Unchanged: []string{},
+		Skipped:   []string{},
+		set:       storage.Jobs{},
+	}
+
+	for _, jk := range sortedKeys(imported) {
+		j := imported[jk]
+
+		old, exists := current[jk]
+		if !exists {
+			p.Added = append(p.Added, jk)
+			p.set[jk] = j
+			continue
+		}
+
+		if mode == MergeAdd {
+			p.Skipped = append(p.Skipped, jk)
+			continue
+		}
+
+		fields, err := diffJobs(old, j)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) == 0 {
+			p.Unchanged = append(p.Unchanged, jk)
+			continue
+		}
+
+		p.Updated = append(p.Updated, JobChange{Name: jk, Fields: fields})
+		p.set[jk] = j
+	}
+
+	if mode == MergeReplace {
+		for _, jk := range sortedKeys(current) {
+			if _, exists := imported[jk]; !exists {
+				p.Removed = append(p.Removed, jk)
+			}
+		}
+	}
+
+	return p, nil
+}
+
+func (p *Plan) Empty() bool {
+	return len(p.Added) == 0 && len(p.Updated) == 0 && len(p.Removed) == 0
+}
+
+func (p *Plan) Apply(db *storage.Database) {
+	if p.Empty() {
+		return
+	}
+	db.SetJobs(p.set, p.Removed)
+}
+
+// Diff returns the plan in a human readable form
+
+func (p *Plan) Diff() string {
+	var b strings.Builder
+
+	for _, jk := range p.Added {
+		fmt.Fprintf(&b, "+ %s\n", jk)
+	}
+	for _, c := range p.Updated {
+		fmt.Fprintf(&b, "~ %s\n", c.Name)
+		for _, f := range c.Fields {
+			fmt.Fprintf(&b, "    %s: %s -> %s\n", f.Field, f.Old, f.New)
+		}
+	}
+	for _, jk := range p.Removed {
+		fmt.Fprintf(&b, "- %s\n", jk)
+	}
+
+	fmt.Fprintf(&b,
+		"%d to add, %d to update, %d to remove, %d unchanged, %d skipped\n",
+		len(p.Added), len(p.Updated), len(p.Removed),
+		len(p.Unchanged), len(p.Skipped),
+	)
+
+	return b.String()
+}
+
+// diffJobs compares jobs field by field, metadata is ignored
+
+func diffJobs(old, new *storage.Job) ([]FieldChange, error) {
+	oldFields, err := flattenJob(old)
+	if err != nil {
+		return nil, err
+	}
+	newFields, err := flattenJob(new)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := map[string]struct{}{}
+	for k := range oldFields {
+		keys[k] = struct{}{}
+	}
+	for k := range newFields {
+		keys[k] = struct{}{}
+	}
+
+	changes := []FieldChange{}
+	for _, k := range sortedKeys(keys) {
+		if oldFields[k] == newFields[k] {
+			continue
+		}
+		changes = append(changes, FieldChange{
+			Field: k,
+			Old:   oldFields[k],
+			New:   newFields[k],
+		})
+	}
+
+	return changes, nil
+}
+
+// flattenJob returns fields of the job as "config.command": "<JSON value>"
+
+func flattenJob(j *storage.Job) (map[string]string, error) {
+	configured := *j
+	configured.Config.Status = j.Config.Status.Configured()
+	configured.Metadata = storage.Metadata{}
+
+	data, err := json.Marshal(&configured)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	delete(doc, "metadata")
+
+	fields := map[string]string{}
+	var flatten func(prefix string, v any)
+	flatten = func(prefix string, v any) {
+		if m, ok := v.(map[string]any); ok {
+			for k, item := range m {
+				flatten(prefix+k+".", item)
+			}
+			return
+		}
+		value, _ := json.Marshal(v)
+		fields[strings.TrimSuffix(prefix, ".")] = string(value)
+	}
+	flatten("", doc)
+
+	return fields, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
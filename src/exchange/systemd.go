@@ -0,0 +1,247 @@
+package exchange
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"cronshroom/storage"
+)
+
+// NOTE: Export of jobs as systemd timers
+
+const systemdUnitPrefix = "cronshroom-"
+
+var systemdDays = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+
+// ExportSystemd returns .service and .timer units by file name,
+// one pair for a job
+
+func ExportSystemd(jobs storage.Jobs) (map[string][]byte, []ExportWarning) {
+	files := map[string][]byte{}
+	var warnings []ExportWarning
+
+	for _, jk := range sortedKeys(jobs) {
+		j := jobs[jk]
+
+		calendar, err := systemdCalendar(j.Config.CronExpression)
+		if err != nil {
+			warnings = append(warnings, ExportWarning{Job: jk, Message: err.Error(), Skipped: true})
+			continue
+		}
+
+		unit := systemdUnitName(jk)
+		for i := 2; files[unit+".service"] != nil; i++ {
+			unit = systemdUnitName(jk) + "-" + strconv.Itoa(i)
+		}
+
+		if j.Config.MaxRetries > 0 {
+			warnings = append(warnings, ExportWarning{Job: jk, Message: "retries are not exported"})
+		}
+		if j.Config.Status.Configured() == storage.StatusDisable {
+			warnings = append(warnings, ExportWarning{
+				Job:     jk,
+				Message: fmt.Sprintf("the job is disabled, do not enable %s.timer", unit),
+			})
+		}
+
+		files[unit+".service"] = systemdService(jk, j)
+		if j.Config.MisfirePolicy == storage.MisfireRunAll {
+			warnings = append(warnings, ExportWarning{
+				Job:     jk,
+				Message: "systemd runs missed occurrences once (Persistent=true), not every one",
+			})
+		}
+		if len(j.Config.ExcludeCalendars) > 0 || len(j.Config.OnlyCalendars) > 0 {
+			warnings = append(warnings, ExportWarning{Job: jk, Message: "calendars are not exported, runs are not skipped"})
+		}
+		if j.Config.Pool != "" {
+			warnings = append(warnings, ExportWarning{Job: jk, Message: "concurrency pools are not exported"})
+		}
+		if j.Config.StartSpread > 0 && j.Config.StartJitter > 0 {
+			warnings = append(warnings, ExportWarning{
+				Job:     jk,
+				Message: "the start delay is random up to start_spread + start_jitter, it is not stable",
+			})
+		}
+
+		files[unit+".timer"] = systemdTimer(jk, calendar, j)
+	}
+
+	return files, warnings
+}
+
+func systemdService(name string, j *storage.Job) []byte {
+	var b strings.Builder
+
+	description := j.Description
+	if description == "" {
+		description = name
+	}
+
+	fmt.Fprintf(&b, "# cronshroom job %s\n", name)
+	b.WriteString("[Unit]\n")
+	fmt.Fprintf(&b, "Description=%s\n", systemdEscape(strings.ReplaceAll(description, "\n", " ")))
+	b.WriteString("\n[Service]\n")
+	b.WriteString("Type=oneshot\n")
+	fmt.Fprintf(&b, "ExecStart=/bin/sh -c %s\n", systemdQuote(j.Config.Command, true))
+	for _, kv := range j.Config.Environ() {
+		fmt.Fprintf(&b, "Environment=%s\n", systemdQuote(kv, false))
+	}
+	if j.Config.Timeout > 0 {
+		fmt.Fprintf(&b, "TimeoutStartSec=%d\n", j.Config.Timeout)
+	}
+	if j.Config.User != "" {
+		fmt.Fprintf(&b, "User=%s\n", j.Config.User)
+	}
+	if j.Config.Group != "" {
+		fmt.Fprintf(&b, "Group=%s\n", j.Config.Group)
+	}
+	if l := j.Config.Limits; l != nil {
+		systemdLimits(&b, l)
+	}
+
+	return []byte(b.String())
+}
+
+// systemdLimits writes limits of the job, the cgroup path is
+// not written: systemd puts the service in its own cgroup
+
+func systemdLimits(b *strings.Builder, l *storage.JobLimits) {
+	if l.Memory != 0 {
+		fmt.Fprintf(b, "LimitAS=%d\n", l.Memory)
+	}
+	if l.CPU != 0 {
+		fmt.Fprintf(b, "LimitCPU=%d\n", l.CPU)
+	}
+	if l.OpenFiles != 0 {
+		fmt.Fprintf(b, "LimitNOFILE=%d\n", l.OpenFiles)
+	}
+	if l.Nice != 0 {
+		fmt.Fprintf(b, "Nice=%d\n", l.Nice)
+	}
+	if l.CgroupMemoryMax != "" && l.CgroupMemoryMax != "max" {
+		fmt.Fprintf(b, "MemoryMax=%s\n", l.CgroupMemoryMax)
+	}
+
+	// cpu.max is "quota period", the period is 100000 by default
+	quota, period, _ := strings.Cut(l.CgroupCPUMax, " ")
+	if period == "" {
+		period = "100000"
+	}
+	q, errQ := strconv.ParseUint(quota, 10, 64)
+	p, errP := strconv.ParseUint(period, 10, 64)
+	if errQ == nil && errP == nil && p != 0 {
+		fmt.Fprintf(b, "CPUQuota=%d%%\n", q*100/p)
+	}
+}
+
+func systemdTimer(name, calendar string, j *storage.Job) []byte {
+	var b strings.Builder
+
+	persistent := j.Config.MisfirePolicy == storage.MisfireRunOnce ||
+		j.Config.MisfirePolicy == storage.MisfireRunAll
+
+	fmt.Fprintf(&b, "# cronshroom job %s\n", name)
+	b.WriteString("[Unit]\n")
+	fmt.Fprintf(&b, "Description=Timer of %s\n", systemdEscape(name))
+	b.WriteString("\n[Timer]\n")
+	fmt.Fprintf(&b, "OnCalendar=%s\n", calendar)
+	b.WriteString("AccuracySec=1s\n")
+	if persistent {
+		b.WriteString("Persistent=true\n")
+	}
+	// A fixed random delay is stable for the timer like the spread
+	if delay := j.Config.StartSpread + j.Config.StartJitter; delay > 0 {
+		fmt.Fprintf(&b, "RandomizedDelaySec=%d\n", delay)
+		if j.Config.StartJitter == 0 {
+			b.WriteString("FixedRandomDelay=true\n")
+		}
+	}
+	b.WriteString("\n[Install]\n")
+	b.WriteString("WantedBy=timers.target\n")
+
+	return []byte(b.String())
+}
+
+// systemdCalendar translates the quartz expression
+// to OnCalendar= "DayOfWeek Year-Month-Day Hour:Minute:Second"
+
+func systemdCalendar(expr string) (string, error) {
+	fields, err := quartzFields(expr)
+	if err != nil {
+		return "", err
+	}
+
+	sets := make([]cronSet, 7)
+	for i, bound := range []cronBound{
+		boundSecond, boundMinute, boundHour, boundDom, boundMonth, boundDow, boundYear,
+	} {
+		if sets[i], err = expandCronField(fields[i], bound); err != nil {
+			return "", err
+		}
+	}
+
+	component := func(s cronSet, width int) string {
+		if s.all() {
+			return "*"
+		}
+		return s.format("..", func(v int) string {
+			return fmt.Sprintf("%0*d", width, v)
+		})
+	}
+
+	calendar := fmt.Sprintf("%s-%s-%s %s:%s:%s",
+		component(sets[6], 4),
+		component(sets[4], 2),
+		component(sets[3], 2),
+		component(sets[2], 2),
+		component(sets[1], 2),
+		component(sets[0], 2),
+	)
+
+	if !sets[5].all() {
+		days := sets[5].format("..", func(v int) string { return systemdDays[v-1] })
+		calendar = days + " " + calendar
+	}
+
+	return calendar, nil
+}
+
+// systemdUnitName keeps characters allowed in unit names
+
+func systemdUnitName(name string) string {
+	var b strings.Builder
+	b.WriteString(systemdUnitPrefix)
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9',
+			r == '-', r == '_', r == '.':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+// systemdEscape escapes specifiers (%)
+
+func systemdEscape(s string) string {
+	return strings.ReplaceAll(s, "%", "%%")
+}
+
+// systemdQuote writes s as a double quoted word, in commands
+// $ is escaped too (systemd expands $VAR in ExecStart=)
+
+func systemdQuote(s string, command bool) string {
+	s = strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		"\n", `\n`,
+	).Replace(systemdEscape(s))
+	if command {
+		s = strings.ReplaceAll(s, "$", "$$")
+	}
+	return `"` + s + `"`
+}
@@ -0,0 +1,129 @@
+package exchange
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// NOTE: TOML export. The TOML library does not keep comments, so
+// only comment blocks of the file header and above job tables of
+// the previous file are kept
+
+func exportTOML(doc *yaml.Node, previous []byte) ([]byte, error) {
+	header, jobComments := parseTOMLComments(previous)
+	if header == "" {
+		header = commentLines(documentHeader)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	buf.WriteString("\n\n")
+
+	version := mappingValue(doc, "version")
+	if version != nil {
+		data, err := toml.Marshal(map[string]string{"version": version.Value})
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(data)
+	}
+
+	jobsNode := mappingValue(doc, "jobs")
+	if jobsNode == nil || len(jobsNode.Content) == 0 {
+		buf.WriteString("\n[jobs]\n")
+		return buf.Bytes(), nil
+	}
+
+	for i := 0; i+1 < len(jobsNode.Content); i += 2 {
+		name := jobsNode.Content[i].Value
+
+		var job map[string]any
+		if err := jobsNode.Content[i+1].Decode(&job); err != nil {
+			return nil, err
+		}
+
+		data, err := toml.Marshal(map[string]any{
+			"jobs": map[string]any{name: job},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		buf.WriteByte('\n')
+		if comment, exists := jobComments[name]; exists {
+			buf.WriteString(comment)
+			buf.WriteByte('\n')
+		}
+		// Every job is marshaled separately, the parent
+		// table must be declared only once
+		buf.Write(bytes.TrimPrefix(data, []byte("[jobs]\n")))
+	}
+
+	return buf.Bytes(), nil
+}
+
+// parseTOMLComments returns the comment block at the start of the
+// file and comment blocks directly above [jobs.<name>] tables
+
+func parseTOMLComments(data []byte) (header string, jobs map[string]string) {
+	jobs = map[string]string{}
+
+	var block []string
+	headerDone := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if strings.HasPrefix(line, "#") {
+			block = append(block, line)
+			continue
+		}
+
+		if !headerDone && len(block) != 0 {
+			header = strings.Join(block, "\n")
+			headerDone = true
+			block = nil
+			continue
+		}
+		if line != "" {
+			headerDone = true
+		}
+
+		if strings.HasPrefix(line, "[") && len(block) != 0 {
+			if name, ok := tomlJobTableName(line); ok {
+				jobs[name] = strings.Join(block, "\n")
+			}
+		}
+		block = nil
+	}
+
+	return header, jobs
+}
+
+// tomlJobTableName returns the name of the job if
+// the line is a [jobs.<name>] table header
+
+func tomlJobTableName(line string) (string, bool) {
+	var doc map[string]any
+	if err := toml.Unmarshal([]byte(line), &doc); err != nil {
+		return "", false
+	}
+
+	jobs, ok := doc["jobs"].(map[string]any)
+	if !ok || len(jobs) != 1 {
+		return "", false
+	}
+
+	for name, v := range jobs {
+		if table, ok := v.(map[string]any); ok && len(table) == 0 {
+			return name, true
+		}
+	}
+
+	return "", false
+}
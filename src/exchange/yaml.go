@@ -0,0 +1,163 @@
+package exchange
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// NOTE: YAML export with preserved comments
+
+func exportYAML(doc *yaml.Node, previous []byte) ([]byte, error) {
+	root := &yaml.Node{
+		Kind:        yaml.DocumentNode,
+		HeadComment: commentLines(documentHeader),
+		Content:     []*yaml.Node{doc},
+	}
+
+	if len(bytes.TrimSpace(previous)) != 0 {
+		var prev yaml.Node
+		if err := yaml.Unmarshal(previous, &prev); err != nil {
+			return nil, fmt.Errorf("failed to parse previous file: %w", err)
+		}
+		copyComments(root, &prev)
+		if len(prev.Content) != 0 {
+			copyComments(doc, prev.Content[0])
+		}
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(root); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// copyComments copies comments of src to dst, for mappings
+// the comments of values are matched by keys
+
+func copyComments(dst, src *yaml.Node) {
+	if src.HeadComment != "" {
+		dst.HeadComment = src.HeadComment
+	}
+	if src.LineComment != "" {
+		dst.LineComment = src.LineComment
+	}
+	if src.FootComment != "" {
+		dst.FootComment = src.FootComment
+	}
+
+	if dst.Kind != yaml.MappingNode || src.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		srcKey, srcValue := src.Content[i], src.Content[i+1]
+		for j := 0; j+1 < len(dst.Content); j += 2 {
+			if dst.Content[j].Value != srcKey.Value {
+				continue
+			}
+			copyComments(dst.Content[j], srcKey)
+			copyComments(dst.Content[j+1], srcValue)
+			break
+		}
+	}
+}
+
+// jsonToNode converts a JSON document to a YAML
+// tree keeping the order of object fields
+
+func jsonToNode(data []byte) (*yaml.Node, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	return decodeNode(decoder)
+}
+
+func decodeNode(decoder *json.Decoder) (*yaml.Node, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := token.(type) {
+	case json.Delim:
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		if t == '[' {
+			node = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		}
+
+		for decoder.More() {
+			if node.Kind == yaml.MappingNode {
+				key, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				node.Content = append(node.Content, &yaml.Node{
+					Kind:  yaml.ScalarNode,
+					Tag:   "!!str",
+					Value: key.(string),
+				})
+			}
+
+			value, err := decodeNode(decoder)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, value)
+		}
+
+		// Closing delimiter
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		return node, nil
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: t}, nil
+	case json.Number:
+		tag := "!!int"
+		if strings.ContainsAny(t.String(), ".eE") {
+			tag = "!!float"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: t.String()}, nil
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: fmt.Sprint(t)}, nil
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+	}
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func deleteMappingKey(node *yaml.Node, key string) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+func commentLines(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, l := range lines {
+		lines[i] = "# " + l
+	}
+	return strings.Join(lines, "\n")
+}
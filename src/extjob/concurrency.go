@@ -0,0 +1,259 @@
+package extjob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NOTE: Concurrency limits. A run takes a slot of the global limit
+// and a slot of its pool, runs which cannot take slots wait in the
+// queue: by priority (higher first), then by the time of arrival
+
+var ErrQueueTimeout = errors.New("run waited in the queue too long")
+
+// QueueRequest is a run waiting for slots
+
+type QueueRequest struct {
+	Job string `json:"job"`
+	// Empty - only the global limit
+	Pool     string `json:"pool,omitempty"`
+	Priority int    `json:"priority"`
+	// No maximum - 0 value
+	MaxWait time.Duration `json:"-"`
+}
+
+type queued struct {
+	QueueRequest
+	seq      uint64
+	queuedAt time.Time
+	ready    chan struct{}
+}
+
+type Limiter struct {
+	mu sync.Mutex
+	// No limit - 0 value
+	limit   int
+	running int
+	pools   map[string]*pool
+	queue   []*queued
+	lastSeq uint64
+}
+
+type pool struct {
+	size    int
+	running int
+}
+
+// NewLimiter creates limits: the global one (no limit - 0 value)
+// and sizes of pools by name
+
+func NewLimiter(limit int, pools map[string]int) *Limiter {
+	l := &Limiter{limit: limit, pools: map[string]*pool{}}
+	for name, size := range pools {
+		l.pools[name] = &pool{size: size}
+	}
+	return l
+}
+
+// ParsePools parses "NAME=SIZE" definitions of pools
+
+func ParsePools(defs []string) (map[string]int, error) {
+	pools := map[string]int{}
+	for _, def := range defs {
+		i := strings.LastIndex(def, "=")
+		if i < 1 {
+			return nil, fmt.Errorf("invalid pool %q, expected NAME=SIZE", def)
+		}
+		size, err := strconv.Atoi(def[i+1:])
+		if err != nil || size < 1 {
+			return nil, fmt.Errorf("invalid size of pool %q, expected a number above 0", def)
+		}
+		pools[def[:i]] = size
+	}
+	return pools, nil
+}
+
+// HasPool reports whether the pool is defined
+
+func (l *Limiter) HasPool(name string) bool {
+	_, exists := l.pools[name]
+	return exists
+}
+
+// Acquire waits for slots of the run, call release when the run is
+// finished. It returns the time of waiting (0 if the run is not
+// queued), ErrQueueTimeout if it is longer than MaxWait or the
+// error of ctx
+
+func (l *Limiter) Acquire(
+	ctx context.Context,
+	req QueueRequest,
+) (release func(), waited time.Duration, err error) {
+	l.mu.Lock()
+	l.lastSeq++
+	q := &queued{
+		QueueRequest: req,
+		seq:          l.lastSeq,
+		queuedAt:     time.Now(),
+		ready:        make(chan struct{}),
+	}
+	l.queue = append(l.queue, q)
+	l.dispatch()
+	l.mu.Unlock()
+
+	var once sync.Once
+	release = func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.releaseSlots(req.Pool)
+			l.dispatch()
+		})
+	}
+
+	select {
+	case <-q.ready:
+		return release, 0, nil
+	default:
+	}
+
+	var timeout <-chan time.Time
+	if req.MaxWait > 0 {
+		timer := time.NewTimer(req.MaxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-q.ready:
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timeout:
+		err = ErrQueueTimeout
+	}
+
+	if err != nil {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		// The slots could be given while the lock was not taken
+		select {
+		case <-q.ready:
+			l.releaseSlots(req.Pool)
+		default:
+			l.remove(q)
+		}
+		l.dispatch()
+		return nil, time.Since(q.queuedAt), err
+	}
+
+	return release, time.Since(q.queuedAt), nil
+}
+
+// dispatch gives slots to waiting runs in the order of the queue.
+// A run which waits for its pool does not stop runs of other pools,
+// a run which waits for the global limit stops all runs after it
+
+// WARN: BEFORE CALLING THIS, PLS THINK ABOUT TAKE LIMITER MUTEX
+
+func (l *Limiter) dispatch() {
+	sort.SliceStable(l.queue, func(i, k int) bool {
+		if l.queue[i].Priority != l.queue[k].Priority {
+			return l.queue[i].Priority > l.queue[k].Priority
+		}
+		return l.queue[i].seq < l.queue[k].seq
+	})
+
+	waiting := l.queue[:0]
+	for i, q := range l.queue {
+		if l.limit > 0 && l.running >= l.limit {
+			waiting = append(waiting, l.queue[i:]...)
+			break
+		}
+		if p := l.pools[q.Pool]; p != nil && p.running >= p.size {
+			waiting = append(waiting, q)
+			continue
+		}
+
+		l.running++
+		if p := l.pools[q.Pool]; p != nil {
+			p.running++
+		}
+		close(q.ready)
+	}
+	clear(l.queue[len(waiting):])
+	l.queue = waiting
+}
+
+func (l *Limiter) releaseSlots(poolName string) {
+	l.running--
+	if p := l.pools[poolName]; p != nil {
+		p.running--
+	}
+}
+
+func (l *Limiter) remove(q *queued) {
+	for i := range l.queue {
+		if l.queue[i] == q {
+			l.queue = append(l.queue[:i], l.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// NOTE: State of the queue
+
+type QueueState struct {
+	// No limit - 0 value
+	Limit   int         `json:"limit"`
+	Running int         `json:"running"`
+	Pools   []PoolState `json:"pools"`
+	// Waiting runs in the order of the queue
+	Queued []QueuedRun `json:"queued"`
+}
+
+type PoolState struct {
+	Name    string `json:"name"`
+	Size    int    `json:"size"`
+	Running int    `json:"running"`
+	Queued  int    `json:"queued"`
+}
+
+type QueuedRun struct {
+	QueueRequest
+	QueuedAt time.Time `json:"queued_at"`
+}
+
+func (l *Limiter) State() QueueState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	state := QueueState{
+		Limit:   l.limit,
+		Running: l.running,
+		Pools:   make([]PoolState, 0, len(l.pools)),
+		Queued:  make([]QueuedRun, 0, len(l.queue)),
+	}
+
+	queuedByPool := map[string]int{}
+	for _, q := range l.queue {
+		state.Queued = append(state.Queued, QueuedRun{QueueRequest: q.QueueRequest, QueuedAt: q.queuedAt})
+		queuedByPool[q.Pool]++
+	}
+	for name, p := range l.pools {
+		state.Pools = append(state.Pools, PoolState{
+			Name:    name,
+			Size:    p.size,
+			Running: p.running,
+			Queued:  queuedByPool[name],
+		})
+	}
+	sort.Slice(state.Pools, func(i, k int) bool { return state.Pools[i].Name < state.Pools[k].Name })
+
+	return state
+}
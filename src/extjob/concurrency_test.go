@@ -0,0 +1,129 @@
+package extjob
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimiterPools(t *testing.T) {
+	ctx := context.Background()
+	l := NewLimiter(0, map[string]int{"db": 1})
+
+	release, waited, err := l.Acquire(ctx, QueueRequest{Job: "a", Pool: "db"})
+	if err != nil || waited != 0 {
+		t.Fatalf("Acquire = %s, %v, want a slot at once", waited, err)
+	}
+
+	// Other pools and jobs without a pool are not limited
+	other, _, err := l.Acquire(ctx, QueueRequest{Job: "b"})
+	if err != nil {
+		t.Fatalf("Acquire without a pool failed: %v", err)
+	}
+	other()
+
+	acquired := make(chan struct{})
+	go func() {
+		release, _, err := l.Acquire(ctx, QueueRequest{Job: "c", Pool: "db"})
+		if err == nil {
+			release()
+		}
+		close(acquired)
+	}()
+
+	waitQueued(t, l, 1)
+	state := l.State()
+	if state.Pools[0].Running != 1 || state.Pools[0].Queued != 1 {
+		t.Errorf("Pool state = %+v, want 1 running and 1 queued", state.Pools[0])
+	}
+
+	release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("Queued run is not given the slot after release")
+	}
+}
+
+func TestLimiterPriority(t *testing.T) {
+	ctx := context.Background()
+	l := NewLimiter(1, nil)
+
+	release, _, _ := l.Acquire(ctx, QueueRequest{Job: "running"})
+
+	order := make(chan string, 3)
+	for i, job := range []string{"low", "high", "low2"} {
+		priority := 0
+		if job == "high" {
+			priority = 10
+		}
+		go func() {
+			release, _, err := l.Acquire(ctx, QueueRequest{Job: job, Priority: priority})
+			if err != nil {
+				return
+			}
+			order <- job
+			release()
+		}()
+		waitQueued(t, l, i+1)
+	}
+
+	release()
+	for _, want := range []string{"high", "low", "low2"} {
+		select {
+		case got := <-order:
+			if got != want {
+				t.Fatalf("Run %s got the slot, want %s", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Run %s did not get the slot", want)
+		}
+	}
+}
+
+func TestLimiterMaxWait(t *testing.T) {
+	l := NewLimiter(1, nil)
+	release, _, _ := l.Acquire(context.Background(), QueueRequest{Job: "a"})
+	defer release()
+
+	_, _, err := l.Acquire(context.Background(), QueueRequest{Job: "b", MaxWait: 10 * time.Millisecond})
+	if !errors.Is(err, ErrQueueTimeout) {
+		t.Errorf("Acquire over the maximum wait = %v, want ErrQueueTimeout", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := l.Acquire(ctx, QueueRequest{Job: "c"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Acquire with a canceled context = %v, want context.Canceled", err)
+	}
+
+	if state := l.State(); len(state.Queued) != 0 || state.Running != 1 {
+		t.Errorf("State = %+v, want 1 running and nothing queued", state)
+	}
+}
+
+func TestParsePools(t *testing.T) {
+	pools, err := ParsePools([]string{"db=2", "api=1"})
+	if err != nil || pools["db"] != 2 || pools["api"] != 1 {
+		t.Errorf("ParsePools = %v, %v", pools, err)
+	}
+
+	for _, def := range []string{"db", "=2", "db=0", "db=x"} {
+		if _, err := ParsePools([]string{def}); err == nil {
+			t.Errorf("ParsePools(%q) succeeded, want an error", def)
+		}
+	}
+}
+
+func waitQueued(t *testing.T, l *Limiter, n int) {
+	t.Helper()
+
+	for range 100 {
+		if len(l.State().Queued) == n {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("%d runs are not queued", n)
+}
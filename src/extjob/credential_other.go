@@ -0,0 +1,12 @@
+//go:build !unix
+
+package extjob
+
+import (
+	"errors"
+	"os/exec"
+)
+
+func setCredential(cmd *exec.Cmd, userName, groupName string) ([]string, error) {
+	return nil, errors.New("running jobs as another user is supported only on Unix")
+}
@@ -0,0 +1,132 @@
+//go:build unix
+
+package extjob
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// setCredential makes cmd run as the user and the group (names or ids,
+// the primary group of the user if the group is empty), it returns
+// HOME, USER and LOGNAME of the user for the environment
+
+func setCredential(cmd *exec.Cmd, userName, groupName string) ([]string, error) {
+	uid, gid := uint32(os.Getuid()), uint32(os.Getgid())
+	var groups []uint32
+	var env []string
+
+	if userName != "" {
+		u, err := lookupUser(userName)
+		if err != nil {
+			return nil, err
+		}
+		uid, gid, err = parseIDs(u.Uid, u.Gid)
+		if err != nil {
+			return nil, fmt.Errorf("user %s: %w", userName, err)
+		}
+
+		groupIDs, err := u.GroupIds()
+		if err != nil {
+			return nil, fmt.Errorf("groups of user %s: %w", userName, err)
+		}
+		for _, id := range groupIDs {
+			if g, err := strconv.ParseUint(id, 10, 32); err == nil {
+				groups = append(groups, uint32(g))
+			}
+		}
+
+		env = []string{
+			"HOME=" + u.HomeDir,
+			"USER=" + u.Username,
+			"LOGNAME=" + u.Username,
+		}
+	}
+
+	if groupName != "" {
+		g, err := lookupGroup(groupName)
+		if err != nil {
+			return nil, err
+		}
+		id, err := strconv.ParseUint(g.Gid, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("group %s: %w", groupName, err)
+		}
+		gid = uint32(id)
+	}
+
+	err := checkCredential(uint32(os.Geteuid()), uint32(os.Getegid()), uid, gid, userName, groupName)
+	if err != nil {
+		return nil, err
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Credential = &syscall.Credential{
+		Uid:         uid,
+		Gid:         gid,
+		Groups:      groups,
+		NoSetGroups: os.Geteuid() != 0,
+	}
+	return env, nil
+}
+
+// checkCredential refuses other ids than the ones of the program
+// (euid and egid) if the program is not run as root
+
+func checkCredential(euid, egid, uid, gid uint32, userName, groupName string) error {
+	if euid != 0 && (uid != euid || gid != egid) {
+		return fmt.Errorf(
+			"permission denied: the job runs as %s, but the program is not run as root",
+			describeCredential(userName, groupName),
+		)
+	}
+	return nil
+}
+
+func lookupUser(name string) (*user.User, error) {
+	if u, err := user.Lookup(name); err == nil {
+		return u, nil
+	}
+	if _, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return user.LookupId(name)
+	}
+	return nil, fmt.Errorf("unknown user %s", name)
+}
+
+func lookupGroup(name string) (*user.Group, error) {
+	if g, err := user.LookupGroup(name); err == nil {
+		return g, nil
+	}
+	if _, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return user.LookupGroupId(name)
+	}
+	return nil, fmt.Errorf("unknown group %s", name)
+}
+
+func parseIDs(uid, gid string) (uint32, uint32, error) {
+	u, err := strconv.ParseUint(uid, 10, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	g, err := strconv.ParseUint(gid, 10, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	return uint32(u), uint32(g), nil
+}
+
+func describeCredential(userName, groupName string) string {
+	switch {
+	case groupName == "":
+		return "user " + userName
+	case userName == "":
+		return "group " + groupName
+	}
+	return "user " + userName + " and group " + groupName
+}
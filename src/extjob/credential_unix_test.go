@@ -0,0 +1,65 @@
+//go:build unix
+
+package extjob
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestCheckCredential(t *testing.T) {
+	tests := []struct {
+		name      string
+		euid      uint32
+		egid      uint32
+		uid       uint32
+		gid       uint32
+		expectErr bool
+	}{
+		{"root switches user", 0, 0, 1000, 1000, false},
+		{"non-root runs as itself", 1000, 1000, 1000, 1000, false},
+		{"non-root switches user", 1000, 1000, 0, 1000, true},
+		{"non-root switches group", 1000, 1000, 1000, 0, true},
+	}
+
+	for _, tt := range tests {
+		err := checkCredential(tt.euid, tt.egid, tt.uid, tt.gid, "root", "")
+		if (err != nil) != tt.expectErr {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if err != nil && !strings.Contains(err.Error(), "permission denied") {
+			t.Errorf("%s: unexpected message %v", tt.name, err)
+		}
+	}
+}
+
+func TestSetCredential(t *testing.T) {
+	cmd := exec.Command("true")
+	if _, err := setCredential(cmd, "", ""); err != nil {
+		t.Fatalf("setCredential without user failed: %v", err)
+	}
+	if c := cmd.SysProcAttr.Credential; c.Uid != uint32(os.Getuid()) || c.Gid != uint32(os.Getgid()) {
+		t.Errorf("Unexpected credential %+v", c)
+	}
+
+	if _, err := setCredential(exec.Command("true"), "no-such-user-cronshroom", ""); err == nil {
+		t.Error("Expected error for an unknown user")
+	}
+
+	cmd = exec.Command("true")
+	env, err := setCredential(cmd, "root", "")
+	if os.Geteuid() != 0 {
+		if err == nil || !strings.Contains(err.Error(), "permission denied") {
+			t.Errorf("Expected permission denied, got %v", err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("setCredential as root failed: %v", err)
+	}
+	if cmd.SysProcAttr.Credential.Uid != 0 || len(env) != 3 {
+		t.Errorf("Unexpected credential %+v, env %v", cmd.SysProcAttr.Credential, env)
+	}
+}
@@ -0,0 +1,46 @@
+package extjob
+
+import (
+	"hash/fnv"
+	"math/rand/v2"
+	"time"
+)
+
+// StartDelay delays the start of scheduled executions, so jobs
+// with the same schedule do not start at the same moment
+
+type StartDelay struct {
+	// Stable delay of the job, see SpreadOffset
+	Offset time.Duration
+	// Maximum random delay added to the offset on every execution
+	Jitter time.Duration
+}
+
+func (d StartDelay) IsZero() bool {
+	return d.Offset <= 0 && d.Jitter <= 0
+}
+
+// Next returns the delay of the next execution
+
+func (d StartDelay) Next() time.Duration {
+	delay := max(d.Offset, 0)
+	if d.Jitter > 0 {
+		delay += rand.N(d.Jitter + 1)
+	}
+	return delay
+}
+
+// SpreadOffset returns the offset of the key within the window, it is
+// the same for the key on every call (and after restarts), different
+// keys are spread over the window evenly
+
+func SpreadOffset(key string, window time.Duration) time.Duration {
+	steps := uint64(window / time.Millisecond)
+	if steps == 0 {
+		return 0
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return time.Duration(h.Sum64()%steps) * time.Millisecond
+}
@@ -0,0 +1,45 @@
+package extjob
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSpreadOffset(t *testing.T) {
+	window := time.Minute
+
+	if got := SpreadOffset("job", 0); got != 0 {
+		t.Errorf("SpreadOffset without a window = %s, want 0", got)
+	}
+
+	a := SpreadOffset("job", window)
+	if a < 0 || a >= window {
+		t.Fatalf("SpreadOffset = %s, want within [0, %s)", a, window)
+	}
+	if b := SpreadOffset("job", window); a != b {
+		t.Errorf("SpreadOffset is not stable: %s and %s", a, b)
+	}
+
+	// Keys are spread over the window
+	offsets := map[time.Duration]struct{}{}
+	for _, key := range []string{"backup", "cleanup", "report", "sync", "vacuum"} {
+		offsets[SpreadOffset(key, window)] = struct{}{}
+	}
+	if len(offsets) < 4 {
+		t.Errorf("Offsets of 5 keys are not spread: %v", offsets)
+	}
+}
+
+func TestStartDelayNext(t *testing.T) {
+	if got := (StartDelay{}).Next(); got != 0 {
+		t.Errorf("Next of zero delay = %s, want 0", got)
+	}
+
+	d := StartDelay{Offset: 5 * time.Second, Jitter: 2 * time.Second}
+	for range 100 {
+		got := d.Next()
+		if got < d.Offset || got > d.Offset+d.Jitter {
+			t.Fatalf("Next = %s, want within [%s, %s]", got, d.Offset, d.Offset+d.Jitter)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package extjob
+
+import (
+	"strings"
+)
+
+// CgroupRoot is the mount point of cgroup v2, cgroups of jobs are
+// only under it
+const CgroupRoot = "/sys/fs/cgroup/"
+
+// Limits of resources of the program of a job, zero values are
+// not applied. Limits are applied to the started shell, its
+// children inherit them
+
+type Limits struct {
+	// Address space in bytes (RLIMIT_AS)
+	Memory uint64
+	// CPU time in seconds (RLIMIT_CPU)
+	CPU uint64
+	// Number of open files (RLIMIT_NOFILE)
+	OpenFiles uint64
+	Nice      int
+	// Path of the cgroup v2 directory (under CgroupRoot) the program
+	// is put in, it is created if it does not exist
+	Cgroup string
+	// Values of memory.max and cpu.max of the cgroup
+	CgroupMemoryMax string
+	CgroupCPUMax    string
+}
+
+func (l Limits) IsZero() bool {
+	return l == Limits{}
+}
+
+// Messages of errors caused by limits: strerror of ENOMEM and
+// EMFILE, errors of runtimes, the message of the shell about
+// a killed program
+var limitMessages = []struct {
+	limit   string
+	message string
+}{
+	{"memory", "cannot allocate memory"},
+	{"memory", "out of memory"},
+	{"memory", "memoryerror"},
+	{"open_files", "too many open files"},
+	{"cpu", "cpu time limit exceeded"},
+}
+
+// limitsInOutput returns limits which are reported in the output
+// of the program
+
+func limitsInOutput(l Limits, output string) []string {
+	output = strings.ToLower(output)
+	set := map[string]bool{
+		"memory":     l.Memory != 0,
+		"open_files": l.OpenFiles != 0,
+		"cpu":        l.CPU != 0,
+	}
+
+	var hit []string
+	for _, lm := range limitMessages {
+		if set[lm.limit] && strings.Contains(output, lm.message) {
+			hit = appendLimit(hit, lm.limit)
+		}
+	}
+	return hit
+}
+
+func appendLimit(hit []string, limit string) []string {
+	for _, h := range hit {
+		if h == limit {
+			return hit
+		}
+	}
+	return append(hit, limit)
+}
@@ -0,0 +1,166 @@
+//go:build linux
+
+package extjob
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// limitRun applies limits to one execution of a job
+
+type limitRun struct {
+	limits    Limits
+	cgroupDir *os.File
+	// Counters of the cgroup before the start
+	oomKills  uint64
+	throttled uint64
+}
+
+// prepareLimits sets up the cgroup of the job
+
+func prepareLimits(l Limits) (*limitRun, error) {
+	r := &limitRun{limits: l}
+	if l.Cgroup == "" {
+		return r, nil
+	}
+
+	if _, err := os.Stat(filepath.Join(CgroupRoot, "cgroup.controllers")); err != nil {
+		return nil, errors.New("cgroup v2 is not available")
+	}
+	if err := os.MkdirAll(l.Cgroup, 0o755); err != nil {
+		return nil, fmt.Errorf("cgroup: %w", err)
+	}
+
+	for file, value := range map[string]string{
+		"memory.max": l.CgroupMemoryMax,
+		"cpu.max":    l.CgroupCPUMax,
+	} {
+		if value == "" {
+			continue
+		}
+		err := os.WriteFile(filepath.Join(l.Cgroup, file), []byte(value), 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("cgroup: %w", err)
+		}
+	}
+
+	r.oomKills = readCgroupStat(l.Cgroup, "memory.events", "oom_kill")
+	r.throttled = readCgroupStat(l.Cgroup, "cpu.stat", "nr_throttled")
+
+	dir, err := os.Open(l.Cgroup)
+	if err != nil {
+		return nil, fmt.Errorf("cgroup: %w", err)
+	}
+	r.cgroupDir = dir
+	return r, nil
+}
+
+// wrap returns the command which sets rlimits and the nice level
+// before the start. The shell sets them on itself, so there is no
+// moment when the program runs without them
+
+func (r *limitRun) wrap(command string) string {
+	l := r.limits
+
+	var limits []string
+	if l.Memory != 0 {
+		limits = append(limits, fmt.Sprintf("ulimit -v %d", max(l.Memory/1024, 1)))
+	}
+	// SIGXCPU at the limit, SIGKILL a second later
+	if l.CPU != 0 {
+		limits = append(limits,
+			fmt.Sprintf("ulimit -S -t %d", l.CPU),
+			fmt.Sprintf("ulimit -H -t %d", l.CPU+1),
+		)
+	}
+	if l.OpenFiles != 0 {
+		limits = append(limits, fmt.Sprintf("ulimit -n %d", l.OpenFiles))
+	}
+	if l.Nice != 0 {
+		limits = append(limits, fmt.Sprintf("renice -n %d -p $$ >/dev/null", l.Nice))
+	}
+
+	if len(limits) == 0 {
+		return command
+	}
+	return strings.Join(limits, " && ") + " || exit 126\n" + command
+}
+
+// setup puts the program in the cgroup, the kernel does it at the start
+
+func (r *limitRun) setup(cmd *exec.Cmd) {
+	if r.cgroupDir == nil {
+		return
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(r.cgroupDir.Fd())
+}
+
+// finish returns limits which were hit by the program
+
+func (r *limitRun) finish(state *os.ProcessState, output string) []string {
+	if r.cgroupDir != nil {
+		_ = r.cgroupDir.Close()
+	}
+
+	hit := limitsInOutput(r.limits, output)
+	l := r.limits
+
+	// The shell is killed by the signal or exits with 128+signal
+	// if the program it waits for is killed
+	if state != nil && l.CPU != 0 {
+		if ws, ok := state.Sys().(syscall.WaitStatus); ok {
+			used := state.UserTime() + state.SystemTime()
+			switch {
+			case ws.Signaled() && ws.Signal() == syscall.SIGXCPU,
+				ws.Signaled() && used.Seconds() >= float64(l.CPU),
+				ws.Exited() && ws.ExitStatus() == 128+int(syscall.SIGXCPU):
+				hit = appendLimit(hit, "cpu")
+			}
+		}
+	}
+
+	if l.Cgroup != "" {
+		if readCgroupStat(l.Cgroup, "memory.events", "oom_kill") > r.oomKills {
+			hit = appendLimit(hit, "cgroup_memory")
+		}
+		if readCgroupStat(l.Cgroup, "cpu.stat", "nr_throttled") > r.throttled {
+			hit = appendLimit(hit, "cgroup_cpu")
+		}
+	}
+
+	return hit
+}
+
+// readCgroupStat returns the "key value" counter of the
+// cgroup file, 0 if it cannot be read
+
+func readCgroupStat(dir, file, key string) uint64 {
+	f, err := os.Open(filepath.Join(dir, file))
+	if err != nil {
+		return 0
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		k, v, _ := strings.Cut(scanner.Text(), " ")
+		if k == key {
+			n, _ := strconv.ParseUint(v, 10, 64)
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,26 @@
+//go:build linux
+
+package extjob
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestLimitRunWrap(t *testing.T) {
+	r := &limitRun{limits: Limits{OpenFiles: 64, Nice: 5}}
+
+	// The shell sets limits on itself, the command inherits them
+	out, err := exec.Command("sh", "-c", r.wrap("ulimit -n; nice")).Output()
+	if err != nil {
+		t.Fatalf("Wrapped command failed: %v", err)
+	}
+	if got := strings.Fields(string(out)); len(got) != 2 || got[0] != "64" || got[1] != "5" {
+		t.Errorf("Expected open files 64 and nice 5, got %q", out)
+	}
+
+	if r := (&limitRun{}); r.wrap("true") != "true" {
+		t.Errorf("Command without limits is changed: %q", r.wrap("true"))
+	}
+}
@@ -0,0 +1,27 @@
+//go:build !linux
+
+package extjob
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+type limitRun struct {
+	limits Limits
+}
+
+func prepareLimits(l Limits) (*limitRun, error) {
+	return nil, errors.New("resource limits are supported only on Linux")
+}
+
+func (r *limitRun) wrap(command string) string {
+	return command
+}
+
+func (r *limitRun) setup(cmd *exec.Cmd) {}
+
+func (r *limitRun) finish(state *os.ProcessState, output string) []string {
+	return limitsInOutput(r.limits, output)
+}
@@ -0,0 +1,73 @@
+package extjob
+
+import (
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// NOTE: Retries of a failed execution, every attempt is a new run
+
+const (
+	BackoffFixed       = "fixed"
+	BackoffExponential = "exponential"
+
+	// Maximum delay of exponential backoff without MaxInterval,
+	// the doubled delay would overflow after ~34 retries
+	DefaultMaxRetryInterval = 24 * time.Hour
+)
+
+type RetryPolicy struct {
+	MaxRetries int
+	// The delay before the first retry
+	Interval time.Duration
+	// fixed (default) or exponential: the delay is doubled every retry
+	Backoff string
+	// Maximum delay of exponential backoff, 0 - DefaultMaxRetryInterval
+	MaxInterval time.Duration
+	// Part of the delay (0..1) added or subtracted at random
+	Jitter float64
+	// Only these exit codes are retried, all if it is empty
+	ExitCodes []int
+	// These exit codes are not retried
+	SkipExitCodes []int
+	// Runs stopped by the timeout are not retried
+	SkipTimeout bool
+}
+
+// Delay returns the delay before the retry (1 - the first one)
+
+func (p RetryPolicy) Delay(retry int) time.Duration {
+	delay := p.Interval
+	if p.Backoff == BackoffExponential {
+		if delay <= 0 {
+			delay = time.Second
+		}
+		limit := p.MaxInterval
+		if limit <= 0 {
+			limit = DefaultMaxRetryInterval
+		}
+		for i := 1; i < retry && delay < limit; i++ {
+			delay *= 2
+		}
+		delay = min(delay, limit)
+	}
+
+	if p.Jitter > 0 && delay > 0 {
+		spread := float64(delay) * min(p.Jitter, 1)
+		delay += time.Duration(spread * (2*rand.Float64() - 1))
+	}
+	return max(delay, 0)
+}
+
+// ShouldRetry reports whether the failed attempt is retried
+
+func (p RetryPolicy) ShouldRetry(exitCode int, timedOut bool) bool {
+	if timedOut {
+		return !p.SkipTimeout
+	}
+	if len(p.ExitCodes) > 0 && !slices.Contains(p.ExitCodes, exitCode) {
+		return false
+	}
+	return !slices.Contains(p.SkipExitCodes, exitCode)
+}
@@ -0,0 +1,103 @@
+package extjob
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	exponential := RetryPolicy{
+		Interval:    time.Second,
+		Backoff:     BackoffExponential,
+		MaxInterval: 5 * time.Second,
+	}
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, want := range expected {
+		if got := exponential.Delay(i + 1); got != want {
+			t.Errorf("Retry %d: expected %v, got %v", i+1, want, got)
+		}
+	}
+
+	// Without the maximum the delay saturates instead of overflowing
+	unlimited := RetryPolicy{Interval: time.Second, Backoff: BackoffExponential}
+	for _, retry := range []int{34, 40, 100, 1000} {
+		if got := unlimited.Delay(retry); got != DefaultMaxRetryInterval {
+			t.Errorf("Retry %d: expected %v, got %v", retry, DefaultMaxRetryInterval, got)
+		}
+	}
+
+	fixed := RetryPolicy{Interval: 10 * time.Second, Jitter: 0.5}
+	for retry := 1; retry <= 20; retry++ {
+		if got := fixed.Delay(retry); got < 5*time.Second || got > 15*time.Second {
+			t.Errorf("Retry %d: delay %v is out of jitter range", retry, got)
+		}
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	p := RetryPolicy{ExitCodes: []int{1, 75}, SkipTimeout: true}
+	if !p.ShouldRetry(75, false) || p.ShouldRetry(2, false) || p.ShouldRetry(1, true) {
+		t.Error("Unexpected decision of the policy with exit codes")
+	}
+
+	p = RetryPolicy{SkipExitCodes: []int{2}}
+	if !p.ShouldRetry(1, false) || p.ShouldRetry(2, false) || !p.ShouldRetry(-1, true) {
+		t.Error("Unexpected decision of the policy with skipped exit codes")
+	}
+}
+
+func TestExecuteRetries(t *testing.T) {
+	var attempts []int
+	job := NewShellJobWithCallbacks("exit 3", 0, nil, func(ctx context.Context, j *ShellJob) {
+		attempt, _ := Attempt(ctx)
+		attempts = append(attempts, attempt)
+	})
+	job.SetRetryPolicy(RetryPolicy{MaxRetries: 2, Interval: time.Millisecond})
+
+	if err := job.Execute(context.Background()); err == nil {
+		t.Fatal("Expected the job to fail")
+	}
+	if len(attempts) != 3 || attempts[2] != 3 {
+		t.Errorf("Expected 3 attempts, got %v", attempts)
+	}
+
+	attempts = nil
+	job.SetRetryPolicy(RetryPolicy{MaxRetries: 2, SkipExitCodes: []int{3}})
+	_ = job.Execute(context.Background())
+	if len(attempts) != 1 {
+		t.Errorf("Expected 1 attempt for skipped exit code, got %v", attempts)
+	}
+}
+
+func TestExecuteSkipAfterDelayAndBeforeRetries(t *testing.T) {
+	var attempts []int
+	job := NewShellJobWithCallbacks("exit 3", 0, nil, func(ctx context.Context, j *ShellJob) {
+		attempt, _ := Attempt(ctx)
+		attempts = append(attempts, attempt)
+	})
+	job.SetRetryPolicy(RetryPolicy{MaxRetries: 2, Interval: time.Millisecond})
+
+	// The run is skipped when it is checked after the first attempt
+	checks := 0
+	job.SetSkip(func(context.Context) bool {
+		checks++
+		return checks > 1
+	})
+	if err := job.Execute(context.Background()); err == nil {
+		t.Error("Expected the error of the attempt before the skipped retry")
+	}
+	if len(attempts) != 1 {
+		t.Errorf("Expected 1 attempt before the skipped retry, got %v", attempts)
+	}
+
+	// The run is skipped when it is checked after the start delay
+	attempts, checks = nil, 0
+	job.SetStartDelay(StartDelay{Offset: time.Millisecond})
+	if err := job.Execute(context.Background()); err != nil {
+		t.Errorf("Skipped run failed: %v", err)
+	}
+	if len(attempts) != 0 || checks != 2 {
+		t.Errorf("Expected no attempts after the delay and 2 checks, got %v and %d", attempts, checks)
+	}
+}
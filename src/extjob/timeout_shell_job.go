@@ -4,11 +4,14 @@ package extjob
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/reugn/go-quartz/quartz"
@@ -25,11 +28,22 @@ const (
 type ShellJob struct {
 	mtx        sync.Mutex
 	cmd        string
+	env        []string
+	user       string
+	group      string
+	limits     Limits
+	limitsHit  []string
+	timedOut   bool
+	retry      RetryPolicy
+	delay      StartDelay
+	acquire    func(ctx context.Context) (func(), time.Duration, error)
+	skip       func(ctx context.Context) bool
 	exitCode   int
 	stdout     string
 	stderr     string
 	jobStatus  Status
 	timeout    time.Duration
+	resolve    func(cmd string, env []string) (string, []string, error)
 	beforeExec func(context.Context, *ShellJob)
 	afterExec  func(context.Context, *ShellJob)
 }
@@ -58,6 +72,86 @@ func NewShellJobWithCallbacks(
 	}
 }
 
+// SetEnv sets "KEY=value" pairs added to the environment of
+// the program, call it before scheduling the job
+
+func (sh *ShellJob) SetEnv(env []string) {
+	sh.mtx.Lock()
+	defer sh.mtx.Unlock()
+	sh.env = env
+}
+
+// SetCredential sets the user and the group (names or ids) to run
+// the program as, call it before scheduling the job
+
+func (sh *ShellJob) SetCredential(user, group string) {
+	sh.mtx.Lock()
+	defer sh.mtx.Unlock()
+	sh.user, sh.group = user, group
+}
+
+// SetLimits sets limits of resources of the program,
+// call it before scheduling the job
+
+func (sh *ShellJob) SetLimits(limits Limits) {
+	sh.mtx.Lock()
+	defer sh.mtx.Unlock()
+	sh.limits = limits
+}
+
+// SetRetryPolicy sets retries of failed executions,
+// call it before scheduling the job
+
+func (sh *ShellJob) SetRetryPolicy(policy RetryPolicy) {
+	sh.mtx.Lock()
+	defer sh.mtx.Unlock()
+	sh.retry = policy
+}
+
+// SetStartDelay sets the delay of the start of executions,
+// call it before scheduling the job
+
+func (sh *ShellJob) SetStartDelay(delay StartDelay) {
+	sh.mtx.Lock()
+	defer sh.mtx.Unlock()
+	sh.delay = delay
+}
+
+// SetAcquire sets the function which waits for a slot of a
+// concurrency limit before every attempt (see Limiter.Acquire),
+// call it before scheduling the job
+
+func (sh *ShellJob) SetAcquire(
+	acquire func(ctx context.Context) (release func(), waited time.Duration, err error),
+) {
+	sh.mtx.Lock()
+	defer sh.mtx.Unlock()
+	sh.acquire = acquire
+}
+
+// SetSkip sets the function which reports whether the execution is
+// skipped (e.g. by a calendar), it is called when the job fires, after
+// the start delay and before every attempt, call it before scheduling
+// the job
+
+func (sh *ShellJob) SetSkip(skip func(ctx context.Context) bool) {
+	sh.mtx.Lock()
+	defer sh.mtx.Unlock()
+	sh.skip = skip
+}
+
+// SetResolver sets the function which returns the command and the env
+// to execute (e.g. with values of secrets), it is called on every
+// execution, so values are not kept in the job
+
+func (sh *ShellJob) SetResolver(
+	resolve func(cmd string, env []string) (string, []string, error),
+) {
+	sh.mtx.Lock()
+	defer sh.mtx.Unlock()
+	sh.resolve = resolve
+}
+
 func (sh *ShellJob) Description() string {
 	return fmt.Sprintf("ShellJob%s%s", quartz.Sep, sh.cmd)
 }
@@ -90,16 +184,70 @@ func getShell() (string, []string) {
 func (j *ShellJob) execute(ctx context.Context) error {
 	shell, args := getShell()
 
+	j.mtx.Lock()
+	command, env, resolve := j.cmd, j.env, j.resolve
+	userName, groupName, limits := j.user, j.group, j.limits
+	j.mtx.Unlock()
+
+	if resolve != nil {
+		var err error
+		if command, env, err = resolve(command, env); err != nil {
+			j.refuse(err)
+			return err
+		}
+	}
+
+	var lim *limitRun
+	if !limits.IsZero() {
+		var err error
+		if lim, err = prepareLimits(limits); err != nil {
+			j.refuse(err)
+			return err
+		}
+		command = lim.wrap(command)
+	}
+
 	var stdout, stderr bytes.Buffer
-	cmd := exec.CommandContext(ctx, shell, append(args, j.cmd)...)
+	cmd := exec.CommandContext(ctx, shell, append(args, command)...)
 	cmd.Stdout = io.Writer(&stdout)
 	cmd.Stderr = io.Writer(&stderr)
 
-	err := cmd.Run()
+	// Variables of the user go before the env of the job,
+	// so the job can override them
+	if userName != "" || groupName != "" {
+		userEnv, err := setCredential(cmd, userName, groupName)
+		if err != nil {
+			if lim != nil {
+				lim.finish(nil, "")
+			}
+			j.refuse(err)
+			return err
+		}
+		env = append(userEnv, env...)
+	}
+
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+
+	if lim != nil {
+		lim.setup(cmd)
+	}
+
+	err := cmd.Start()
+	if err == nil {
+		err = cmd.Wait()
+	}
+
+	var limitsHit []string
+	if lim != nil {
+		limitsHit = lim.finish(cmd.ProcessState, stderr.String())
+	}
 
 	j.mtx.Lock()
 	j.stdout, j.stderr = stdout.String(), stderr.String()
 	j.exitCode = cmd.ProcessState.ExitCode()
+	j.limitsHit = limitsHit
 
 	if err != nil {
 		j.jobStatus = StatusFailure
@@ -111,7 +259,212 @@ func (j *ShellJob) execute(ctx context.Context) error {
 	return err
 }
 
+// refuse finishes the run which is not started with the error
+
+func (j *ShellJob) refuse(err error) {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+	j.stdout, j.stderr = "", err.Error()
+	j.exitCode = -1
+	j.limitsHit = nil
+	j.timedOut = false
+	j.jobStatus = StatusFailure
+}
+
+// NOTE: Runs. Every execution of a job is a run with its own id
+// and cancel function, both are passed to callbacks in the context
+
+type runKey struct{}
+
+type runInfo struct {
+	id     uint64
+	cancel context.CancelFunc
+	// The context of the run is derived from it,
+	// the next attempt is a run derived from it too
+	parent context.Context
+	// Attempt of the execution from 1 and the number of attempts
+	attempt  int
+	attempts int
+	// Delay before the next attempt, -1 if there is no next attempt
+	retryIn time.Duration
+	// Delay of the start of the first attempt
+	startDelay time.Duration
+	// Time of waiting in the queue of concurrency limits
+	queued time.Duration
+}
+
+var lastRunID atomic.Uint64
+
+// NewRunContext starts a run, pass the context to Execute
+// to know the id of the run before it is started
+
+func NewRunContext(ctx context.Context) (context.Context, uint64) {
+	runCtx, cancel := context.WithCancel(ctx)
+	info := &runInfo{
+		id:       lastRunID.Add(1),
+		cancel:   cancel,
+		parent:   ctx,
+		attempt:  1,
+		attempts: 1,
+		retryIn:  -1,
+	}
+	return context.WithValue(runCtx, runKey{}, info), info.id
+}
+
+// RunID returns the id of the run, 0 if ctx is not a run context
+
+func RunID(ctx context.Context) uint64 {
+	if info, ok := ctx.Value(runKey{}).(*runInfo); ok {
+		return info.id
+	}
+	return 0
+}
+
+// RunCancel returns the function which stops the run
+
+func RunCancel(ctx context.Context) context.CancelFunc {
+	if info, ok := ctx.Value(runKey{}).(*runInfo); ok {
+		return info.cancel
+	}
+	return func() {}
+}
+
+// Attempt returns the attempt of the execution (from 1) and the
+// number of attempts, it is known in callbacks
+
+func Attempt(ctx context.Context) (int, int) {
+	if info, ok := ctx.Value(runKey{}).(*runInfo); ok {
+		return info.attempt, info.attempts
+	}
+	return 1, 1
+}
+
+// RetryIn returns the delay before the next attempt, false if the
+// failed attempt is not retried. It is known in the after callback
+
+func RetryIn(ctx context.Context) (time.Duration, bool) {
+	if info, ok := ctx.Value(runKey{}).(*runInfo); ok && info.retryIn >= 0 {
+		return info.retryIn, true
+	}
+	return 0, false
+}
+
+// StartDelayOf returns the delay of the start of the run,
+// it is known in callbacks of the first attempt
+
+func StartDelayOf(ctx context.Context) time.Duration {
+	if info, ok := ctx.Value(runKey{}).(*runInfo); ok && info.attempt == 1 {
+		return info.startDelay
+	}
+	return 0
+}
+
+// Queued returns the time the attempt waited in the queue of
+// concurrency limits, it is known in callbacks
+
+func Queued(ctx context.Context) time.Duration {
+	if info, ok := ctx.Value(runKey{}).(*runInfo); ok {
+		return info.queued
+	}
+	return 0
+}
+
 func (j *ShellJob) Execute(ctx context.Context) error {
+	if RunID(ctx) == 0 {
+		ctx, _ = NewRunContext(ctx)
+	}
+	parent := ctx.Value(runKey{}).(*runInfo).parent
+
+	j.mtx.Lock()
+	policy, delay, acquire, skip := j.retry, j.delay, j.acquire, j.skip
+	j.mtx.Unlock()
+
+	// A skipped run is not started (callbacks are not called). It is
+	// checked when the job fires, after the delay and before retries,
+	// so e.g. a pause during the delay skips the run
+	skipped := func() bool {
+		if skip == nil || !skip(ctx) {
+			return false
+		}
+		RunCancel(ctx)()
+		return true
+	}
+
+	// The run is not started (callbacks are not called) during the delay
+	if d := delay.Next(); d > 0 {
+		if skipped() {
+			return nil
+		}
+		ctx.Value(runKey{}).(*runInfo).startDelay = d
+
+		timer := time.NewTimer(d)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			RunCancel(ctx)()
+			return ctx.Err()
+		}
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		// The error of the previous attempt is the result of a
+		// skipped retry
+		if skipped() {
+			return err
+		}
+
+		info := ctx.Value(runKey{}).(*runInfo)
+		info.attempt, info.attempts = attempt, policy.MaxRetries+1
+
+		// A run which is not given a slot is not started
+		release := func() {}
+		if acquire != nil {
+			var acquireErr error
+			if release, info.queued, acquireErr = acquire(ctx); acquireErr != nil {
+				RunCancel(ctx)()
+				return acquireErr
+			}
+		}
+
+		err = j.executeAttempt(ctx, func() {
+			// Canceled runs and runs stopped by shutdown are not retried
+			if attempt > policy.MaxRetries || ctx.Err() != nil {
+				return
+			}
+			j.mtx.Lock()
+			failed := j.jobStatus == StatusFailure
+			exitCode, timedOut := j.exitCode, j.timedOut
+			j.mtx.Unlock()
+
+			if failed && policy.ShouldRetry(exitCode, timedOut) {
+				info.retryIn = policy.Delay(attempt)
+			}
+		})
+		release()
+		RunCancel(ctx)()
+
+		if err == nil || info.retryIn < 0 {
+			return err
+		}
+
+		timer := time.NewTimer(info.retryIn)
+		select {
+		case <-timer.C:
+		case <-parent.Done():
+			timer.Stop()
+			return err
+		}
+
+		ctx, _ = NewRunContext(parent)
+	}
+}
+
+// executeAttempt runs the program once, decide is called before the
+// after callback to set the delay of the next attempt
+
+func (j *ShellJob) executeAttempt(ctx context.Context, decide func()) error {
 	if j.beforeExec != nil {
 		j.beforeExec(ctx, j)
 	}
@@ -123,8 +476,15 @@ func (j *ShellJob) Execute(ctx context.Context) error {
 		timeoutCtx, cancel := context.WithTimeout(ctx, j.timeout)
 		defer cancel()
 		err = j.execute(timeoutCtx)
+
+		timedOut := errors.Is(timeoutCtx.Err(), context.DeadlineExceeded)
+		j.mtx.Lock()
+		j.timedOut = timedOut
+		j.mtx.Unlock()
 	}
 
+	decide()
+
 	if j.afterExec != nil {
 		j.afterExec(ctx, j)
 	}
@@ -150,6 +510,15 @@ func (sh *ShellJob) Stderr() string {
 	return sh.stderr
 }
 
+// LimitsHit returns limits which were hit by the last execution:
+// memory, cpu, open_files, cgroup_memory, cgroup_cpu
+
+func (sh *ShellJob) LimitsHit() []string {
+	sh.mtx.Lock()
+	defer sh.mtx.Unlock()
+	return sh.limitsHit
+}
+
 func (sh *ShellJob) JobStatus() Status {
 	sh.mtx.Lock()
 	defer sh.mtx.Unlock()
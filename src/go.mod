@@ -4,7 +4,10 @@ go 1.25.1
 
 require (
 	github.com/jessevdk/go-flags v1.6.1
+	github.com/pelletier/go-toml/v2 v2.2.4
 	github.com/reugn/go-quartz v0.15.2
+	go.etcd.io/bbolt v1.4.3
+	gopkg.in/yaml.v3 v3.0.1
 )
 
-require golang.org/x/sys v0.21.0 // indirect
+require golang.org/x/sys v0.29.0 // indirect
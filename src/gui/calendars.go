@@ -0,0 +1,193 @@
+package gui
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"cronshroom/calendars"
+	"cronshroom/storage"
+)
+
+// NOTE: Calendars which suppress or allow scheduled runs of jobs
+
+func listCalendars(
+	logger *slog.Logger,
+	db *storage.Database,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store := db.Calendars()
+		if store == nil {
+			http.Error(w, storage.ErrNoCalendars.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		if err := json.NewEncoder(w).Encode(store.List()); err != nil {
+			logger.Error("Failed to encode calendars to JSON", "error", err)
+			return
+		}
+	}
+}
+
+func setCalendar(
+	logger *slog.Logger,
+	db *storage.Database,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store := db.Calendars()
+		if store == nil {
+			http.Error(w, storage.ErrNoCalendars.Error(), http.StatusNotFound)
+			return
+		}
+
+		var req struct {
+			Name     string              `json:"name"`
+			Calendar *calendars.Calendar `json:"calendar"`
+		}
+
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			logger.Error("Error decode setCalendar json data", "error", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		defer func() {
+			if err = r.Body.Close(); err != nil {
+				logger.Error("Failed to close request body", "error", err)
+			}
+		}()
+
+		if req.Calendar == nil {
+			http.Error(w, "calendar is empty", http.StatusBadRequest)
+			return
+		}
+
+		saveCalendar(w, logger, store, req.Name, req.Calendar)
+	}
+}
+
+// importCalendar creates or replaces the calendar (name and description
+// are parameters) with events of the .ics file in the request body
+
+func importCalendar(
+	logger *slog.Logger,
+	db *storage.Database,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store := db.Calendars()
+		if store == nil {
+			http.Error(w, storage.ErrNoCalendars.Error(), http.StatusNotFound)
+			return
+		}
+
+		defer func() {
+			if err := r.Body.Close(); err != nil {
+				logger.Error("Failed to close request body", "error", err)
+			}
+		}()
+
+		name := r.URL.Query().Get("name")
+		c, skipped, err := calendars.ParseICS(r.Body)
+		if err != nil {
+			logger.Error("Failed to import calendar",
+				"calendar", name,
+				"error", err,
+			)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c.Description = r.URL.Query().Get("description")
+
+		if !saveCalendar(w, logger, store, name, c) {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(map[string]int{
+			"dates":   len(c.Dates),
+			"periods": len(c.Periods),
+			"skipped": skipped,
+		})
+		if err != nil {
+			logger.Error("Failed to encode import result to JSON", "error", err)
+			return
+		}
+	}
+}
+
+func saveCalendar(
+	w http.ResponseWriter,
+	logger *slog.Logger,
+	store *calendars.Store,
+	name string,
+	c *calendars.Calendar,
+) bool {
+	if err := calendars.ValidateName(name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	if err := c.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+
+	if err := store.Set(name, c); err != nil {
+		logger.Error("Failed to set calendar",
+			"calendar", name,
+			"error", err,
+		)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	logger.Info("Calendar is set", "calendar", name)
+	return true
+}
+
+func deleteCalendar(
+	logger *slog.Logger,
+	db *storage.Database,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store := db.Calendars()
+		if store == nil {
+			http.Error(w, storage.ErrNoCalendars.Error(), http.StatusNotFound)
+			return
+		}
+
+		var req struct {
+			Name string `json:"name"`
+		}
+
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			logger.Error("Error decode deleteCalendar json data", "error", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		defer func() {
+			if err = r.Body.Close(); err != nil {
+				logger.Error("Failed to close request body", "error", err)
+			}
+		}()
+
+		if err := store.Delete(req.Name); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, calendars.ErrCalendarNotFound) {
+				status = http.StatusNotFound
+			} else {
+				logger.Error("Failed to delete calendar",
+					"calendar", req.Name,
+					"error", err,
+				)
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		logger.Info("Calendar is deleted", "calendar", req.Name)
+	}
+}
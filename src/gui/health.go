@@ -0,0 +1,48 @@
+package gui
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"cronshroom/utils"
+)
+
+// NOTE: Liveness and readiness probes
+
+func healthz(
+	logger *slog.Logger,
+	health *utils.Health,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sendHealthReport(w, logger, health.Liveness())
+	}
+}
+
+func readyz(
+	logger *slog.Logger,
+	health *utils.Health,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sendHealthReport(w, logger, health.Readiness())
+	}
+}
+
+func sendHealthReport(
+	w http.ResponseWriter,
+	logger *slog.Logger,
+	report utils.HealthReport,
+) {
+	status := http.StatusOK
+	if !report.OK {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		logger.Error("Failed to encode health report to JSON", "error", err)
+		return
+	}
+}
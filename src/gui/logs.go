@@ -0,0 +1,92 @@
+package gui
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"cronshroom/logstore"
+)
+
+// queryLogs searches the log store. Parameters: level (minimal),
+// since and until (RFC 3339), job, q (substring of the message),
+// regex (of the message), limit, cursor (next_cursor of the
+// previous page). Entries are returned from the newest
+
+func queryLogs(
+	logger *slog.Logger,
+	logStore *logstore.Store,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if logStore == nil {
+			http.Error(w, "log store is disabled", http.StatusNotFound)
+			return
+		}
+
+		q, err := parseLogQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		page, err := logStore.Query(q)
+		if err != nil {
+			logger.Error("Failed to query logs", "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		if err := json.NewEncoder(w).Encode(page); err != nil {
+			logger.Error("Failed to encode logs to JSON", "error", err)
+			return
+		}
+	}
+}
+
+func parseLogQuery(r *http.Request) (logstore.Query, error) {
+	params := r.URL.Query()
+	q := logstore.Query{
+		Level: slog.LevelDebug,
+		Job:   params.Get("job"),
+		Text:  params.Get("q"),
+	}
+
+	var err error
+	if v := params.Get("level"); v != "" {
+		if err := q.Level.UnmarshalText([]byte(v)); err != nil {
+			return q, err
+		}
+	}
+	if v := params.Get("since"); v != "" {
+		if q.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			return q, err
+		}
+	}
+	if v := params.Get("until"); v != "" {
+		if q.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			return q, err
+		}
+	}
+	if v := params.Get("regex"); v != "" {
+		if q.Regex, err = regexp.Compile(v); err != nil {
+			return q, err
+		}
+	}
+	if v := params.Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil {
+			return q, err
+		}
+	}
+	if v := params.Get("cursor"); v != "" {
+		if q.Cursor, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return q, err
+		}
+	}
+
+	return q, nil
+}
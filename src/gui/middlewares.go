@@ -28,3 +28,18 @@ func logReqMiddleware(logger *slog.Logger) middleware {
 		})
 	}
 }
+
+// readOnlyMiddleware allows only requests which do not change anything
+
+func readOnlyMiddleware() middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				w.Header().Set("Allow", "GET, HEAD")
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package gui
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"cronshroom/storage"
+)
+
+// NOTE: Pause of the scheduler (maintenance mode)
+
+// pauseScheduler pauses the scheduler until the time (RFC 3339) or for
+// the number of seconds, until it is resumed if both are empty
+
+func pauseScheduler(
+	logger *slog.Logger,
+	db *storage.Database,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Until         string `json:"until"`
+			Duration      uint   `json:"duration"`
+			Reason        string `json:"reason"`
+			CancelRunning bool   `json:"cancelRunning"`
+		}
+
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			logger.Error("Error decode pauseScheduler json data", "error", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		defer func() {
+			if err = r.Body.Close(); err != nil {
+				logger.Error("Failed to close request body", "error", err)
+			}
+		}()
+
+		var until time.Time
+		switch {
+		case req.Until != "" && req.Duration > 0:
+			http.Error(w, "until and duration are mutually exclusive", http.StatusBadRequest)
+			return
+		case req.Until != "":
+			until, err = time.Parse(time.RFC3339, req.Until)
+			if err != nil {
+				http.Error(w, "invalid until: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		case req.Duration > 0:
+			until = time.Now().Add(time.Duration(req.Duration) * time.Second)
+		}
+
+		canceled, err := db.Pause(until, req.Reason, req.CancelRunning, logger)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]int{"canceled": canceled}); err != nil {
+			logger.Error("Failed to encode pause result to JSON", "error", err)
+			return
+		}
+	}
+}
+
+func resumeScheduler(
+	logger *slog.Logger,
+	db *storage.Database,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !db.Resume(logger) {
+			http.Error(w, "scheduler is not paused", http.StatusConflict)
+			return
+		}
+	}
+}
+
+func getPause(
+	logger *slog.Logger,
+	db *storage.Database,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		if err := json.NewEncoder(w).Encode(db.PauseState()); err != nil {
+			logger.Error("Failed to encode pause state to JSON", "error", err)
+			return
+		}
+	}
+}
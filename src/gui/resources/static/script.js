@@ -195,11 +195,96 @@ class JobsTable {
                 <td>${job.config.timeout}</td>
                 <td>${job.config.max_retries}</td>
                 <td>${job.config.retry_interval}</td>
+                <td>${this.getStartDelay(job.config)}</td>
+                <td>${this.getCalendarsHTML(job.config)}</td>
             `;
             tbody.appendChild(row);
         });
     }
 
+    // Concurrency limits, hidden if they are not configured
+    updateQueue(queue) {
+        const element = document.getElementById('queueStats');
+        if (!queue) {
+            element.style.display = 'none';
+            return;
+        }
+
+        const parts = [`Running: ${queue.running}/${queue.limit || '∞'}`];
+        queue.pools.forEach(pool => {
+            parts.push(`${pool.name}: ${pool.running}/${pool.size}` + (pool.queued ? ` (${pool.queued} queued)` : ''));
+        });
+        const queued = queue.queued.map(run => run.job);
+        parts.push(`Queued: ${queued.length ? queued.join(', ') : '-'}`);
+
+        element.textContent = parts.join(' • ');
+        element.style.display = 'block';
+    }
+
+    // Maintenance mode, scheduled runs are skipped while it is shown
+    updatePause(state) {
+        this.pauseState = state;
+        const banner = document.getElementById('pauseBanner');
+        document.getElementById('pauseBtn').textContent = state.paused ? 'Resume' : 'Pause';
+        if (!state.paused) {
+            banner.style.display = 'none';
+            return;
+        }
+
+        const since = DateFormatter.format(Date.parse(state.since) / 1000);
+        const until = state.until ? `until ${DateFormatter.format(Date.parse(state.until) / 1000)}` : 'until it is resumed';
+        let text = `Scheduler is paused since ${since} ${until}, scheduled runs are skipped`;
+        if (state.reason) text += ` • ${state.reason}`;
+        banner.textContent = text;
+        banner.style.display = 'block';
+    }
+
+    togglePause() {
+        if (this.pauseState?.paused) {
+            ApiClient.sendJSON({}, "/api/resume")
+                .then(() => this.updatePause({ paused: false }))
+                .catch(err => console.error("Failed to resume scheduler:", err));
+            return;
+        }
+
+        const minutes = prompt("Pause the scheduler for minutes (empty - until it is resumed):", "");
+        if (minutes === null) return;
+        const duration = minutes.trim() ? Math.round(parseFloat(minutes) * 60) : 0;
+        if (isNaN(duration) || duration < 0) {
+            alert("Invalid number of minutes");
+            return;
+        }
+        const reason = prompt("Reason of the pause:", "") || "";
+        const cancelRunning = confirm("Cancel running runs? (Cancel - they finish)");
+
+        ApiClient.sendJSON({ duration, reason, cancelRunning }, "/api/pause")
+            .then(() => ApiClient.receiveJSON("/api/get_pause"))
+            .then(state => this.updatePause(state))
+            .catch(err => console.error("Failed to pause scheduler:", err));
+    }
+
+    // Calendars which skip runs now are highlighted
+    getCalendarsHTML(config) {
+        const active = this.activeCalendars || new Set();
+        const parts = [];
+        (config.exclude_calendars || []).forEach(name => {
+            const text = `not ${name}`;
+            parts.push(active.has(name) ? `<span style="color: #FFCA29"><b>${text}</b></span>` : text);
+        });
+        (config.only_calendars || []).forEach(name => {
+            const text = `only ${name}`;
+            parts.push(active.has(name) ? text : `<span style="color: #FFCA29"><b>${text}</b></span>`);
+        });
+        return parts.length ? parts.join(', ') : '-';
+    }
+
+    getStartDelay(config) {
+        const parts = [];
+        if (config.start_spread) parts.push(`spread ${config.start_spread}s`);
+        if (config.start_jitter) parts.push(`jitter ${config.start_jitter}s`);
+        return parts.length ? parts.join(', ') : '-';
+    }
+
     getStatusHTML(status) {
         switch(status) {
             case "D": return `<span style="color: #939393;"><b>${status}</b></span>`;
@@ -232,6 +317,22 @@ class JobsTable {
                     console.error('Error loading database:', error);
                     this.showError('Failed to load jobs data');
                 });
+            ApiClient.receiveJSON("/api/list_calendars")
+                .then(calendars => {
+                    this.activeCalendars = new Set(calendars.filter(c => c.active).map(c => c.name));
+                })
+                .catch(() => { this.activeCalendars = null; });
+            ApiClient.receiveJSON("/api/get_pause")
+                .then(state => this.updatePause(state))
+                .catch(() => {});
+            // Not found - limits are not configured, it is not asked again
+            if (this.queueDisabled) return;
+            ApiClient.receiveJSON("/api/get_queue")
+                .then(queue => this.updateQueue(queue))
+                .catch(() => {
+                    this.queueDisabled = true;
+                    this.updateQueue(null);
+                });
         };
 
         refresh();
@@ -291,6 +392,17 @@ class ManageJobModal extends Modal {
                 });
         } catch (e) {}
     }
+
+    // The last lines of the job log file in a new tab or the whole file as a download
+    showLog(download) {
+        try {
+            const name = encodeURIComponent(this.getJobName());
+            const url = download
+                ? `/api/job_log?name=${name}&download=1`
+                : `/api/job_log?name=${name}&lines=500`;
+            window.open(url, "_blank");
+        } catch (e) {}
+    }
 }
 
 class SetJobModal extends Modal {
@@ -338,6 +450,42 @@ class SetJobModal extends Modal {
         this.updateCronDescription(cronInput.value);
     }
 
+    splitNames(value) {
+        return (value || '').split(',').map(name => name.trim()).filter(name => name);
+    }
+
+    // null if every field is empty, so the job has no limits
+    limits(formData) {
+        const limits = {
+            memory: parseInt(formData.get('limitMemory')) || 0,
+            cpu: parseInt(formData.get('limitCPU')) || 0,
+            open_files: parseInt(formData.get('limitOpenFiles')) || 0,
+            nice: parseInt(formData.get('limitNice')) || 0,
+            cgroup: (formData.get('limitCgroup') || '').trim(),
+            cgroup_memory_max: (formData.get('limitCgroupMemoryMax') || '').trim(),
+            cgroup_cpu_max: (formData.get('limitCgroupCPUMax') || '').trim()
+        };
+        return Object.values(limits).some(v => v) ? limits : null;
+    }
+
+    splitCodes(value) {
+        return this.splitNames(value).map(code => parseInt(code)).filter(code => !isNaN(code));
+    }
+
+    // null if every field is default, so any failure is retried every retry interval
+    retry(formData) {
+        const retry = {
+            backoff: formData.get('retryBackoff') === 'exponential' ? 'exponential' : '',
+            max_interval: parseInt(formData.get('retryMaxInterval')) || 0,
+            jitter: parseFloat(formData.get('retryJitter')) || 0,
+            exit_codes: this.splitCodes(formData.get('retryExitCodes')),
+            skip_exit_codes: this.splitCodes(formData.get('retrySkipExitCodes')),
+            skip_timeout: formData.get('retrySkipTimeout') !== null
+        };
+        const set = Object.values(retry).some(v => Array.isArray(v) ? v.length : v);
+        return set ? retry : null;
+    }
+
     attachSubmitHandler() {
         document.getElementById('setJobForm').addEventListener('submit', (e) => {
             e.preventDefault();
@@ -349,7 +497,21 @@ class SetJobModal extends Modal {
                 cron: formData.get('cron'),
                 timeout: parseInt(formData.get('timeout')),
                 maxRetries: parseInt(formData.get('maxRetries')),
-                retryInterval: parseInt(formData.get('retryInterval'))
+                retryInterval: parseInt(formData.get('retryInterval')),
+                env: formData.get('env'),
+                user: formData.get('user'),
+                group: formData.get('group'),
+                limits: this.limits(formData),
+                retry: this.retry(formData),
+                misfirePolicy: formData.get('misfirePolicy'),
+                misfireMaxRuns: parseInt(formData.get('misfireMaxRuns')) || 0,
+                startSpread: parseInt(formData.get('startSpread')) || 0,
+                startJitter: parseInt(formData.get('startJitter')) || 0,
+                excludeCalendars: this.splitNames(formData.get('excludeCalendars')),
+                onlyCalendars: this.splitNames(formData.get('onlyCalendars')),
+                pool: (formData.get('pool') || '').trim(),
+                priority: parseInt(formData.get('priority')) || 0,
+                queueMaxWait: parseInt(formData.get('queueMaxWait')) || 0
             };
 
             ApiClient.sendJSON(jobData, "/api/change_job")
@@ -361,12 +523,131 @@ class SetJobModal extends Modal {
     }
 }
 
+class ImportExportModal extends Modal {
+    constructor() {
+        super('importExportModal');
+        this.form = document.getElementById('importExportForm');
+        this.diff = document.getElementById('importDiff');
+    }
+
+    open() {
+        super.open();
+        this.diff.style.display = 'none';
+        this.diff.textContent = '';
+    }
+
+    showDiff(text) {
+        this.diff.style.display = 'block';
+        this.diff.textContent = text;
+    }
+
+    exportJobs() {
+        const format = this.form.elements['format'].value;
+        window.location = `/api/export_jobs?format=${encodeURIComponent(format)}`;
+    }
+
+    importJobs(dryRun) {
+        const file = this.form.elements['file'].files[0];
+        if (!file) {
+            this.showDiff('Choose a file to import');
+            return;
+        }
+
+        const format = this.form.elements['format'].value;
+        const mode = this.form.elements['mode'].value;
+        const params = new URLSearchParams({ format, mode, dry_run: dryRun });
+
+        file.text()
+            .then(text => fetch(`/api/import_jobs?${params}`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'text/plain' },
+                body: text
+            }))
+            .then(response => response.ok
+                ? response.json().then(plan => this.showDiff((dryRun ? '' : 'Imported:\n') + plan.diff))
+                : response.text().then(text => this.showDiff(`Error: ${text}`)))
+            .catch(err => {
+                console.error("Failed to import jobs:", err);
+                this.showDiff(`Error: ${err.message}`);
+            });
+    }
+}
+
+// Search in the log store, pages are loaded from the newest
+
+class LogHistoryModal extends Modal {
+    constructor() {
+        super('logHistoryModal');
+        this.form = document.getElementById('logHistoryForm');
+        this.content = document.getElementById('logHistoryContent');
+        this.olderBtn = document.getElementById('logHistoryOlderBtn');
+        this.cursor = 0;
+
+        this.form.addEventListener('submit', (e) => {
+            e.preventDefault();
+            this.search(false);
+        });
+    }
+
+    open() {
+        super.open();
+        this.search(false);
+    }
+
+    params() {
+        const params = new URLSearchParams();
+        for (const name of ['level', 'job', 'q', 'regex']) {
+            const value = this.form.elements[name].value.trim();
+            if (value) params.set(name, value);
+        }
+        for (const name of ['since', 'until']) {
+            const value = this.form.elements[name].value;
+            if (value) params.set(name, new Date(value).toISOString().replace(/\.\d+Z$/, 'Z'));
+        }
+        return params;
+    }
+
+    search(older) {
+        const params = this.params();
+        if (older) {
+            if (!this.cursor) return;
+            params.set('cursor', this.cursor);
+        }
+
+        fetch(`/api/logs?${params}`)
+            .then(response => response.ok
+                ? response.json()
+                : response.text().then(text => { throw new Error(text.trim()); }))
+            .then(page => {
+                const text = page.entries.map(entry => {
+                    const attrs = entry.attrs ? ' ' + JSON.stringify(entry.attrs) : '';
+                    return `${entry.time} — ${entry.level} — ${entry.message}${attrs}`;
+                }).join('\n\n');
+
+                if (older) {
+                    this.content.textContent += text ? '\n\n' + text : '';
+                } else {
+                    this.content.textContent = text || 'No matching logs found';
+                    this.content.scrollTop = 0;
+                }
+                this.cursor = page.next_cursor || 0;
+                this.olderBtn.disabled = !this.cursor;
+            })
+            .catch(err => {
+                console.error("Failed to search logs:", err);
+                this.content.textContent = `Error: ${err.message}`;
+            });
+    }
+}
+
 class App {
     constructor() {
         this.jobsTable = new JobsTable();
         this.logsModal = new LogsModal();
         this.manageJobModal = new ManageJobModal();
         this.setJobModal = new SetJobModal();
+        this.importExportModal = new ImportExportModal();
+        this.logHistoryModal = new LogHistoryModal();
 
         this.setJobModal.attachSubmitHandler();
         this.attachGlobalEventListeners();
@@ -385,6 +666,8 @@ class App {
                 if (this.logsModal.modal.style.display === 'block') this.logsModal.close();
                 else if (this.setJobModal.modal.style.display === 'block') this.setJobModal.close();
                 else if (this.manageJobModal.modal.style.display === 'block') this.manageJobModal.close();
+                else if (this.importExportModal.modal.style.display === 'block') this.importExportModal.close();
+                else if (this.logHistoryModal.modal.style.display === 'block') this.logHistoryModal.close();
             }
         });
     }
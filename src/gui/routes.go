@@ -3,11 +3,20 @@ package gui
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"html/template"
+	"io"
+	"io/fs"
 	"log/slog"
+	"mime"
 	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
+	"cronshroom/exchange"
 	"cronshroom/storage"
 	"cronshroom/utils"
 )
@@ -29,8 +38,8 @@ func lastLog(
 			Attrs   map[string]any `json:"attrs,omitempty"`
 		}
 
-		if bh, ok := logger.Handler().(*utils.SlogBufferedHandler); ok {
-			webLogMaxEntries := logger.Handler().(*utils.SlogBufferedHandler).MaxRecords
+		if bh, ok := utils.FindBufferedHandler(logger.Handler()); ok {
+			webLogMaxEntries := bh.MaxRecords
 
 			records := bh.GetLastRecords(webLogMaxEntries)
 			entries := make([]LogEntry, len(records))
@@ -85,6 +94,7 @@ func execJob(
 		err := json.NewDecoder(r.Body).Decode(&req)
 		if err != nil {
 			logger.Error("Error decode execJob json data", "error", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
@@ -94,7 +104,19 @@ func execJob(
 			}
 		}()
 
-		db.ExecJob(req.Name, ctx, logger)
+		runID, err := db.ExecJob(req.Name, ctx, logger)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]uint64{
+			"run_id": runID,
+		}); err != nil {
+			logger.Error("Failed to encode run id to JSON", "error", err)
+			return
+		}
 	}
 }
 
@@ -110,6 +132,7 @@ func toggleJob(
 		err := json.NewDecoder(r.Body).Decode(&req)
 		if err != nil {
 			logger.Error("Error decode toggleJob json data", "error", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
@@ -119,7 +142,10 @@ func toggleJob(
 			}
 		}()
 
-		db.ToggleJob(req.Name)
+		if err := db.ToggleJob(req.Name); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
 	}
 }
 
@@ -135,6 +161,7 @@ func deleteJob(
 		err := json.NewDecoder(r.Body).Decode(&req)
 		if err != nil {
 			logger.Error("Error decode deleteJob json data", "error", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
@@ -144,7 +171,10 @@ func deleteJob(
 			}
 		}()
 
-		db.DeleteJob(req.Name)
+		if err := db.DeleteJob(req.Name); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
 	}
 }
 
@@ -161,11 +191,34 @@ func changeJob(
 			Timeout       uint   `json:"timeout"`
 			MaxRetries    uint   `json:"maxRetries"`
 			RetryInterval uint   `json:"retryInterval"`
+			// One VAR=value per line
+			Env string `json:"env"`
+			// Empty - the user of the program
+			User  string `json:"user"`
+			Group string `json:"group"`
+			// nil - no limits
+			Limits *storage.JobLimits `json:"limits"`
+			// nil - retries of any failure every RetryInterval seconds
+			Retry *storage.RetryConfig `json:"retry"`
+			// ignore (default), run_once or run_all
+			MisfirePolicy  string `json:"misfirePolicy"`
+			MisfireMaxRuns uint   `json:"misfireMaxRuns"`
+			// Seconds of the window of the stable offset and of the random delay
+			StartSpread uint `json:"startSpread"`
+			StartJitter uint `json:"startJitter"`
+			// Concurrency pool, empty - only the global limit
+			Pool         string `json:"pool"`
+			Priority     int    `json:"priority"`
+			QueueMaxWait uint   `json:"queueMaxWait"`
+			// Names of calendars
+			ExcludeCalendars []string `json:"excludeCalendars"`
+			OnlyCalendars    []string `json:"onlyCalendars"`
 		}
 
 		err := json.NewDecoder(r.Body).Decode(&req)
 		if err != nil {
 			logger.Error("Error decode changeJob json data", "error", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
@@ -185,6 +238,39 @@ func changeJob(
 		)
 		if err != nil {
 			logger.Error("Create job error", "error", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		j.Config.Env, err = parseEnv(req.Env)
+		if err != nil {
+			logger.Error("Create job error", "error", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		j.Config.User = strings.TrimSpace(req.User)
+		j.Config.Group = strings.TrimSpace(req.Group)
+		j.Config.Limits = req.Limits
+		j.Config.Retry = req.Retry
+		j.Config.MisfirePolicy = req.MisfirePolicy
+		j.Config.MisfireMaxRuns = req.MisfireMaxRuns
+		j.Config.StartSpread = req.StartSpread
+		j.Config.StartJitter = req.StartJitter
+		j.Config.Pool = req.Pool
+		j.Config.Priority = req.Priority
+		j.Config.QueueMaxWait = req.QueueMaxWait
+		j.Config.ExcludeCalendars = req.ExcludeCalendars
+		j.Config.OnlyCalendars = req.OnlyCalendars
+
+		if err := storage.ValidateJob(req.Name, j); err != nil {
+			logger.Error("Create job error", "error", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := db.CheckCalendars(&j.Config); err != nil {
+			logger.Error("Create job error", "name", req.Name, "error", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
@@ -192,6 +278,229 @@ func changeJob(
 	}
 }
 
+// parseEnv parses VAR=value lines, empty lines and # comments are skipped
+
+func parseEnv(text string) (map[string]string, error) {
+	env := map[string]string{}
+	for line := range strings.Lines(text) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		k, v, found := strings.Cut(line, "=")
+		k = strings.TrimSpace(k)
+		if !found || k == "" || strings.ContainsAny(k, " \t") {
+			return nil, fmt.Errorf("invalid env line: %s", line)
+		}
+		env[k] = v
+	}
+
+	if len(env) == 0 {
+		return nil, nil
+	}
+	return env, nil
+}
+
+func getJob(
+	logger *slog.Logger,
+	db *storage.Database,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("name")
+
+		db.Mu.RLock()
+		j, exists := db.Jobs[name]
+		var data []byte
+		var err error
+		if exists {
+			data, err = json.Marshal(j)
+		}
+		db.Mu.RUnlock()
+
+		if !exists {
+			http.Error(w, storage.ErrJobNotFound.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to encode job to JSON", "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write(data); err != nil {
+			logger.Error("Failed to send job", "error", err)
+			return
+		}
+	}
+}
+
+// jobLog sends the log file of the job: the last "lines" lines (the
+// whole file if it is 0 or not set) or the part after "offset" for
+// following. X-Log-Size is the size of the file for the next offset
+
+func jobLog(
+	logger *slog.Logger,
+	db *storage.Database,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobLogs := db.JobLogs()
+		if jobLogs == nil {
+			http.Error(w, "log files of jobs are disabled", http.StatusNotFound)
+			return
+		}
+
+		query := r.URL.Query()
+		name := query.Get("name")
+		if name == "" {
+			http.Error(w, "job name is empty", http.StatusBadRequest)
+			return
+		}
+
+		var data []byte
+		var size int64
+		var err error
+		if query.Has("offset") {
+			offset, parseErr := strconv.ParseInt(query.Get("offset"), 10, 64)
+			if parseErr != nil || offset < 0 {
+				http.Error(w, "invalid offset", http.StatusBadRequest)
+				return
+			}
+			data, size, err = jobLogs.ReadFrom(name, offset)
+		} else {
+			lines := 0
+			if query.Has("lines") {
+				lines, err = strconv.Atoi(query.Get("lines"))
+				if err != nil || lines < 0 {
+					http.Error(w, "invalid number of lines", http.StatusBadRequest)
+					return
+				}
+			}
+			data, size, err = jobLogs.Tail(name, lines)
+		}
+		if errors.Is(err, fs.ErrNotExist) {
+			http.Error(w, "job has no log", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to read job log", "name", name, "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Log-Size", strconv.FormatInt(size, 10))
+		if query.Get("download") == "1" {
+			w.Header().Set("Content-Disposition", mime.FormatMediaType(
+				"attachment",
+				map[string]string{"filename": filepath.Base(jobLogs.Path(name))},
+			))
+		}
+		if _, err := w.Write(data); err != nil {
+			logger.Error("Failed to send job log", "error", err)
+			return
+		}
+	}
+}
+
+func listRuns(
+	logger *slog.Logger,
+	db *storage.Database,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		runs := db.Runs(r.URL.Query().Get("job"))
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(runs); err != nil {
+			logger.Error("Failed to encode runs to JSON", "error", err)
+			return
+		}
+	}
+}
+
+// getQueue sends the state of concurrency limits: slots of the
+// global limit and of pools, runs waiting in the queue
+
+func getQueue(
+	logger *slog.Logger,
+	db *storage.Database,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		queue, enabled := db.Queue()
+		if !enabled {
+			http.Error(w, "concurrency limits are not configured", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(queue); err != nil {
+			logger.Error("Failed to encode queue to JSON", "error", err)
+			return
+		}
+	}
+}
+
+func getRun(
+	logger *slog.Logger,
+	db *storage.Database,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid run id", http.StatusBadRequest)
+			return
+		}
+
+		run, err := db.GetRun(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(run); err != nil {
+			logger.Error("Failed to encode run to JSON", "error", err)
+			return
+		}
+	}
+}
+
+func cancelRun(
+	logger *slog.Logger,
+	db *storage.Database,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID uint64 `json:"id"`
+		}
+
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			logger.Error("Error decode cancelRun json data", "error", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		defer func() {
+			if err = r.Body.Close(); err != nil {
+				logger.Error("Failed to close request body", "error", err)
+			}
+		}()
+
+		if err := db.CancelRun(req.ID); err != nil {
+			status := http.StatusConflict
+			if errors.Is(err, storage.ErrRunNotFound) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		logger.Info("Run is canceled", "run_id", req.ID)
+	}
+}
+
 func sendDatabase(
 	logger *slog.Logger,
 	db *storage.Database,
@@ -213,6 +522,202 @@ func sendDatabase(
 	}
 }
 
+func listBackups(
+	logger *slog.Logger,
+	backups *storage.Backups,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		snapshots, err := backups.List()
+		if err != nil {
+			logger.Error("Failed to list database snapshots", "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(snapshots); err != nil {
+			logger.Error("Failed to encode database snapshots to JSON",
+				"error", err,
+			)
+			return
+		}
+	}
+}
+
+func createBackup(
+	logger *slog.Logger,
+	backups *storage.Backups,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		snapshot, err := backups.Create()
+		if err != nil {
+			logger.Error("Failed to create database snapshot", "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		logger.Info("Database snapshot is created", "snapshot", snapshot.Name)
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(snapshot); err != nil {
+			logger.Error("Failed to encode database snapshot to JSON",
+				"error", err,
+			)
+			return
+		}
+	}
+}
+
+func restoreBackup(
+	logger *slog.Logger,
+	db *storage.Database,
+	backups *storage.Backups,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Name string `json:"name"`
+		}
+
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			logger.Error("Error decode restoreBackup json data", "error", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		defer func() {
+			if err = r.Body.Close(); err != nil {
+				logger.Error("Failed to close request body", "error", err)
+			}
+		}()
+
+		restored, err := backups.Load(req.Name)
+		if err != nil {
+			logger.Error("Failed to load database snapshot",
+				"snapshot", req.Name,
+				"error", err,
+			)
+			status := http.StatusInternalServerError
+			if errors.Is(err, storage.ErrSnapshotNotFound) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		// Keep the current state, the restore can be undone
+		current, err := backups.Create()
+		if err != nil {
+			logger.Error("Failed to create database snapshot", "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		db.Restore(restored)
+		logger.Info("Database is restored from snapshot",
+			"snapshot", req.Name,
+			"previous_state", current.Name,
+		)
+	}
+}
+
+func exportJobs(
+	logger *slog.Logger,
+	db *storage.Database,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = exchange.FormatYAML
+		}
+
+		db.Mu.RLock()
+		data, err := exchange.Export(db.Jobs, format, nil)
+		db.Mu.RUnlock()
+		if err != nil {
+			logger.Error("Failed to export jobs", "error", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set(
+			"Content-Disposition",
+			`attachment; filename="cronshroom-jobs.`+format+`"`,
+		)
+		if _, err := w.Write(data); err != nil {
+			logger.Error("Failed to send exported jobs", "error", err)
+			return
+		}
+	}
+}
+
+func importJobs(
+	logger *slog.Logger,
+	db *storage.Database,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		format := query.Get("format")
+		mode := query.Get("mode")
+		dryRun := query.Get("dry_run") == "true"
+
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			logger.Error("Error read importJobs data", "error", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		defer func() {
+			if err = r.Body.Close(); err != nil {
+				logger.Error("Failed to close request body", "error", err)
+			}
+		}()
+
+		imported, err := exchange.Import(data, format, db)
+		if err != nil {
+			logger.Error("Failed to import jobs", "error", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		db.Mu.RLock()
+		plan, err := exchange.NewPlan(db.Jobs, imported, mode)
+		db.Mu.RUnlock()
+		if err != nil {
+			logger.Error("Failed to import jobs", "error", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if !dryRun {
+			plan.Apply(db)
+			logger.Info("Jobs are imported",
+				"mode", mode,
+				"added", len(plan.Added),
+				"updated", len(plan.Updated),
+				"removed", len(plan.Removed),
+			)
+		}
+
+		resp := struct {
+			*exchange.Plan
+			Diff string `json:"diff"`
+		}{
+			Plan: plan,
+			Diff: plan.Diff(),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			logger.Error("Failed to encode import plan to JSON",
+				"error", err,
+			)
+			return
+		}
+	}
+}
+
 func listHandler(
 	logger *slog.Logger,
 ) http.HandlerFunc {
@@ -0,0 +1,102 @@
+package gui
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"cronshroom/storage"
+)
+
+// TestChangeJobRoundTrip saves a job as the Add/Edit form sends it and
+// checks that get_job returns every field
+
+func TestChangeJobRoundTrip(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := storage.New()
+
+	body := `{
+		"name": "backup",
+		"description": "nightly backup",
+		"command": "echo ok",
+		"cron": "0 0 3 * * *",
+		"timeout": 30,
+		"maxRetries": 3,
+		"retryInterval": 10,
+		"env": "",
+		"user": "",
+		"group": "",
+		"misfirePolicy": "run_all",
+		"misfireMaxRuns": 5,
+		"startSpread": 0,
+		"startJitter": 0,
+		"excludeCalendars": [],
+		"onlyCalendars": [],
+		"pool": "db",
+		"priority": 10,
+		"queueMaxWait": 600,
+		"retry": {
+			"backoff": "exponential",
+			"max_interval": 300,
+			"jitter": 0.1,
+			"exit_codes": [],
+			"skip_exit_codes": [2],
+			"skip_timeout": true
+		},
+		"limits": {
+			"memory": 1048576,
+			"cpu": 60,
+			"open_files": 256,
+			"nice": 5,
+			"cgroup": "",
+			"cgroup_memory_max": "",
+			"cgroup_cpu_max": ""
+		}
+	}`
+
+	w := httptest.NewRecorder()
+	changeJob(logger, db).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/change_job", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("change_job: expected status 200, got %d: %s", w.Code, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	getJob(logger, db).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/get_job?name=backup", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("get_job: expected status 200, got %d: %s", w.Code, w.Body)
+	}
+
+	var j storage.Job
+	if err := json.Unmarshal(w.Body.Bytes(), &j); err != nil {
+		t.Fatalf("get_job: %v", err)
+	}
+
+	if j.Config.MisfirePolicy != "run_all" || j.Config.MisfireMaxRuns != 5 {
+		t.Errorf("Misfire: got %q %d, want run_all 5", j.Config.MisfirePolicy, j.Config.MisfireMaxRuns)
+	}
+
+	if j.Config.Pool != "db" || j.Config.Priority != 10 || j.Config.QueueMaxWait != 600 {
+		t.Errorf("Queue: got %q %d %d, want db 10 600", j.Config.Pool, j.Config.Priority, j.Config.QueueMaxWait)
+	}
+
+	limits := &storage.JobLimits{Memory: 1048576, CPU: 60, OpenFiles: 256, Nice: 5}
+	if !reflect.DeepEqual(j.Config.Limits, limits) {
+		t.Errorf("Limits: got %+v, want %+v", j.Config.Limits, limits)
+	}
+
+	retry := &storage.RetryConfig{
+		Backoff:       "exponential",
+		MaxInterval:   300,
+		Jitter:        0.1,
+		SkipExitCodes: []int{2},
+		SkipTimeout:   true,
+	}
+	if !reflect.DeepEqual(j.Config.Retry, retry) {
+		t.Errorf("Retry: got %+v, want %+v", j.Config.Retry, retry)
+	}
+}
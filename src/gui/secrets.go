@@ -0,0 +1,125 @@
+package gui
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"cronshroom/secrets"
+	"cronshroom/storage"
+)
+
+// NOTE: Secrets are write-only: names are listed, values
+// are only set and are never returned
+
+func listSecrets(
+	logger *slog.Logger,
+	db *storage.Database,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store := db.Secrets()
+		if store == nil {
+			http.Error(w, storage.ErrNoSecrets.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		if err := json.NewEncoder(w).Encode(store.List()); err != nil {
+			logger.Error("Failed to encode secrets to JSON", "error", err)
+			return
+		}
+	}
+}
+
+func setSecret(
+	logger *slog.Logger,
+	db *storage.Database,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store := db.Secrets()
+		if store == nil {
+			http.Error(w, storage.ErrNoSecrets.Error(), http.StatusNotFound)
+			return
+		}
+
+		var req struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		}
+
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			logger.Error("Error decode setSecret json data", "error", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		defer func() {
+			if err = r.Body.Close(); err != nil {
+				logger.Error("Failed to close request body", "error", err)
+			}
+		}()
+
+		if err := secrets.ValidateName(req.Name); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := store.Set(req.Name, req.Value); err != nil {
+			logger.Error("Failed to set secret",
+				"secret", req.Name,
+				"error", err,
+			)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		logger.Info("Secret is set", "secret", req.Name)
+	}
+}
+
+func deleteSecret(
+	logger *slog.Logger,
+	db *storage.Database,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store := db.Secrets()
+		if store == nil {
+			http.Error(w, storage.ErrNoSecrets.Error(), http.StatusNotFound)
+			return
+		}
+
+		var req struct {
+			Name string `json:"name"`
+		}
+
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			logger.Error("Error decode deleteSecret json data", "error", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		defer func() {
+			if err = r.Body.Close(); err != nil {
+				logger.Error("Failed to close request body", "error", err)
+			}
+		}()
+
+		if err := store.Delete(req.Name); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, secrets.ErrSecretNotFound) {
+				status = http.StatusNotFound
+			} else {
+				logger.Error("Failed to delete secret",
+					"secret", req.Name,
+					"error", err,
+				)
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		logger.Info("Secret is deleted", "secret", req.Name)
+	}
+}
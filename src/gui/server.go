@@ -8,7 +8,9 @@ import (
 	"log/slog"
 	"net/http"
 
+	"cronshroom/logstore"
 	"cronshroom/storage"
+	"cronshroom/utils"
 )
 
 //go:embed resources/*
@@ -27,6 +29,9 @@ func CreateWebServer(
 	httpLogger *slog.Logger,
 	logger *slog.Logger,
 	db *storage.Database,
+	backups *storage.Backups,
+	health *utils.Health,
+	logStore *logstore.Store,
 	ctx context.Context,
 ) *http.Server {
 	mux := http.NewServeMux()
@@ -47,15 +52,41 @@ func CreateWebServer(
 	mux.Handle("/", m(rootHandler()))
 	mux.Handle("/list", m(listHandler(logger)))
 
+	// Probes are called often, they are not logged
+	mux.Handle("/healthz", healthz(logger, health))
+	mux.Handle("/readyz", readyz(logger, health))
+
 	// NOTE: Api routes
 
 	{
 		mux.Handle("/api/get_database", m(sendDatabase(logger, db)))
+		mux.Handle("/api/get_job", m(getJob(logger, db)))
 		mux.Handle("/api/change_job", m(changeJob(logger, db)))
 		mux.Handle("/api/delete_job", m(deleteJob(logger, db)))
 		mux.Handle("/api/toggle_job", m(toggleJob(logger, db)))
 		mux.Handle("/api/exec_job", m(execJob(logger, db, ctx)))
+		mux.Handle("/api/job_log", m(jobLog(logger, db)))
+		mux.Handle("/api/list_runs", m(listRuns(logger, db)))
+		mux.Handle("/api/get_run", m(getRun(logger, db)))
+		mux.Handle("/api/get_queue", m(getQueue(logger, db)))
+		mux.Handle("/api/cancel_run", m(cancelRun(logger, db)))
+		mux.Handle("/api/get_pause", m(getPause(logger, db)))
+		mux.Handle("/api/pause", m(pauseScheduler(logger, db)))
+		mux.Handle("/api/resume", m(resumeScheduler(logger, db)))
 		mux.Handle("/api/last_log", m(lastLog(logger)))
+		mux.Handle("/api/logs", m(queryLogs(logger, logStore)))
+		mux.Handle("/api/export_jobs", m(exportJobs(logger, db)))
+		mux.Handle("/api/import_jobs", m(importJobs(logger, db)))
+		mux.Handle("/api/list_backups", m(listBackups(logger, backups)))
+		mux.Handle("/api/create_backup", m(createBackup(logger, backups)))
+		mux.Handle("/api/restore_backup", m(restoreBackup(logger, db, backups)))
+		mux.Handle("/api/list_secrets", m(listSecrets(logger, db)))
+		mux.Handle("/api/set_secret", m(setSecret(logger, db)))
+		mux.Handle("/api/delete_secret", m(deleteSecret(logger, db)))
+		mux.Handle("/api/list_calendars", m(listCalendars(logger, db)))
+		mux.Handle("/api/set_calendar", m(setCalendar(logger, db)))
+		mux.Handle("/api/import_calendar", m(importCalendar(logger, db)))
+		mux.Handle("/api/delete_calendar", m(deleteCalendar(logger, db)))
 	}
 
 	return &http.Server{
@@ -0,0 +1,124 @@
+package gui
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"strings"
+	"time"
+
+	"cronshroom/logstore"
+	"cronshroom/storage"
+	"cronshroom/utils"
+)
+
+// NOTE: Read-only listener: probes and metrics. It has no routes
+// which change something or show jobs, runs and logs, so it can be
+// exposed when the web interface is disabled (headless mode)
+
+func CreateStatusServer(
+	addr string,
+	httpLogger *slog.Logger,
+	logger *slog.Logger,
+	db *storage.Database,
+	health *utils.Health,
+	logStore *logstore.Store,
+) *http.Server {
+	mux := http.NewServeMux()
+
+	m := createMiddlewaresChain(
+		logReqMiddleware(httpLogger),
+		readOnlyMiddleware(),
+	)
+
+	mux.Handle("/healthz", readOnlyMiddleware()(healthz(logger, health)))
+	mux.Handle("/readyz", readOnlyMiddleware()(readyz(logger, health)))
+	mux.Handle("/metrics", m(metrics(logger, db, logStore)))
+
+	return &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+}
+
+var startedAt = time.Now()
+
+// metrics sends metrics in the Prometheus text format
+
+func metrics(
+	logger *slog.Logger,
+	db *storage.Database,
+	logStore *logstore.Store,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var enabled, disabled int
+		db.Mu.RLock()
+		for _, j := range db.Jobs {
+			if j.Config.Status.Configured() == storage.StatusEnable {
+				enabled++
+			} else {
+				disabled++
+			}
+		}
+		updatedAt := db.Metadata.UpdatedAt
+		db.Mu.RUnlock()
+
+		stats := db.RunStats()
+
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+
+		var b strings.Builder
+		metric := func(name, help, kind string) {
+			fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		}
+
+		metric("cronshroom_jobs", "Number of jobs by configured status", "gauge")
+		fmt.Fprintf(&b, "cronshroom_jobs{status=\"enabled\"} %d\n", enabled)
+		fmt.Fprintf(&b, "cronshroom_jobs{status=\"disabled\"} %d\n", disabled)
+
+		metric("cronshroom_runs_running", "Number of running jobs", "gauge")
+		fmt.Fprintf(&b, "cronshroom_runs_running %d\n", stats.Running)
+
+		paused := 0
+		if db.PauseState().Paused {
+			paused = 1
+		}
+		metric("cronshroom_paused", "Whether the scheduler is paused (maintenance mode)", "gauge")
+		fmt.Fprintf(&b, "cronshroom_paused %d\n", paused)
+
+		if queue, enabled := db.Queue(); enabled {
+			metric("cronshroom_runs_queued", "Number of runs waiting for concurrency limits", "gauge")
+			fmt.Fprintf(&b, "cronshroom_runs_queued %d\n", len(queue.Queued))
+		}
+
+		metric("cronshroom_runs_finished_total", "Number of finished runs by status", "counter")
+		for _, status := range []storage.RunStatus{storage.RunOK, storage.RunFailed, storage.RunCanceled} {
+			fmt.Fprintf(&b, "cronshroom_runs_finished_total{status=%q} %d\n", status, stats.Finished[status])
+		}
+
+		metric("cronshroom_database_updated_at_seconds", "Time of the last change of the database", "gauge")
+		fmt.Fprintf(&b, "cronshroom_database_updated_at_seconds %d\n", updatedAt)
+
+		metric("cronshroom_start_time_seconds", "Start time of the program", "gauge")
+		fmt.Fprintf(&b, "cronshroom_start_time_seconds %d\n", startedAt.Unix())
+
+		if logStore != nil {
+			metric("cronshroom_log_entries_dropped_total", "Number of log entries dropped because the log store falls behind", "counter")
+			fmt.Fprintf(&b, "cronshroom_log_entries_dropped_total %d\n", logStore.Dropped())
+		}
+
+		metric("cronshroom_goroutines", "Number of goroutines", "gauge")
+		fmt.Fprintf(&b, "cronshroom_goroutines %d\n", runtime.NumGoroutine())
+
+		metric("cronshroom_heap_alloc_bytes", "Bytes of allocated heap objects", "gauge")
+		fmt.Fprintf(&b, "cronshroom_heap_alloc_bytes %d\n", mem.HeapAlloc)
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if _, err := w.Write([]byte(b.String())); err != nil {
+			logger.Error("Failed to send metrics", "error", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package gui
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cronshroom/storage"
+	"cronshroom/utils"
+)
+
+func TestStatusServerRoutes(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	srv := CreateStatusServer("", logger, logger, storage.New(), utils.NewHealth(time.Second, 3), nil)
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{http.MethodGet, "/metrics", http.StatusOK},
+		{http.MethodPost, "/metrics", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/api/get_database", http.StatusNotFound},
+		{http.MethodGet, "/api/get_run?id=1", http.StatusNotFound},
+		{http.MethodGet, "/api/logs", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.path, tt.status, w.Code)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package logstore
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+)
+
+// NOTE: slog handler which adds records to the store. Attributes
+// of groups are flattened: "group.key"
+
+type handler struct {
+	store  *Store
+	level  slog.Leveler
+	attrs  []slog.Attr
+	prefix string
+}
+
+func (s *Store) Handler(level slog.Leveler) slog.Handler {
+	return &handler{store: s, level: level}
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *handler) Handle(_ context.Context, r slog.Record) error {
+	e := Entry{
+		Time:    r.Time,
+		Level:   r.Level.String(),
+		Message: r.Message,
+	}
+
+	attrs := map[string]any{}
+	for _, a := range h.attrs {
+		addAttr(attrs, "", a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		addAttr(attrs, h.prefix, a)
+		return true
+	})
+	if len(attrs) > 0 {
+		e.Attrs = attrs
+	}
+
+	h.store.add(e)
+	return nil
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.attrs = slices.Clone(h.attrs)
+	for _, a := range attrs {
+		// Attributes are kept with keys of the current group
+		a.Key = h.prefix + a.Key
+		h2.attrs = append(h2.attrs, a)
+	}
+	return &h2
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.prefix = h.prefix + name + "."
+	return &h2
+}
+
+func addAttr(attrs map[string]any, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		groupPrefix := prefix
+		if a.Key != "" {
+			groupPrefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			addAttr(attrs, groupPrefix, ga)
+		}
+		return
+	}
+
+	v := a.Value.Any()
+	if err, ok := v.(error); ok {
+		v = err.Error()
+	}
+	attrs[prefix+a.Key] = v
+}
@@ -0,0 +1,476 @@
+// Package logstore: on-disk store of log entries with search
+package logstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// NOTE: Entries are kept in bbolt by increasing ids, indexes
+// map times and job names to ids. Entries are written by a
+// background goroutine in batches
+
+var (
+	entriesBucket = []byte("entries")
+	timeBucket    = []byte("time")
+	jobBucket     = []byte("job")
+)
+
+const (
+	queueSize     = 1024
+	batchSize     = 256
+	flushInterval = 500 * time.Millisecond
+	// Entries logged before Open are kept up to the limit
+	maxPending = 1000
+
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+var ErrNotOpen = errors.New("log store is not open")
+
+type Entry struct {
+	ID      uint64         `json:"id"`
+	Time    time.Time      `json:"time"`
+	Level   string         `json:"level"`
+	Message string         `json:"message"`
+	Attrs   map[string]any `json:"attrs,omitempty"`
+}
+
+// Records about jobs have the job name in this attribute
+const jobAttr = "name"
+
+func (e *Entry) job() string {
+	job, _ := e.Attrs[jobAttr].(string)
+	return job
+}
+
+type Store struct {
+	maxEntries uint64
+
+	mu     sync.RWMutex
+	db     *bbolt.DB
+	queue  chan Entry
+	done   chan struct{}
+	closed bool
+	// Entries dropped because the queue is full
+	dropped atomic.Uint64
+
+	pendingMu sync.Mutex
+	pending   []Entry
+}
+
+// New returns the store which keeps up to maxEntries entries. Entries
+// are accepted before Open and written to the file after it
+
+func New(maxEntries uint64) *Store {
+	return &Store{
+		maxEntries: maxEntries,
+		queue:      make(chan Entry, queueSize),
+		done:       make(chan struct{}),
+	}
+}
+
+func (s *Store) Open(path string) error {
+	db, err := bbolt.Open(path, 0o644, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{entriesBucket, timeBucket, jobBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return err
+	}
+
+	s.mu.Lock()
+	s.db = db
+	s.mu.Unlock()
+
+	s.pendingMu.Lock()
+	pending := s.pending
+	s.pending = nil
+	s.pendingMu.Unlock()
+
+	go s.writeLoop(pending)
+	return nil
+}
+
+// Close writes queued entries and closes the file
+
+func (s *Store) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.queue)
+	db := s.db
+	s.mu.Unlock()
+
+	if db == nil {
+		return nil
+	}
+	<-s.done
+	return db.Close()
+}
+
+// add queues the entry, it is dropped if the writer falls behind,
+// so logging (also under locks of callers) never waits for the file
+
+func (s *Store) add(e Entry) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	switch {
+	case s.closed:
+	case s.db == nil:
+		s.pendingMu.Lock()
+		if len(s.pending) < maxPending {
+			s.pending = append(s.pending, e)
+		}
+		s.pendingMu.Unlock()
+	default:
+		select {
+		case s.queue <- e:
+		default:
+			s.dropped.Add(1)
+		}
+	}
+}
+
+// Dropped returns the number of entries dropped because the queue was full
+
+func (s *Store) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+func (s *Store) writeLoop(batch []Entry) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	var reported uint64
+	flush := func() {
+		if dropped := s.dropped.Load(); dropped > reported {
+			fmt.Fprintf(os.Stderr, "Log store dropped %d entries, the writer falls behind\n", dropped-reported)
+			reported = dropped
+		}
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.write(batch); err != nil {
+			// The store is a part of logging,
+			// so errors can only be printed
+			fmt.Fprintf(os.Stderr, "Log store write failed: %v\n", err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case e, ok := <-s.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, e)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func (s *Store) write(batch []Entry) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		entries := tx.Bucket(entriesBucket)
+		times := tx.Bucket(timeBucket)
+		jobs := tx.Bucket(jobBucket)
+
+		for _, e := range batch {
+			id, err := entries.NextSequence()
+			if err != nil {
+				return err
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+
+			if err := entries.Put(itob(id), data); err != nil {
+				return err
+			}
+			if err := times.Put(timeKey(e.Time, id), nil); err != nil {
+				return err
+			}
+			if job := e.job(); job != "" {
+				if err := jobs.Put(jobKey(job, id), nil); err != nil {
+					return err
+				}
+			}
+		}
+
+		return s.prune(tx)
+	})
+}
+
+// prune deletes the oldest entries over maxEntries
+
+func (s *Store) prune(tx *bbolt.Tx) error {
+	entries := tx.Bucket(entriesBucket)
+	first, _ := entries.Cursor().First()
+	if first == nil {
+		return nil
+	}
+	count := entries.Sequence() - btoi(first) + 1
+	if count <= s.maxEntries {
+		return nil
+	}
+
+	for id := btoi(first); count > s.maxEntries; id, count = id+1, count-1 {
+		data := entries.Get(itob(id))
+		if data == nil {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(data, &e); err != nil {
+			return err
+		}
+
+		if err := entries.Delete(itob(id)); err != nil {
+			return err
+		}
+		if err := tx.Bucket(timeBucket).Delete(timeKey(e.Time, id)); err != nil {
+			return err
+		}
+		if job := e.job(); job != "" {
+			if err := tx.Bucket(jobBucket).Delete(jobKey(job, id)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// NOTE: Search
+
+type Query struct {
+	// Minimal level
+	Level slog.Level
+	// Time range, a zero time is not limited
+	Since time.Time
+	Until time.Time
+	// Value of the "name" attribute
+	Job string
+	// Case-insensitive substring of the message
+	Text  string
+	Regex *regexp.Regexp
+	// Entries with smaller ids are returned, 0 - from the newest
+	Cursor uint64
+	Limit  int
+}
+
+type Page struct {
+	Entries []Entry `json:"entries"`
+	// Cursor of the next (older) page, 0 if there are no more entries
+	NextCursor uint64 `json:"next_cursor,omitempty"`
+}
+
+// Query returns entries matching the query from the newest
+
+func (s *Store) Query(q Query) (*Page, error) {
+	s.mu.RLock()
+	db := s.db
+	s.mu.RUnlock()
+	if db == nil {
+		return nil, ErrNotOpen
+	}
+
+	if q.Limit <= 0 {
+		q.Limit = DefaultLimit
+	}
+	q.Limit = min(q.Limit, MaxLimit)
+	text := strings.ToLower(q.Text)
+
+	page := &Page{Entries: []Entry{}}
+	err := db.View(func(tx *bbolt.Tx) error {
+		entries := tx.Bucket(entriesBucket)
+		next := idIterator(tx, q)
+
+		for id := next(); id != 0; id = next() {
+			data := entries.Get(itob(id))
+			if data == nil {
+				continue
+			}
+			var e Entry
+			if err := json.Unmarshal(data, &e); err != nil {
+				return err
+			}
+			e.ID = id
+
+			// Ids follow the time, so older
+			// entries are not checked further
+			if !q.Since.IsZero() && e.Time.Before(q.Since) {
+				return nil
+			}
+			if !q.Until.IsZero() && e.Time.After(q.Until) {
+				continue
+			}
+			if !levelAtLeast(e.Level, q.Level) {
+				continue
+			}
+			if text != "" && !strings.Contains(strings.ToLower(e.Message), text) {
+				continue
+			}
+			if q.Regex != nil && !q.Regex.MatchString(e.Message) {
+				continue
+			}
+
+			if len(page.Entries) == q.Limit {
+				page.NextCursor = page.Entries[len(page.Entries)-1].ID
+				return nil
+			}
+			page.Entries = append(page.Entries, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// idIterator returns the function which returns ids of entries from
+// the newest to check for the query, 0 after the last one
+
+func idIterator(tx *bbolt.Tx, q Query) func() uint64 {
+	// There are no ids below the first one
+	if q.Cursor == 1 {
+		return func() uint64 { return 0 }
+	}
+
+	// The first id to return is below the cursor and,
+	// if Until is set, not newer than it
+	start := uint64(0)
+	if q.Cursor > 0 {
+		start = q.Cursor - 1
+	}
+	if !q.Until.IsZero() {
+		c := tx.Bucket(timeBucket).Cursor()
+		k, _ := c.Seek(timeKey(q.Until.Add(time.Nanosecond), 0))
+		if k == nil {
+			k, _ = c.Last()
+		} else {
+			k, _ = c.Prev()
+		}
+		if k == nil {
+			return func() uint64 { return 0 }
+		}
+		if id := btoi(k[8:]); start == 0 || id < start {
+			start = id
+		}
+	}
+
+	if q.Job != "" {
+		prefix := jobKey(q.Job, 0)[:len(q.Job)+1]
+		c := tx.Bucket(jobBucket).Cursor()
+		seek := jobKey(q.Job, ^uint64(0))
+		if start > 0 {
+			seek = jobKey(q.Job, start+1)
+		}
+		first := true
+		return func() uint64 {
+			var k []byte
+			if first {
+				first = false
+				if k, _ = c.Seek(seek); k == nil {
+					k, _ = c.Last()
+				} else {
+					k, _ = c.Prev()
+				}
+			} else {
+				k, _ = c.Prev()
+			}
+			if k == nil || !bytes.HasPrefix(k, prefix) || len(k) != len(prefix)+8 {
+				return 0
+			}
+			return btoi(k[len(prefix):])
+		}
+	}
+
+	c := tx.Bucket(entriesBucket).Cursor()
+	first := true
+	return func() uint64 {
+		var k []byte
+		if first {
+			first = false
+			if start == 0 {
+				k, _ = c.Last()
+			} else if k, _ = c.Seek(itob(start + 1)); k == nil {
+				k, _ = c.Last()
+			} else {
+				k, _ = c.Prev()
+			}
+		} else {
+			k, _ = c.Prev()
+		}
+		if k == nil {
+			return 0
+		}
+		return btoi(k)
+	}
+}
+
+func levelAtLeast(level string, min slog.Level) bool {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return true
+	}
+	return l >= min
+}
+
+// NOTE: Keys
+
+func itob(v uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, v)
+}
+
+func btoi(b []byte) uint64 {
+	return binary.BigEndian.Uint64(b)
+}
+
+func timeKey(t time.Time, id uint64) []byte {
+	key := binary.BigEndian.AppendUint64(nil, uint64(t.UnixNano()))
+	return binary.BigEndian.AppendUint64(key, id)
+}
+
+// jobKey is the name, zero byte and the id
+
+func jobKey(job string, id uint64) []byte {
+	key := append([]byte(job), 0)
+	return binary.BigEndian.AppendUint64(key, id)
+}
@@ -0,0 +1,140 @@
+package logstore
+
+import (
+	"log/slog"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.db")
+
+	s := New(8)
+	logger := slog.New(s.Handler(slog.LevelDebug))
+
+	// Logged before Open
+	logger.Info("Program started")
+	if err := s.Open(path); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	start := time.Now()
+	for i := range 4 {
+		logger.Info("Start command execution", "name", "backup", "i", i)
+		logger.Warn("Command failed", "name", "cleanup", "i", i)
+	}
+	logger.WithGroup("http").Debug("HTTP request", "path", "/list")
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Reopen: entries are kept, the oldest ones are pruned
+	s = New(8)
+	if err := s.Open(path); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	tests := []struct {
+		name     string
+		query    Query
+		expected []uint64
+		next     uint64
+	}{
+		{
+			name:     "all, the oldest are pruned",
+			query:    Query{Level: slog.LevelDebug},
+			expected: []uint64{10, 9, 8, 7, 6, 5, 4, 3},
+		},
+		{
+			name:     "level",
+			query:    Query{Level: slog.LevelWarn},
+			expected: []uint64{9, 7, 5, 3},
+		},
+		{
+			name:     "job",
+			query:    Query{Job: "backup"},
+			expected: []uint64{8, 6, 4},
+		},
+		{
+			name:     "job page",
+			query:    Query{Job: "cleanup", Limit: 2},
+			expected: []uint64{9, 7},
+			next:     7,
+		},
+		{
+			name:     "job next page",
+			query:    Query{Job: "cleanup", Limit: 2, Cursor: 7},
+			expected: []uint64{5, 3},
+		},
+		{
+			name:     "text and page",
+			query:    Query{Text: "COMMAND", Limit: 3, Cursor: 8},
+			expected: []uint64{7, 6, 5},
+			next:     5,
+		},
+		{
+			name:     "cursor of the first entry",
+			query:    Query{Level: slog.LevelDebug, Cursor: 1},
+			expected: []uint64{},
+		},
+		{
+			name:     "regex",
+			query:    Query{Level: slog.LevelDebug, Regex: regexp.MustCompile(`^HTTP`)},
+			expected: []uint64{10},
+		},
+		{
+			name:     "until",
+			query:    Query{Until: start.Add(-time.Hour)},
+			expected: []uint64{},
+		},
+		{
+			name:     "since",
+			query:    Query{Level: slog.LevelDebug, Since: time.Now().Add(time.Hour)},
+			expected: []uint64{},
+		},
+	}
+
+	for _, tt := range tests {
+		page, err := s.Query(tt.query)
+		if err != nil {
+			t.Fatalf("%s: Query failed: %v", tt.name, err)
+		}
+
+		ids := []uint64{}
+		for _, e := range page.Entries {
+			ids = append(ids, e.ID)
+		}
+		if len(ids) != len(tt.expected) || page.NextCursor != tt.next {
+			t.Fatalf("%s: expected %v (next %d), got %v (next %d)", tt.name, tt.expected, tt.next, ids, page.NextCursor)
+		}
+		for i := range ids {
+			if ids[i] != tt.expected[i] {
+				t.Fatalf("%s: expected %v, got %v", tt.name, tt.expected, ids)
+			}
+		}
+	}
+
+	page, _ := s.Query(Query{Level: slog.LevelDebug, Limit: 1})
+	if attrs := page.Entries[0].Attrs; attrs["http.path"] != "/list" {
+		t.Fatalf("Expected attributes of the group, got %v", attrs)
+	}
+}
+
+func TestStoreDropsWhenQueueIsFull(t *testing.T) {
+	// Open store without the writer, the queue is not read
+	s := New(8)
+	s.db = &bbolt.DB{}
+
+	logger := slog.New(s.Handler(slog.LevelDebug))
+	for range queueSize + 5 {
+		logger.Info("Start command execution")
+	}
+	if dropped := s.Dropped(); dropped != 5 {
+		t.Fatalf("Expected 5 dropped entries, got %d", dropped)
+	}
+}
@@ -2,17 +2,23 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"regexp"
 	"runtime"
+	"slices"
 	"sync/atomic"
 	"time"
 
+	"cronshroom/calendars"
+	"cronshroom/extjob"
 	"cronshroom/gui"
+	"cronshroom/logstore"
 	"cronshroom/storage"
 	"cronshroom/utils"
 
@@ -22,21 +28,47 @@ import (
 )
 
 const (
-	defaultDatabaseName = "cronshroom-database.json"
-	defaultLogFileName  = "cronshroom-log"
+	defaultDatabaseName     = "cronshroom-database.json"
+	defaultBoltDatabaseName = "cronshroom-database.db"
+	defaultLogFileName      = "cronshroom-log"
+	defaultLogStoreName     = "cronshroom-logs.db"
 )
 
 type flagOpts struct {
-	DatabasePath                string `short:"d" long:"database" description:"Path to the database file (default: in system config directory)"`
-	WebServerPort               uint16 `short:"p" long:"port" description:"Web server port" default:"3777"`
-	WebLogMaxEntries            uint   `short:"l" long:"web-log-max" description:"Maximum log entries to show in web interface" default:"100"`
-	DatabaseSyncInterval        uint   `long:"sync-interval" description:"Database sync interval in seconds" default:"1"`
-	DatabaseSyncAttemptMaxCount uint32 `long:"max-sync-attempts" description:"Max consecutive database sync attempts before shutdown" default:"10"`
-	WebServerShutdownTimeout    uint   `long:"server-shutdown-timeout" description:"The time in seconds that the web server gives all connections to complete before it terminates them harshly" default:"10"`
-	MemStatsInterval            uint   `long:"mem-stats-interval" description:"Interval in seconds for logging memory statistics (for leak detection). It also causes garbage collection. Disable - 0 value" default:"1800"`
-	HTTPLog                     bool   `long:"http-log" description:"Log messages about HTTP connections"`
-	LogFileMaxSizeBytes         uint64 `long:"log-file-max-size" description:"Log file max size in bytes (if the max size is reached the file will be overwritten)" default:"10485760"`
-	Cleanup                     bool   `long:"cleanup" description:"Delete all files created by the program in system config directory and shut down"`
+	ConfigPath                  string   `short:"c" long:"config" description:"Path to the config file, TOML or YAML (default: cronshroom-config.toml or .yaml in system config directory if it exists)"`
+	DatabasePath                string   `short:"d" long:"database" description:"Path to the database file (default: in system config directory)"`
+	DatabaseBackend             string   `short:"b" long:"backend" description:"Database storage backend: json - single JSON file, bolt - embedded key-value database for large job sets" choice:"json" choice:"bolt" default:"json"`
+	WebServerPort               uint16   `short:"p" long:"port" description:"Web server port" default:"3777"`
+	WebLogMaxEntries            uint     `short:"l" long:"web-log-max" description:"Maximum log entries to show in web interface" default:"100"`
+	DatabaseSyncInterval        uint     `long:"sync-interval" description:"Database sync interval in seconds" default:"1"`
+	DatabaseSyncAttemptMaxCount uint32   `long:"max-sync-attempts" description:"Max consecutive database sync attempts before shutdown" default:"10"`
+	WebServerShutdownTimeout    uint     `long:"server-shutdown-timeout" description:"The time in seconds that the web server gives all connections to complete before it terminates them harshly" default:"10"`
+	MemStatsInterval            uint     `long:"mem-stats-interval" description:"Interval in seconds for logging memory statistics (for leak detection). It also causes garbage collection. Disable - 0 value" default:"1800"`
+	LogFormat                   string   `long:"log-format" description:"Log format of stdout and the log file" choice:"text" choice:"json" default:"text"`
+	LogLevel                    string   `long:"log-level" description:"Minimal level of log messages" choice:"debug" choice:"info" choice:"warn" choice:"error" default:"info"`
+	HTTPLog                     bool     `long:"http-log" description:"Log messages about HTTP connections"`
+	Headless                    bool     `long:"headless" description:"Run without the web interface and the web API, jobs are managed by changes of the database file"`
+	StatusAddr                  string   `long:"status-addr" description:"Address (e.g. 127.0.0.1:3778) of the read-only listener with probes and metrics. Disable - empty value"`
+	SocketPath                  string   `long:"socket" description:"Unix socket to serve the web API on (in addition to the port). Client commands connect to it if it is set"`
+	CalendarsFile               string   `long:"calendars-file" description:"Path to the file of calendars which suppress or allow scheduled runs of jobs (default: in system config directory)"`
+	StatePath                   string   `long:"state-file" description:"File of last fire times of jobs, they are used to run occurrences missed while the program was not running, and of the pause of the scheduler (default: in system config directory)"`
+	MaxConcurrentRuns           uint     `long:"max-concurrent-runs" description:"Maximum number of scheduled runs at once, other runs wait in the queue. Unlimited - 0 value" default:"0"`
+	Pools                       []string `long:"pool" description:"Concurrency pool NAME=SIZE: at most SIZE scheduled runs of jobs of the pool at once. Can be repeated"`
+	BackupDir                   string   `long:"backup-dir" description:"Directory for database snapshots (default: in system config directory)"`
+	BackupInterval              uint     `long:"backup-interval" description:"Interval in seconds for database snapshots, a snapshot is taken only if the database was changed. Disable - 0 value" default:"3600"`
+	BackupEveryChanges          uint     `long:"backup-every-changes" description:"Take a database snapshot after every N job changes. Disable - 0 value" default:"20"`
+	BackupMaxCount              uint     `long:"backup-max-count" description:"Maximum number of database snapshots to keep. Unlimited - 0 value" default:"30"`
+	BackupMaxAge                uint     `long:"backup-max-age" description:"Maximum age in seconds of database snapshots to keep (the newest one is always kept). Unlimited - 0 value" default:"2592000"`
+	LogFileMaxSizeBytes         uint64   `long:"log-file-max-size" description:"Log file max size in bytes, the file is rotated when it is reached. Disable - 0 value" default:"10485760"`
+	LogFileMaxBackups           uint     `long:"log-file-max-backups" description:"Number of rotated log files to keep (compressed with gzip)" default:"5"`
+	LogFileDaily                bool     `long:"log-file-daily" description:"Rotate the log file every day"`
+	LogStoreMaxEntries          uint64   `long:"log-store-max-entries" description:"Maximum number of entries in the searchable log store (in system config directory). Disable - 0 value" default:"100000"`
+	SecretsFile                 string   `long:"secrets-file" description:"Path to the file of encrypted secrets (default: in system config directory)"`
+	SecretsKeyFile              string   `long:"secrets-key-file" description:"Path to the file with the key of secrets (32 random bytes in base64). The key can also be set by the CRONSHROOM_SECRETS_KEY environment variable. Secrets are disabled without a key"`
+	RedactPatterns              []string `long:"redact-pattern" description:"Regular expression of secrets to mask in logs and outputs of jobs, the first group is masked or the whole match if there are no groups. Can be repeated, added to built-in patterns (passwords in URLs, tokens, PGPASSWORD=...)"`
+	NoRedact                    bool     `long:"no-redact" description:"Disable masking of secrets in logs and outputs of jobs"`
+	JobLogDir                   string   `long:"job-log-dir" description:"Directory for log files of jobs: records about runs of every job are also written to its own file, rotated like the log file. Disable - empty value"`
+	Cleanup                     bool     `long:"cleanup" description:"Delete all files created by the program in system config directory and shut down"`
 }
 
 // TODO: doc files https://github.com/reugn/go-quartz/blob/master/job/doc.go
@@ -46,21 +78,48 @@ func main() {
 
 	var fo flagOpts
 	parser := flags.NewParser(&fo, flags.Default)
+	parser.SubcommandsOptional = true
+	setupEnv(parser)
+
+	// Config file values are applied after the parse,
+	// before a subcommand or the program is started
+	cfg := &effectiveConfig{}
+	parser.CommandHandler = func(cmd flags.Commander, args []string) error {
+		if err := cfg.apply(parser, fo.ConfigPath); err != nil {
+			return err
+		}
+		if cmd == nil {
+			return nil
+		}
+		return cmd.Execute(args)
+	}
+	addCommands(parser, &fo, cfg)
 
 	_, err := parser.Parse()
 	if err != nil {
 		if _, ok := err.(*flags.Error); ok {
 			return
 		}
-		slog.New(slog.NewTextHandler(os.Stdout, nil)).Error(
-			"Failed to parse command line flags",
-			"error", err,
-		)
+		// Subcommand and config errors are already printed by the parser
+		os.Exit(1)
+	}
+
+	// Subcommand was executed by the parser
+	if parser.Active != nil {
 		return
 	}
 
 	logFileMaxSizeBytes := fo.LogFileMaxSizeBytes
+	logFileMaxBackups := fo.LogFileMaxBackups
+	logFileDaily := fo.LogFileDaily
+	logStoreMaxEntries := fo.LogStoreMaxEntries
+	jobLogDir := fo.JobLogDir
+	secretsFile := fo.SecretsFile
+	secretsKeyFile := fo.SecretsKeyFile
+	redactPatterns := fo.RedactPatterns
+	noRedact := fo.NoRedact
 	dbPath := fo.DatabasePath
+	dbBackend := fo.DatabaseBackend
 	webLogMaxEntries := fo.WebLogMaxEntries
 	dbSyncInterval := fo.DatabaseSyncInterval
 	dbSyncAttemptMaxCount := fo.DatabaseSyncAttemptMaxCount
@@ -68,6 +127,20 @@ func main() {
 	webServerShutdownTimeout := fo.WebServerShutdownTimeout
 	memStatsInterval := fo.MemStatsInterval
 	HTTPLog := fo.HTTPLog
+	logFormat := fo.LogFormat
+	logLevel := fo.LogLevel
+	headless := fo.Headless
+	statusAddr := fo.StatusAddr
+	socketPath := fo.SocketPath
+	statePath := fo.StatePath
+	calendarsFile := fo.CalendarsFile
+	maxConcurrentRuns := fo.MaxConcurrentRuns
+	pools := fo.Pools
+	backupDir := fo.BackupDir
+	backupInterval := fo.BackupInterval
+	backupEveryChanges := fo.BackupEveryChanges
+	backupMaxCount := fo.BackupMaxCount
+	backupMaxAge := fo.BackupMaxAge
 	cleanup := fo.Cleanup
 
 	if webLogMaxEntries == 0 {
@@ -79,23 +152,84 @@ func main() {
 
 	// NOTE: Setup logger
 
+	// The level is one of the choices of the flag
+	var level slog.Level
+	_ = level.UnmarshalText([]byte(logLevel))
+
 	logWriter := utils.NewSwappableWriter(os.Stdout)
-	logHandler := utils.NewSlogBufferedHandler(
-		slog.NewTextHandler(logWriter, nil),
+	outputHandler := utils.NewLogHandler(logWriter, logFormat, level)
+
+	// Entries are kept by the log store before its file is opened
+	var logStore *logstore.Store
+	if logStoreMaxEntries != 0 && !cleanup {
+		logStore = logstore.New(logStoreMaxEntries)
+		outputHandler = utils.NewMultiHandler(
+			outputHandler,
+			logStore.Handler(level),
+		)
+	}
+
+	var logHandler slog.Handler = utils.NewSlogBufferedHandler(
+		outputHandler,
 		int(webLogMaxEntries),
 	)
+
+	// Secrets are masked before records are buffered or written
+	var redactor *utils.Redactor
+	if !noRedact {
+		patterns := slices.Clone(utils.DefaultRedactPatterns)
+		for _, p := range redactPatterns {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				slog.New(slog.NewTextHandler(os.Stdout, nil)).Error(
+					"Invalid redact pattern",
+					"pattern", p,
+					"error", err,
+				)
+				return
+			}
+			patterns = append(patterns, re)
+		}
+		redactor = utils.NewRedactor(patterns...)
+		logHandler = utils.NewRedactHandler(logHandler, redactor)
+	}
+
 	logger := slog.New(logHandler)
 
 	logger.Info("Program started with flags",
+		"config", cfg.File,
 		"database", dbPath,
+		"backend", dbBackend,
 		"port", webServerPort,
 		"web-log-max", webLogMaxEntries,
 		"sync-interval", dbSyncInterval,
 		"max-sync-attempts", dbSyncAttemptMaxCount,
 		"server-shutdown-timeout", webServerShutdownTimeout,
 		"mem-stats-interval", memStatsInterval,
+		"log-format", logFormat,
+		"log-level", logLevel,
 		"http-log", HTTPLog,
+		"headless", headless,
+		"status-addr", statusAddr,
+		"socket", socketPath,
+		"state-file", statePath,
+		"calendars-file", calendarsFile,
+		"max-concurrent-runs", maxConcurrentRuns,
+		"pool", pools,
+		"backup-dir", backupDir,
+		"backup-interval", backupInterval,
+		"backup-every-changes", backupEveryChanges,
+		"backup-max-count", backupMaxCount,
+		"backup-max-age", backupMaxAge,
 		"log-file-max-size", logFileMaxSizeBytes,
+		"log-file-max-backups", logFileMaxBackups,
+		"log-file-daily", logFileDaily,
+		"log-store-max-entries", logStoreMaxEntries,
+		"job-log-dir", jobLogDir,
+		"secrets-file", secretsFile,
+		"secrets-key-file", secretsKeyFile,
+		"redact-pattern", redactPatterns,
+		"no-redact", noRedact,
 		"cleanup", cleanup,
 	)
 
@@ -109,6 +243,12 @@ func main() {
 		return
 	}
 
+	rotateOpts := utils.RotateOptions{
+		MaxSize:    int64(logFileMaxSizeBytes),
+		MaxBackups: int(logFileMaxBackups),
+		Daily:      logFileDaily,
+	}
+
 	//
 	logFilePath, err := utils.ResolveFileInDefaultConfigDir(
 		defaultLogFileName,
@@ -120,10 +260,7 @@ func main() {
 		logger.Warn("Failed to resolve log file path", "error", err)
 	} else if !cleanup {
 		logger.Info("Loading log file", "file", logFilePath)
-		logFile, err := utils.OpenLogFile(
-			logFilePath,
-			int64(logFileMaxSizeBytes),
-		)
+		logFile, err := utils.OpenRotatingFile(logFilePath, rotateOpts)
 		if err != nil {
 			logger.Error("Failed to open log file",
 				"file", logFilePath,
@@ -133,6 +270,7 @@ func main() {
 			logger.Info("Log file loaded successfully", "file", logFilePath)
 			logWriter.Set(io.MultiWriter(os.Stdout, logFile))
 			defer func() {
+				logWriter.Set(os.Stdout)
 				if err := logFile.Close(); err != nil {
 					slog.New(slog.NewTextHandler(os.Stdout, nil)).Error(
 						"Failed to close log file",
@@ -149,24 +287,63 @@ func main() {
 				"error", err,
 			)
 		}
+		rotated, _ := utils.RotatedFiles(logFilePath)
+		for _, file := range rotated {
+			if err := os.Remove(file); err != nil {
+				logger.Warn("Failed to delete rotated log file",
+					"file", file,
+					"error", err,
+				)
+			}
+		}
 	}
 
-	//
-
-	if dbPath == "" {
-		dbPath, err = utils.ResolveFileInDefaultConfigDir(
-			defaultDatabaseName,
-			func(fullPath string) error {
-				return storage.New().SaveToFile(fullPath)
-			},
-		)
-		if err != nil {
-			logger.Error("Failed to resolve database file",
-				"file", dbPath,
+	logStorePath, err := utils.ResolveFileInDefaultConfigDir(
+		defaultLogStoreName,
+		func(fullPath string) error {
+			return nil
+		},
+	)
+	if err != nil {
+		logger.Warn("Failed to resolve log store path", "error", err)
+		logStore = nil
+	} else if cleanup {
+		if err := os.Remove(logStorePath); err != nil && !os.IsNotExist(err) {
+			logger.Warn("Failed to delete log store",
+				"file", logStorePath,
 				"error", err,
 			)
-			return
 		}
+	} else if logStore != nil {
+		if err := logStore.Open(logStorePath); err != nil {
+			logger.Error("Failed to open log store",
+				"file", logStorePath,
+				"error", err,
+			)
+			logStore = nil
+		} else {
+			logger.Info("Log store opened", "file", logStorePath)
+			defer func() {
+				if err := logStore.Close(); err != nil {
+					slog.New(slog.NewTextHandler(os.Stdout, nil)).Error(
+						"Failed to close log store",
+						"file", logStorePath,
+						"error", err,
+					)
+				}
+			}()
+		}
+	}
+
+	//
+
+	dbPath, err = resolveDatabasePath(dbPath, dbBackend)
+	if err != nil {
+		logger.Error("Failed to resolve database file",
+			"file", dbPath,
+			"error", err,
+		)
+		return
 	}
 
 	if cleanup {
@@ -176,6 +353,34 @@ func main() {
 				"error", err,
 			)
 		}
+		if backupDir == "" {
+			if err := removeDefaultBackupDir(); err != nil {
+				logger.Warn("Failed to delete backup directory",
+					"error", err,
+				)
+			}
+		}
+		if secretsFile == "" {
+			if err := removeDefaultFile(defaultSecretsName); err != nil {
+				logger.Warn("Failed to delete secrets file",
+					"error", err,
+				)
+			}
+		}
+		if statePath == "" {
+			if err := removeDefaultFile(defaultStateName); err != nil {
+				logger.Warn("Failed to delete state file",
+					"error", err,
+				)
+			}
+		}
+		if calendarsFile == "" {
+			if err := removeDefaultFile(defaultCalendarsName); err != nil {
+				logger.Warn("Failed to delete calendars file",
+					"error", err,
+				)
+			}
+		}
 		logger.Info("Cleanup done")
 		return
 	}
@@ -187,8 +392,22 @@ func main() {
 
 	// NOTE: Load database
 
-	logger.Info("Loading database", "file", dbPath)
-	db, err := storage.LoadFromFile(dbPath)
+	logger.Info("Loading database", "file", dbPath, "backend", dbBackend)
+	store, err := storage.OpenStore(dbBackend, dbPath)
+	if err != nil {
+		logger.Error("Database open failed",
+			"file", dbPath,
+			"error", err,
+		)
+		return
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			logger.Error("Database close failed", "error", err)
+		}
+	}()
+
+	db, err := store.Load()
 	if err != nil {
 		logger.Error("Database load failed",
 			"file", dbPath,
@@ -198,6 +417,96 @@ func main() {
 	}
 	logger.Info("Database loaded successfully", "file", dbPath)
 
+	health := utils.NewHealth(
+		time.Second*time.Duration(dbSyncInterval),
+		dbSyncAttemptMaxCount,
+	)
+	health.SetDatabaseLoaded()
+
+	if db.Migrated != nil {
+		logger.Info("Database is migrated to a new version",
+			"file", dbPath,
+			"from", db.Migrated.From,
+			"to", db.Migrated.To,
+			"backup", db.Migrated.Backup,
+		)
+	}
+
+	db.SetRedactor(redactor)
+
+	secretsStore, err := openSecrets(secretsFile, secretsKeyFile)
+	if err != nil {
+		logger.Error("Failed to open secrets",
+			"file", secretsFile,
+			"error", err,
+		)
+		return
+	}
+	if secretsStore != nil {
+		logger.Info("Secrets loaded successfully",
+			"count", len(secretsStore.List()),
+		)
+		db.SetSecrets(secretsStore)
+	}
+
+	if jobLogDir != "" {
+		jobLogs, err := utils.NewJobLogs(jobLogDir, rotateOpts, logFormat, level)
+		if err != nil {
+			logger.Error("Failed to setup log files of jobs",
+				"dir", jobLogDir,
+				"error", err,
+			)
+			return
+		}
+		db.SetJobLogs(jobLogs)
+		defer func() { _ = jobLogs.Close() }()
+	}
+
+	resolvedStatePath, err := resolveDefaultFile(statePath, defaultStateName)
+	if err != nil {
+		logger.Error("Failed to resolve state file", "error", err)
+		return
+	}
+	fireTimes, err := storage.LoadFireTimes(resolvedStatePath)
+	if err != nil {
+		logger.Error("Failed to load state file",
+			"file", resolvedStatePath,
+			"error", err,
+		)
+		return
+	}
+	db.SetFireTimes(fireTimes)
+	db.RestorePause(logger)
+	defer func() {
+		if err := fireTimes.Flush(time.Now()); err != nil {
+			logger.Warn("Failed to save state file", "error", err)
+		}
+	}()
+
+	resolvedCalendarsFile, err := resolveDefaultFile(calendarsFile, defaultCalendarsName)
+	if err != nil {
+		logger.Error("Failed to resolve calendars file", "error", err)
+		return
+	}
+	calendarStore, err := calendars.Open(resolvedCalendarsFile)
+	if err != nil {
+		logger.Error("Failed to load calendars file",
+			"file", resolvedCalendarsFile,
+			"error", err,
+		)
+		return
+	}
+	db.SetCalendars(calendarStore)
+
+	if maxConcurrentRuns > 0 || len(pools) > 0 {
+		poolSizes, err := extjob.ParsePools(pools)
+		if err != nil {
+			logger.Error("Failed to parse pools", "error", err)
+			return
+		}
+		db.SetLimiter(extjob.NewLimiter(int(maxConcurrentRuns), poolSizes))
+	}
+
 	// NOTE: Setup context
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -214,6 +523,13 @@ func main() {
 	}
 
 	scheduler.Start(ctx)
+	health.SetSchedulerProbe(func() error {
+		if !scheduler.IsStarted() {
+			return errors.New("scheduler is not started")
+		}
+		_, err := scheduler.GetJobKeys()
+		return err
+	})
 	defer func() {
 		scheduler.Stop()
 		scheduler.Wait(ctx)
@@ -230,6 +546,15 @@ func main() {
 		return
 	}
 
+	// NOTE: Run occurrences missed while the program was not running
+
+	db.Mu.RLock()
+	err = storage.CatchUp(ctx, db, logger)
+	db.Mu.RUnlock()
+	if err != nil {
+		logger.Warn("Catch-up of missed occurrences failed", "error", err)
+	}
+
 	// NOTE: Memory monitor
 
 	if memStatsInterval != 0 {
@@ -245,9 +570,53 @@ func main() {
 		defer close(memMonitorStopChan)
 	}
 
+	// NOTE: Database snapshots
+
+	resolvedBackupDir, err := resolveBackupDir(backupDir)
+	if err != nil {
+		logger.Error("Failed to resolve backup directory", "error", err)
+		return
+	}
+
+	backups, err := storage.NewBackups(store, storage.BackupOptions{
+		Dir:          resolvedBackupDir,
+		Backend:      dbBackend,
+		EveryChanges: backupEveryChanges,
+		MaxCount:     backupMaxCount,
+		MaxAge:       time.Second * time.Duration(backupMaxAge),
+	})
+	if err != nil {
+		logger.Error("Failed to setup database snapshots",
+			"dir", resolvedBackupDir,
+			"error", err,
+		)
+		return
+	}
+
+	if backupInterval != 0 {
+		backupTickerStopChan := utils.Ticker(func() {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+
+			snapshot, err := backups.CreateScheduled()
+			if err != nil {
+				logger.Warn("Database snapshot failed", "error", err)
+				return
+			}
+			if snapshot != nil {
+				logger.Info("Database snapshot is created",
+					"snapshot", snapshot.Name,
+				)
+			}
+		}, time.Second*time.Duration(backupInterval))
+		defer close(backupTickerStopChan)
+	}
+
 	// NOTE: Save db to file
 
-	var dbSyncFailureCount atomic.Uint32
 	var prevUpdatedAt atomic.Int64
 	prevUpdatedAt.Store(db.Metadata.UpdatedAt)
 
@@ -258,10 +627,15 @@ func main() {
 			return
 		default:
 		}
+		health.SyncTick()
+
+		if err := fireTimes.Flush(time.Now()); err != nil {
+			logger.Warn("Failed to save state file", "error", err)
+		}
 
 		// Exit if database reload has failed many
 		// times in a row - likely a persistent issue
-		if dbSyncFailureCount.Load() >= dbSyncAttemptMaxCount {
+		if health.SyncFailures() >= dbSyncAttemptMaxCount {
 			logger.Error(
 				"Persistent database reload failures - shutting down",
 			)
@@ -281,7 +655,7 @@ func main() {
 		// from memory after the end of the work
 		if err = scheduler.Clear(); err != nil {
 			logger.Warn("Scheduler clear failed", "error", err)
-			dbSyncFailureCount.Add(1)
+			health.SyncFailed(err)
 			return
 		}
 		logger.Info("Scheduler is cleared")
@@ -289,42 +663,153 @@ func main() {
 		err = storage.RegisterJobs(scheduler, db, logger)
 		if err != nil {
 			logger.Warn("Jobs register failed", "error", err)
-			dbSyncFailureCount.Add(1)
+			health.SyncFailed(err)
 			return
 		}
 		logger.Info("Jobs are registered in scheduler")
 
-		if err := db.SaveToFile(dbPath); err != nil {
-			logger.Warn("Save database to file failed", "error", err)
-			dbSyncFailureCount.Add(1)
+		changes := db.Changes()
+		changesCount := len(changes)
+		if changes == nil {
+			changesCount = len(db.Jobs)
+		}
+
+		if err := store.Sync(db, changes); err != nil {
+			logger.Warn("Save database to store failed", "error", err)
+			health.SyncFailed(err)
 			return
 		}
-		logger.Info("Database is saved to file")
+		db.ResetChanges()
+		logger.Info("Database is saved to store")
+
+		snapshot, err := backups.AddChanges(changesCount)
+		if err != nil {
+			logger.Warn("Database snapshot failed", "error", err)
+		} else if snapshot != nil {
+			logger.Info("Database snapshot is created",
+				"snapshot", snapshot.Name,
+			)
+		}
 
 		prevUpdatedAt.Store(db.Metadata.UpdatedAt)
-		dbSyncFailureCount.Store(0)
+		health.SyncSucceeded()
 	}, time.Second*time.Duration(dbSyncInterval))
 	defer close(dbSyncTickerStopChan)
 
-	// NOTE: Start Web Server
+	// NOTE: Reload db after external changes of the store
 
-	httpLogger := utils.MaybeLogger(logger, HTTPLog)
-	server := gui.CreateWebServer(
-		webServerPort,
-		httpLogger,
-		logger,
-		db,
+	if dbBackend == storage.BackendBolt {
+		logger.Warn("Database is not reloaded after external changes with the bolt backend, it is locked by the program",
+			"file", dbPath,
+		)
+	}
+
+	dbChangedChan := store.Watch(
 		ctx,
+		time.Second*time.Duration(dbSyncInterval),
 	)
 	go func() {
-		logger.Info("Starting web server", "port", webServerPort)
-		err := server.ListenAndServe()
-		if err != nil && err != http.ErrServerClosed {
-			logger.Error("Web server error", "error", err)
-			cancel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-dbChangedChan:
+			}
+
+			logger.Info("Database is changed outside, reloading",
+				"file", dbPath,
+			)
+			newDb, err := store.Load()
+			if err != nil {
+				logger.Warn("Database reload failed",
+					"file", dbPath,
+					"error", err,
+				)
+				continue
+			}
+			db.Replace(newDb)
 		}
 	}()
 
+	// NOTE: Start Web Server
+
+	httpLogger := utils.MaybeLogger(logger, HTTPLog)
+	var servers []*http.Server
+
+	if headless {
+		logger.Info("Headless mode, web server is not started")
+		if socketPath != "" {
+			logger.Warn("Socket is ignored in headless mode",
+				"socket", socketPath,
+			)
+		}
+	} else {
+		server := gui.CreateWebServer(
+			webServerPort,
+			httpLogger,
+			logger,
+			db,
+			backups,
+			health,
+			logStore,
+			ctx,
+		)
+		servers = append(servers, server)
+		go func() {
+			logger.Info("Starting web server", "port", webServerPort)
+			err := server.ListenAndServe()
+			if err != nil && err != http.ErrServerClosed {
+				logger.Error("Web server error", "error", err)
+				cancel()
+			}
+		}()
+
+		if socketPath != "" {
+			listener, err := listenSocket(socketPath)
+			if err != nil {
+				logger.Error("Failed to listen on socket",
+					"socket", socketPath,
+					"error", err,
+				)
+				return
+			}
+
+			go func() {
+				logger.Info("Starting web server on socket", "socket", socketPath)
+				err := server.Serve(listener)
+				if err != nil && err != http.ErrServerClosed {
+					logger.Error("Web server error",
+						"socket", socketPath,
+						"error", err,
+					)
+					cancel()
+				}
+			}()
+		}
+	}
+
+	// NOTE: Start status server
+
+	if statusAddr != "" {
+		statusServer := gui.CreateStatusServer(
+			statusAddr,
+			httpLogger,
+			logger,
+			db,
+			health,
+			logStore,
+		)
+		servers = append(servers, statusServer)
+		go func() {
+			logger.Info("Starting status server", "addr", statusAddr)
+			err := statusServer.ListenAndServe()
+			if err != nil && err != http.ErrServerClosed {
+				logger.Error("Status server error", "error", err)
+				cancel()
+			}
+		}()
+	}
+
 	// NOTE: Shutdown
 
 	{
@@ -343,10 +828,15 @@ func main() {
 		)
 		defer serverCancel()
 
-		if err := server.Shutdown(serverCtx); err != nil {
-			logger.Error("Web server shutdown error", "error", err)
-		} else {
-			logger.Info("Web server stopped")
+		for _, server := range servers {
+			if err := server.Shutdown(serverCtx); err != nil {
+				logger.Error("Web server shutdown error",
+					"addr", server.Addr,
+					"error", err,
+				)
+			} else {
+				logger.Info("Web server stopped", "addr", server.Addr)
+			}
 		}
 	}
 }
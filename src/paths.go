@@ -0,0 +1,127 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"cronshroom/secrets"
+	"cronshroom/storage"
+	"cronshroom/utils"
+)
+
+// NOTE: Default locations of program files (system config directory)
+
+const (
+	defaultBackupDirName = "cronshroom-backups"
+	defaultSecretsName   = "cronshroom-secrets.json"
+	defaultStateName     = "cronshroom-state.json"
+	defaultCalendarsName = "cronshroom-calendars.json"
+)
+
+// The key of secrets is not an option, so it is never
+// printed with other options or passed to jobs
+const secretsKeyEnv = envPrefix + "SECRETS_KEY"
+
+// resolveDatabasePath returns path if it is set, otherwise the default
+// database file of the backend, which is created if it does not exist
+
+func resolveDatabasePath(path, backend string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
+
+	name := defaultDatabaseName
+	if backend == storage.BackendBolt {
+		name = defaultBoltDatabaseName
+	}
+
+	return utils.ResolveFileInDefaultConfigDir(
+		name,
+		func(fullPath string) error {
+			return storage.InitStore(backend, fullPath)
+		},
+	)
+}
+
+func resolveBackupDir(dir string) (string, error) {
+	if dir != "" {
+		return dir, nil
+	}
+
+	return utils.ResolveFileInDefaultConfigDir(
+		defaultBackupDirName,
+		func(fullPath string) error {
+			return os.MkdirAll(fullPath, 0o755)
+		},
+	)
+}
+
+func removeDefaultBackupDir() error {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return err
+	}
+
+	return os.RemoveAll(filepath.Join(configDir, defaultBackupDirName))
+}
+
+// openSecrets opens the secrets file (the default one in system config
+// directory if path is empty) with the key from the key file or the
+// environment variable. It returns nil if there is no key
+
+func openSecrets(path, keyFile string) (*secrets.Store, error) {
+	envKey, fromEnv := os.LookupEnv(secretsKeyEnv)
+	if fromEnv {
+		_ = os.Unsetenv(secretsKeyEnv)
+	}
+	if keyFile == "" && !fromEnv {
+		return nil, nil
+	}
+
+	key, err := secrets.LoadKey(keyFile, envKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if path == "" {
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(configDir, defaultSecretsName)
+	}
+
+	return secrets.Open(path, key)
+}
+
+// resolveDefaultFile returns path if it is set, otherwise the file
+// name in system config directory (the file is created on the
+// first write, e.g. the file of fire times on the first fire)
+
+func resolveDefaultFile(path, name string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, name), nil
+}
+
+// removeDefaultFile deletes the file in system config
+// directory, a missing file is not an error
+
+func removeDefaultFile(name string) error {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(filepath.Join(configDir, name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
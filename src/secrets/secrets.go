@@ -0,0 +1,254 @@
+// Package secrets: named secrets encrypted at rest
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NOTE: Secrets are kept in a JSON file, every value is encrypted
+// with AES-256-GCM. The key is 32 random bytes in base64 (a file or
+// an environment variable), passphrases are not accepted: a key
+// derived from a passphrase could be brute-forced from the file
+
+// KeySize is the size of the key of secrets in bytes
+const KeySize = 32
+
+var (
+	ErrSecretNotFound = errors.New("secret not found")
+	ErrWrongKey       = errors.New("secrets cannot be decrypted, wrong key")
+	ErrQuotedRef      = errors.New("referenced inside single quotes, where the shell does not expand it, close the quotes around the reference")
+	ErrNoWindowsRefs  = errors.New("references in commands are not supported on Windows, pass the secret in env of the job")
+)
+
+var nameRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+type entry struct {
+	// base64 of nonce and ciphertext
+	Value     string `json:"value"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// Info describes a secret without its value
+
+type Info struct {
+	Name      string `json:"name"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+type Store struct {
+	mu      sync.RWMutex
+	path    string
+	aead    cipher.AEAD
+	entries map[string]entry
+}
+
+// LoadKey returns the key from the file (if the path is not empty)
+// or from the value of the environment variable, the key is
+// KeySize bytes in base64
+
+func LoadKey(keyFile, envValue string) ([]byte, error) {
+	var material string
+	if keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		material = strings.TrimSpace(string(data))
+	} else {
+		material = envValue
+	}
+
+	if material == "" {
+		return nil, errors.New("secrets key is empty")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(material)
+	if err != nil || len(key) != KeySize {
+		return nil, fmt.Errorf(
+			"secrets key must be %d random bytes in base64, e.g. the output of: head -c %d /dev/urandom | base64",
+			KeySize, KeySize,
+		)
+	}
+	return key, nil
+}
+
+// Open loads the secrets file, a missing file is an empty store.
+// All secrets are checked with the key
+
+func Open(path string, key []byte) (*Store, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("secrets key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{
+		path:    path,
+		aead:    aead,
+		entries: map[string]entry{},
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &s.entries); err != nil {
+		return nil, fmt.Errorf("secrets file %s: %w", path, err)
+	}
+	for name, e := range s.entries {
+		if _, err := s.decrypt(e.Value); err != nil {
+			return nil, fmt.Errorf("secret %s: %w", name, err)
+		}
+	}
+
+	return s, nil
+}
+
+// ValidateName checks the name of a secret, names are used in
+// references {{secret:NAME}}
+
+func ValidateName(name string) error {
+	if !nameRegex.MatchString(name) {
+		return fmt.Errorf("invalid secret name %q", name)
+	}
+	return nil
+}
+
+// Set creates or replaces the secret and saves the file
+
+func (s *Store) Set(name, value string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+
+	encrypted, err := s.encrypt(value)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, existed := s.entries[name]
+	s.entries[name] = entry{
+		Value:     encrypted,
+		UpdatedAt: time.Now().Unix(),
+	}
+	if err := s.save(); err != nil {
+		if existed {
+			s.entries[name] = prev
+		} else {
+			delete(s.entries, name)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *Store) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, exists := s.entries[name]
+	if !exists {
+		return ErrSecretNotFound
+	}
+	delete(s.entries, name)
+	if err := s.save(); err != nil {
+		s.entries[name] = prev
+		return err
+	}
+	return nil
+}
+
+// Get returns the decrypted value of the secret
+
+func (s *Store) Get(name string) (string, error) {
+	s.mu.RLock()
+	e, exists := s.entries[name]
+	s.mu.RUnlock()
+
+	if !exists {
+		return "", ErrSecretNotFound
+	}
+	return s.decrypt(e.Value)
+}
+
+// List returns secrets sorted by name, values are not returned
+
+func (s *Store) List() []Info {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	infos := make([]Info, 0, len(s.entries))
+	for name, e := range s.entries {
+		infos = append(infos, Info{Name: name, UpdatedAt: e.UpdatedAt})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
+// WARN: BEFORE CALLING THIS, PLS THINK ABOUT TAKE STORE MUTEX
+
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, s.path)
+}
+
+func (s *Store) encrypt(value string) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := s.aead.Seal(nonce, nonce, []byte(value), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (s *Store) decrypt(encrypted string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", err
+	}
+
+	n := s.aead.NonceSize()
+	if len(data) < n {
+		return "", ErrWrongKey
+	}
+	value, err := s.aead.Open(nil, data[:n], data[n:], nil)
+	if err != nil {
+		return "", ErrWrongKey
+	}
+	return string(value), nil
+}
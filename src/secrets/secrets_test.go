@@ -0,0 +1,180 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, KeySize)
+}
+
+func TestLoadKey(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(testKey(1))
+	if got, err := LoadKey("", key); err != nil || !bytes.Equal(got, testKey(1)) {
+		t.Errorf("LoadKey: got %v (%v)", got, err)
+	}
+
+	keyFile := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(keyFile, []byte(key+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := LoadKey(keyFile, ""); err != nil || !bytes.Equal(got, testKey(1)) {
+		t.Errorf("LoadKey from file: got %v (%v)", got, err)
+	}
+
+	// Passphrases and short keys are rejected
+	for _, material := range []string{"", "correct horse battery staple", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		if _, err := LoadKey("", material); err == nil {
+			t.Errorf("Expected error for key %q", material)
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.json")
+
+	if _, err := Open(path, []byte("key")); err == nil {
+		t.Error("Expected error for a short key")
+	}
+
+	s, err := Open(path, testKey(1))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := s.Set("db_password", "hunter2"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := s.Set("bad name", "x"); err == nil {
+		t.Error("Expected error for invalid name")
+	}
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "hunter2") {
+		t.Errorf("Expected the value to be encrypted, got %s", data)
+	}
+
+	s, err = Open(path, testKey(1))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if v, err := s.Get("db_password"); err != nil || v != "hunter2" {
+		t.Errorf("Expected hunter2, got %q (%v)", v, err)
+	}
+	if infos := s.List(); len(infos) != 1 || infos[0].Name != "db_password" {
+		t.Errorf("Unexpected list %v", infos)
+	}
+
+	if _, err := Open(path, testKey(2)); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("Expected ErrWrongKey, got %v", err)
+	}
+
+	if err := s.Delete("db_password"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := s.Get("db_password"); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Expected ErrSecretNotFound, got %v", err)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	lookup := func(name string) (string, error) {
+		if name == "token" {
+			return "abc", nil
+		}
+		return "", ErrSecretNotFound
+	}
+
+	used := map[string]string{}
+	got, err := Expand("curl -H 'X: {{secret:token}}' {{ secret:token }}", lookup, used)
+	if err != nil {
+		t.Fatalf("Expand failed: %v", err)
+	}
+	if got != "curl -H 'X: abc' abc" {
+		t.Errorf("Unexpected expansion %q", got)
+	}
+	if used["token"] != "abc" {
+		t.Errorf("Expected token in used, got %v", used)
+	}
+
+	if _, err := Expand("{{secret:missing}}", lookup, used); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Expected ErrSecretNotFound, got %v", err)
+	}
+}
+
+func TestExpandCommand(t *testing.T) {
+	// Quotes, variables, separators and spaces are not parsed by the shell
+	password := `it's "a" $HOME; echo injected ` + "`id`"
+	lookup := func(name string) (string, error) {
+		switch name {
+		case "db.password":
+			return password, nil
+		case "db_password":
+			return "other", nil
+		}
+		return "", ErrSecretNotFound
+	}
+
+	used := map[string]string{}
+	cmd, env, err := expandCommand(
+		`printf '%s|%s|%s' {{secret:db.password}} "x{{secret:db_password}} \"y\"" {{secret:db.password}}`,
+		lookup,
+		used,
+		"linux",
+	)
+	if err != nil {
+		t.Fatalf("ExpandCommand failed: %v", err)
+	}
+	if strings.Contains(cmd, "it's") || len(env) != 2 {
+		t.Fatalf("Unexpected expansion %q, env %v", cmd, env)
+	}
+	if used["db.password"] != password {
+		t.Errorf("Expected db.password in used, got %v", used)
+	}
+
+	c := exec.Command("sh", "-c", cmd)
+	c.Env = append(os.Environ(), env...)
+	out, err := c.Output()
+	if err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+	if want := password + `|xother "y"|` + password; string(out) != want {
+		t.Errorf("Output: got %q, want %q", out, want)
+	}
+
+	if _, _, err := expandCommand("echo {{secret:missing}}", lookup, used, "linux"); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Expected ErrSecretNotFound, got %v", err)
+	}
+}
+
+func TestExpandCommandRefused(t *testing.T) {
+	lookup := func(name string) (string, error) { return "value", nil }
+
+	tests := []struct {
+		cmd  string
+		goos string
+		err  error
+	}{
+		{`echo '{{secret:a}}'`, "linux", ErrQuotedRef},
+		{`echo 'x "{{secret:a}}" y'`, "linux", ErrQuotedRef},
+		{`echo "it's" '{{secret:a}}'`, "linux", ErrQuotedRef},
+		{`echo {{secret:a}}`, "windows", ErrNoWindowsRefs},
+		{`echo 'x'{{secret:a}}'y'`, "linux", nil},
+		{`echo \'{{secret:a}}`, "linux", nil},
+		{`echo "it's {{secret:a}}"`, "linux", nil},
+		{`echo plain`, "windows", nil},
+	}
+
+	for _, tt := range tests {
+		_, _, err := expandCommand(tt.cmd, lookup, map[string]string{}, tt.goos)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s on %s: expected %v, got %v", tt.cmd, tt.goos, tt.err, err)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package secrets
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// NOTE: References to secrets in commands and env of jobs:
+// {{secret:NAME}} is replaced with the value at execution time
+
+var refRegex = regexp.MustCompile(`\{\{\s*secret:([A-Za-z0-9_.-]+)\s*\}\}`)
+
+// Prefix of env variables with values of secrets referenced in commands
+const EnvPrefix = "CRONSHROOM_SECRET_"
+
+// Expand replaces references in s with values of secrets, values
+// are added to used (e.g. to mask them in logs)
+
+func Expand(
+	s string,
+	lookup func(name string) (string, error),
+	used map[string]string,
+) (string, error) {
+	var expandErr error
+	expanded := refRegex.ReplaceAllStringFunc(s, func(ref string) string {
+		name := refRegex.FindStringSubmatch(ref)[1]
+		value, err := lookup(name)
+		if err != nil {
+			if expandErr == nil {
+				expandErr = fmt.Errorf("secret %s: %w", name, err)
+			}
+			return ref
+		}
+		used[name] = value
+		return value
+	})
+	return expanded, expandErr
+}
+
+// ExpandCommand replaces references in the shell command with quoted
+// references to env variables and returns the variables with values
+// of secrets. So values are not parsed by the shell and are not seen
+// in arguments of the process
+
+func ExpandCommand(
+	cmd string,
+	lookup func(name string) (string, error),
+	used map[string]string,
+) (string, []string, error) {
+	return expandCommand(cmd, lookup, used, runtime.GOOS)
+}
+
+func expandCommand(
+	cmd string,
+	lookup func(name string) (string, error),
+	used map[string]string,
+	goos string,
+) (string, []string, error) {
+	matches := refRegex.FindAllStringSubmatchIndex(cmd, -1)
+	if len(matches) == 0 {
+		return cmd, nil, nil
+	}
+	// cmd expands %VAR% before it parses the command, so
+	// values of secrets would be parsed
+	if goos == "windows" {
+		return "", nil, ErrNoWindowsRefs
+	}
+
+	vars := map[string]string{}
+	var env []string
+	var b strings.Builder
+	var q shellQuotes
+	prev := 0
+
+	for _, m := range matches {
+		q.scan(cmd[prev:m[0]])
+		b.WriteString(cmd[prev:m[0]])
+		prev = m[1]
+
+		name := cmd[m[2]:m[3]]
+		if q.single {
+			return "", nil, fmt.Errorf("secret %s: %w", name, ErrQuotedRef)
+		}
+
+		variable, exists := vars[name]
+		if !exists {
+			value, err := lookup(name)
+			if err != nil {
+				return "", nil, fmt.Errorf("secret %s: %w", name, err)
+			}
+			used[name] = value
+
+			variable = envName(name, len(vars))
+			vars[name] = variable
+			env = append(env, variable+"="+value)
+		}
+
+		// Inside double quotes the reference is already quoted
+		if q.double {
+			b.WriteString("${" + variable + "}")
+		} else {
+			b.WriteString(`"${` + variable + `}"`)
+		}
+	}
+	b.WriteString(cmd[prev:])
+
+	return b.String(), env, nil
+}
+
+// shellQuotes tracks quotes of a POSIX shell command
+
+type shellQuotes struct {
+	single, double, escaped bool
+}
+
+func (q *shellQuotes) scan(s string) {
+	for _, r := range s {
+		switch {
+		case q.escaped:
+			q.escaped = false
+		case q.single:
+			q.single = r != '\''
+		case r == '\\':
+			q.escaped = true
+		case r == '"':
+			q.double = !q.double
+		case r == '\'' && !q.double:
+			q.single = true
+		}
+	}
+}
+
+// envName returns the name of the env variable of the secret, the
+// index of the secret in the command keeps names unique (a.b, a_b)
+
+func envName(name string, index int) string {
+	sanitized := strings.Map(func(r rune) rune {
+		if r == '.' || r == '-' {
+			return '_'
+		}
+		return r
+	}, strings.ToUpper(name))
+	return fmt.Sprintf("%s%d_%s", EnvPrefix, index, sanitized)
+}
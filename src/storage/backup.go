@@ -0,0 +1,308 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NOTE: Database snapshots (backups) with rotation
+
+const (
+	snapshotPrefix     = "snapshot-"
+	snapshotTimeLayout = "20060102-150405.000"
+)
+
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+type BackupOptions struct {
+	Dir     string
+	Backend string
+	// Take a snapshot after every EveryChanges job changes, 0 - never
+	EveryChanges uint
+	// Retention, 0 - unlimited. The newest snapshot is always kept
+	MaxCount uint
+	MaxAge   time.Duration
+}
+
+type Snapshot struct {
+	Name    string    `json:"name"`
+	Backend string    `json:"backend"`
+	Time    time.Time `json:"time"`
+	Size    int64     `json:"size"`
+}
+
+type Backups struct {
+	store Store
+	opts  BackupOptions
+
+	mu sync.Mutex
+	// Job changes since the last snapshot
+	changes uint
+}
+
+func NewBackups(store Store, opts BackupOptions) (*Backups, error) {
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &Backups{
+		store: store,
+		opts:  opts,
+	}, nil
+}
+
+func (b *Backups) Dir() string {
+	return b.opts.Dir
+}
+
+// Create takes a snapshot of the store and removes outdated snapshots
+
+func (b *Backups) Create() (Snapshot, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.create()
+}
+
+func (b *Backups) create() (Snapshot, error) {
+	now := time.Now()
+	name := snapshotPrefix +
+		now.Format(snapshotTimeLayout) +
+		backendExt(b.opts.Backend)
+	path := filepath.Join(b.opts.Dir, name)
+
+	if err := b.store.Backup(path); err != nil {
+		return Snapshot{}, err
+	}
+	b.changes = 0
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	if err := b.prune(); err != nil {
+		return Snapshot{}, err
+	}
+
+	return Snapshot{
+		Name:    name,
+		Backend: b.opts.Backend,
+		Time:    now,
+		Size:    info.Size(),
+	}, nil
+}
+
+// AddChanges counts job changes written to the store and takes
+// a snapshot when EveryChanges is reached. Returns nil if no
+// snapshot is taken
+
+func (b *Backups) AddChanges(n int) (*Snapshot, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.changes += uint(n)
+	if b.opts.EveryChanges == 0 || b.changes < b.opts.EveryChanges {
+		return nil, nil
+	}
+
+	snapshot, err := b.create()
+	if err != nil {
+		return nil, err
+	}
+
+	return &snapshot, nil
+}
+
+// CreateScheduled takes a snapshot if the store was changed since the
+// last one or if there are no snapshots yet. Returns nil if no
+// snapshot is taken
+
+func (b *Backups) CreateScheduled() (*Snapshot, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.changes == 0 {
+		snapshots, err := b.list()
+		if err != nil {
+			return nil, err
+		}
+		if len(snapshots) != 0 {
+			return nil, nil
+		}
+	}
+
+	snapshot, err := b.create()
+	if err != nil {
+		return nil, err
+	}
+
+	return &snapshot, nil
+}
+
+// List returns snapshots sorted from the newest to the oldest
+
+func (b *Backups) List() ([]Snapshot, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.list()
+}
+
+func (b *Backups) list() ([]Snapshot, error) {
+	return ListSnapshots(b.opts.Dir)
+}
+
+func ListSnapshots(dir string) ([]Snapshot, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := []Snapshot{}
+	for _, e := range entries {
+		snapshot, ok := parseSnapshotName(e.Name())
+		if !ok || e.IsDir() {
+			continue
+		}
+
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		snapshot.Size = info.Size()
+
+		snapshots = append(snapshots, snapshot)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Time.After(snapshots[j].Time)
+	})
+
+	return snapshots, nil
+}
+
+// Load reads the database from the snapshot. The snapshot is not
+// changed: an older version is migrated only in memory
+
+func (b *Backups) Load(name string) (*Database, error) {
+	snapshot, ok := parseSnapshotName(name)
+	if !ok || filepath.Base(name) != name {
+		return nil, fmt.Errorf("%w: %s", ErrSnapshotNotFound, name)
+	}
+
+	path := filepath.Join(b.opts.Dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrSnapshotNotFound, name)
+	}
+
+	var data []byte
+	var err error
+	if snapshot.Backend == BackendBolt {
+		data, err = readBoltFile(path)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	migrated, _, err := MigrateDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	return Deserialize(migrated)
+}
+
+// Restore writes the snapshot to the store, the current content
+// of the store is saved as a new snapshot first. Use it only when
+// nobody else syncs the database with the store
+
+func (b *Backups) Restore(name string) (*Database, error) {
+	db, err := b.Load(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := b.Create(); err != nil {
+		return nil, err
+	}
+
+	db.Metadata.UpdatedAt = time.Now().Unix()
+	if err := b.store.Sync(db, nil); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func (b *Backups) prune() error {
+	snapshots, err := b.list()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for i, s := range snapshots {
+		// The newest one is always kept
+		if i == 0 {
+			continue
+		}
+
+		tooMany := b.opts.MaxCount != 0 && uint(i) >= b.opts.MaxCount
+		tooOld := b.opts.MaxAge != 0 && now.Sub(s.Time) > b.opts.MaxAge
+		if !tooMany && !tooOld {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(b.opts.Dir, s.Name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func parseSnapshotName(name string) (Snapshot, bool) {
+	if !strings.HasPrefix(name, snapshotPrefix) {
+		return Snapshot{}, false
+	}
+
+	var backend string
+	switch filepath.Ext(name) {
+	case backendExt(BackendJSON):
+		backend = BackendJSON
+	case backendExt(BackendBolt):
+		backend = BackendBolt
+	default:
+		return Snapshot{}, false
+	}
+
+	stamp := strings.TrimSuffix(
+		strings.TrimPrefix(name, snapshotPrefix),
+		filepath.Ext(name),
+	)
+	t, err := time.ParseInLocation(snapshotTimeLayout, stamp, time.Local)
+	if err != nil {
+		return Snapshot{}, false
+	}
+
+	return Snapshot{
+		Name:    name,
+		Backend: backend,
+		Time:    t,
+	}, true
+}
+
+func backendExt(backend string) string {
+	if backend == BackendBolt {
+		return ".db"
+	}
+	return ".json"
+}
@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+func TestBackups(t *testing.T) {
+	dir := t.TempDir()
+
+	store := NewJSONStore(filepath.Join(dir, "db.json"))
+	db := testStoreDatabase(t)
+	if err := store.Sync(db, nil); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	backups, err := NewBackups(store, BackupOptions{
+		Dir:          filepath.Join(dir, "backups"),
+		Backend:      BackendJSON,
+		EveryChanges: 2,
+		MaxCount:     2,
+	})
+	if err != nil {
+		t.Fatalf("NewBackups failed: %v", err)
+	}
+
+	first, err := backups.Create()
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	// Unchanged store, the scheduled snapshot is skipped
+	if snapshot, err := backups.CreateScheduled(); err != nil || snapshot != nil {
+		t.Fatalf("Unexpected scheduled snapshot: %v, %v", snapshot, err)
+	}
+
+	db.DeleteJob("job1")
+	if err := store.Sync(db, db.Changes()); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if snapshot, err := backups.AddChanges(1); err != nil || snapshot != nil {
+		t.Fatalf("Unexpected snapshot before threshold: %v, %v", snapshot, err)
+	}
+
+	for range 2 {
+		// Snapshot names have millisecond precision
+		time.Sleep(2 * time.Millisecond)
+		if snapshot, err := backups.AddChanges(2); err != nil || snapshot == nil {
+			t.Fatalf("Expected snapshot after threshold: %v, %v", snapshot, err)
+		}
+	}
+
+	snapshots, err := backups.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(snapshots) != 2 {
+		t.Fatalf("Expected 2 snapshots after pruning, got %d", len(snapshots))
+	}
+	for _, s := range snapshots {
+		if s.Name == first.Name {
+			t.Errorf("The oldest snapshot is not pruned")
+		}
+	}
+
+	// The last snapshot has 2 jobs, the restore brings them back
+	if _, err := backups.Restore(snapshots[0].Name); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	jobs, err := store.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(jobs) != 2 {
+		t.Errorf("Expected 2 jobs after restore, got %d", len(jobs))
+	}
+
+	if _, err := backups.Load("../db.json"); err == nil {
+		t.Errorf("Expected error for a path outside of backup directory")
+	}
+}
+
+func TestBackupsLoadDoesNotChangeSnapshots(t *testing.T) {
+	tests := []struct {
+		backend string
+		file    string
+	}{
+		{backend: BackendJSON, file: "db.json"},
+		{backend: BackendBolt, file: "db.db"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.backend, func(t *testing.T) {
+			dir := t.TempDir()
+			store, err := OpenStore(tt.backend, filepath.Join(dir, tt.file))
+			if err != nil {
+				t.Fatalf("OpenStore failed: %v", err)
+			}
+			defer func() { _ = store.Close() }()
+			if err := store.Sync(testStoreDatabase(t), nil); err != nil {
+				t.Fatalf("Sync failed: %v", err)
+			}
+
+			backups, err := NewBackups(store, BackupOptions{
+				Dir:     filepath.Join(dir, "backups"),
+				Backend: tt.backend,
+			})
+			if err != nil {
+				t.Fatalf("NewBackups failed: %v", err)
+			}
+			snapshot, err := backups.Create()
+			if err != nil {
+				t.Fatalf("Create failed: %v", err)
+			}
+
+			// The snapshot of an older version
+			path := filepath.Join(backups.Dir(), snapshot.Name)
+			if tt.backend == BackendBolt {
+				bdb, err := bbolt.Open(path, 0o644, nil)
+				if err != nil {
+					t.Fatalf("bbolt.Open failed: %v", err)
+				}
+				err = bdb.Update(func(tx *bbolt.Tx) error {
+					return putBoltHeader(tx, "1.3", Metadata{UpdatedAt: 1})
+				})
+				_ = bdb.Close()
+				if err != nil {
+					t.Fatalf("putBoltHeader failed: %v", err)
+				}
+			} else {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				data = bytes.Replace(data, []byte(`"`+SchemaVersion+`"`), []byte(`"1.3"`), 1)
+				if err := os.WriteFile(path, data, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			before, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			db, err := backups.Load(snapshot.Name)
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if db.Version != SchemaVersion || len(db.Jobs) != 3 {
+				t.Errorf("Unexpected loaded database: version %s, %d jobs", db.Version, len(db.Jobs))
+			}
+
+			after, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(before, after) {
+				t.Error("Snapshot is changed by Load")
+			}
+			entries, err := os.ReadDir(backups.Dir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 {
+				t.Errorf("Expected only the snapshot in the backup directory, got %d files", len(entries))
+			}
+		})
+	}
+}
@@ -0,0 +1,352 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// NOTE: Embedded transactional key-value store (bbolt)
+
+var (
+	boltMetaBucket = []byte("meta")
+	boltJobsBucket = []byte("jobs")
+	boltHeaderKey  = []byte("header")
+)
+
+// The part of the database stored apart from jobs
+
+type boltHeader struct {
+	Version  string   `json:"version"`
+	Metadata Metadata `json:"metadata"`
+}
+
+type BoltStore struct {
+	db   *bbolt.DB
+	path string
+
+	// Set if the store was upgraded from an older version on open
+	migrated *MigrationInfo
+}
+
+var _ Store = (*BoltStore)(nil)
+
+// NewBoltStore opens the store file, creating it if necessary.
+// The file is locked for the lifetime of the store, so only one
+// process can work with it at a time
+
+func NewBoltStore(path string) (*BoltStore, error) {
+	bdb, err := bbolt.Open(path, 0o644, &bbolt.Options{
+		Timeout: time.Second,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = bdb.Update(func(tx *bbolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(boltMetaBucket)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists(boltJobsBucket); err != nil {
+			return err
+		}
+
+		if meta.Get(boltHeaderKey) != nil {
+			return nil
+		}
+		empty := New()
+		return putBoltHeader(tx, empty.Version, empty.Metadata)
+	})
+	if err != nil {
+		_ = bdb.Close()
+		return nil, err
+	}
+
+	s := &BoltStore{db: bdb, path: path}
+	if err := s.migrate(); err != nil {
+		_ = bdb.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// migrate upgrades the store to SchemaVersion. The whole database is
+// assembled to a document, upgraded as the JSON file would be and
+// written back, a copy of the store file is kept as a backup
+
+func (s *BoltStore) migrate() error {
+	var doc []byte
+	var fromVersion string
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		fromVersion, err = boltVersion(tx)
+		if err != nil {
+			return err
+		}
+		if cmp, err := compareVersions(fromVersion, SchemaVersion); err != nil || cmp == 0 {
+			return err
+		}
+
+		doc, err = boltDocument(tx)
+		return err
+	})
+	if err != nil || len(doc) == 0 {
+		return err
+	}
+
+	migrated, _, err := MigrateDocument(doc)
+	if err != nil {
+		return err
+	}
+
+	db, err := Deserialize(migrated)
+	if err != nil {
+		return err
+	}
+
+	backupPath := migrationBackupPath(s.path, fromVersion)
+	if err := s.Backup(backupPath); err != nil {
+		return err
+	}
+
+	if err := s.Sync(db, nil); err != nil {
+		return err
+	}
+
+	s.migrated = &MigrationInfo{
+		From:   fromVersion,
+		To:     db.Version,
+		Backup: backupPath,
+	}
+
+	return nil
+}
+
+// boltVersion returns the schema version of the store
+
+func boltVersion(tx *bbolt.Tx) (string, error) {
+	var header boltHeader
+	data := tx.Bucket(boltMetaBucket).Get(boltHeaderKey)
+	if err := decodeStrict(data, &header); err != nil {
+		return "", err
+	}
+	return header.Version, nil
+}
+
+// boltDocument assembles the whole store to the document
+// of the JSON file
+
+func boltDocument(tx *bbolt.Tx) ([]byte, error) {
+	var header boltHeader
+	if err := decodeStrict(tx.Bucket(boltMetaBucket).Get(boltHeaderKey), &header); err != nil {
+		return nil, err
+	}
+
+	metadata, err := json.Marshal(header.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	version, err := json.Marshal(header.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc bytes.Buffer
+	doc.WriteString(`{"version":`)
+	doc.Write(version)
+	doc.WriteString(`,"metadata":`)
+	doc.Write(metadata)
+	doc.WriteString(`,"jobs":{`)
+	first := true
+	err = tx.Bucket(boltJobsBucket).ForEach(func(k, v []byte) error {
+		name, err := json.Marshal(string(k))
+		if err != nil {
+			return err
+		}
+		if !first {
+			doc.WriteByte(',')
+		}
+		first = false
+		doc.Write(name)
+		doc.WriteByte(':')
+		doc.Write(v)
+		return nil
+	})
+	doc.WriteString(`}}`)
+	return doc.Bytes(), err
+}
+
+// readBoltFile reads the document of the bolt file without
+// changes: it is opened read-only and is not migrated
+
+func readBoltFile(path string) ([]byte, error) {
+	bdb, err := bbolt.Open(path, 0o444, &bbolt.Options{
+		Timeout:  time.Second,
+		ReadOnly: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = bdb.Close() }()
+
+	var doc []byte
+	err = bdb.View(func(tx *bbolt.Tx) error {
+		if tx.Bucket(boltMetaBucket) == nil || tx.Bucket(boltJobsBucket) == nil {
+			return errors.New("not a database file")
+		}
+		doc, err = boltDocument(tx)
+		return err
+	})
+	return doc, err
+}
+
+func (s *BoltStore) Load() (*Database, error) {
+	db := &Database{Jobs: Jobs{}}
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		var header boltHeader
+		data := tx.Bucket(boltMetaBucket).Get(boltHeaderKey)
+		if err := decodeStrict(data, &header); err != nil {
+			return err
+		}
+		db.Version = header.Version
+		db.Metadata = header.Metadata
+
+		return tx.Bucket(boltJobsBucket).ForEach(func(k, v []byte) error {
+			var j Job
+			if err := decodeStrict(v, &j); err != nil {
+				return err
+			}
+			db.Jobs[string(k)] = &j
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	db.Migrated, s.migrated = s.migrated, nil
+
+	return db, nil
+}
+
+// WARN: BEFORE CALLING THIS, PLS THINK ABOUT TAKE DB MUTEX
+
+func (s *BoltStore) Sync(db *Database, changed []string) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		if err := putBoltHeader(tx, db.Version, db.Metadata); err != nil {
+			return err
+		}
+
+		jobs := tx.Bucket(boltJobsBucket)
+
+		if changed == nil {
+			// Full sync: drop keys which are not in the database
+			var stale [][]byte
+			err := jobs.ForEach(func(k, _ []byte) error {
+				if _, exists := db.Jobs[string(k)]; !exists {
+					stale = append(stale, bytes.Clone(k))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for _, k := range stale {
+				if err := jobs.Delete(k); err != nil {
+					return err
+				}
+			}
+
+			for jk := range db.Jobs {
+				changed = append(changed, jk)
+			}
+		}
+
+		for _, jk := range changed {
+			j, exists := db.Jobs[jk]
+			if !exists {
+				if err := jobs.Delete([]byte(jk)); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := putBoltJob(jobs, jk, j); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *BoltStore) List() (Jobs, error) {
+	db, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	return db.Jobs, nil
+}
+
+// Watch never reports changes: the file is locked
+// by this process, nobody else can change it
+
+func (s *BoltStore) Watch(
+	ctx context.Context,
+	interval time.Duration,
+) <-chan struct{} {
+	return make(chan struct{})
+}
+
+func (s *BoltStore) Backup(path string) error {
+	return s.db.View(func(tx *bbolt.Tx) error {
+		return tx.CopyFile(path, 0o644)
+	})
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+func putBoltHeader(tx *bbolt.Tx, version string, metadata Metadata) error {
+	data, err := json.Marshal(boltHeader{
+		Version:  version,
+		Metadata: metadata,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Bucket(boltMetaBucket).Put(boltHeaderKey, data)
+}
+
+func touchBoltHeader(tx *bbolt.Tx) error {
+	var header boltHeader
+	data := tx.Bucket(boltMetaBucket).Get(boltHeaderKey)
+	if err := decodeStrict(data, &header); err != nil {
+		return err
+	}
+
+	header.Metadata.UpdatedAt = time.Now().Unix()
+	return putBoltHeader(tx, header.Version, header.Metadata)
+}
+
+func putBoltJob(jobs *bbolt.Bucket, name string, j *Job) error {
+	stored := *j
+	stored.Config.Status = j.Config.Status.Configured()
+
+	data, err := json.Marshal(&stored)
+	if err != nil {
+		return err
+	}
+
+	return jobs.Put([]byte(name), data)
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"cronshroom/calendars"
+)
+
+// NOTE: Calendars of jobs: scheduled runs are skipped when the time
+// is in one of ExcludeCalendars or is not in any of OnlyCalendars
+
+var ErrNoCalendars = errors.New("calendars are not configured")
+
+// SetCalendars enables calendars of jobs, it must be
+// called before jobs are registered in the scheduler
+
+func (db *Database) SetCalendars(store *calendars.Store) {
+	db.calendars = store
+}
+
+// Calendars returns the calendars store, nil if it is not configured
+
+func (db *Database) Calendars() *calendars.Store {
+	return db.calendars
+}
+
+func validateCalendars(c *JobConfig) error {
+	for _, names := range [][]string{c.ExcludeCalendars, c.OnlyCalendars} {
+		for _, name := range names {
+			if err := calendars.ValidateName(name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// CheckCalendars checks that calendars of the job are in the store,
+// a job with calendars is refused if calendars are not configured
+
+func (db *Database) CheckCalendars(c *JobConfig) error {
+	return checkCalendars(db.calendars, c)
+}
+
+func checkCalendars(store *calendars.Store, c *JobConfig) error {
+	for _, names := range [][]string{c.ExcludeCalendars, c.OnlyCalendars} {
+		for _, name := range names {
+			if store == nil {
+				return ErrNoCalendars
+			}
+			if !store.Exists(name) {
+				return fmt.Errorf("calendar %q: %w", name, calendars.ErrCalendarNotFound)
+			}
+		}
+	}
+	return nil
+}
+
+// calendarSkipFunc returns the function which reports whether a run
+// of the job is skipped by its calendars, nil if the job has no
+// calendars. A run is skipped if a calendar is not found, since
+// it is not known whether the run is allowed
+
+// WARN: BEFORE CALLING THIS, PLS THINK ABOUT TAKE DB MUTEX
+
+func (db *Database) calendarSkipFunc(jobKey string) func(*slog.Logger) bool {
+	c := db.Jobs[jobKey].Config
+	exclude, only := c.ExcludeCalendars, c.OnlyCalendars
+	if len(exclude) == 0 && len(only) == 0 {
+		return nil
+	}
+
+	return func(logger *slog.Logger) bool {
+		if err := checkCalendars(db.calendars, &c); err != nil {
+			logger.Warn("Run is skipped, calendar of job is not available",
+				"name", jobKey,
+				"error", err,
+			)
+			return true
+		}
+
+		now := time.Now()
+		for _, name := range exclude {
+			if in, _ := db.calendars.Contains(name, now); in {
+				logger.Info("Run is skipped by calendar",
+					"name", jobKey,
+					"calendar", name,
+					"mode", "exclude",
+				)
+				return true
+			}
+		}
+
+		if len(only) == 0 {
+			return false
+		}
+		for _, name := range only {
+			if in, _ := db.calendars.Contains(name, now); in {
+				return false
+			}
+		}
+		logger.Info("Run is skipped by calendar",
+			"name", jobKey,
+			"calendar", only,
+			"mode", "only",
+		)
+		return true
+	}
+}
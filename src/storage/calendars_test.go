@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cronshroom/calendars"
+)
+
+func TestSkipFunc(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	store, err := calendars.Open(filepath.Join(t.TempDir(), "calendars.json"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	always := &calendars.Calendar{Weekly: []calendars.WeeklyRange{{From: "00:00", To: "24:00"}}}
+	never := &calendars.Calendar{Dates: []string{"2001-01-01"}}
+	if err := store.Set("always", always); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("never", never); err != nil {
+		t.Fatal(err)
+	}
+
+	db := New()
+	db.SetCalendars(store)
+
+	tests := []struct {
+		name    string
+		exclude []string
+		only    []string
+		skip    bool
+	}{
+		{"excluded", []string{"never", "always"}, nil, true},
+		{"not excluded", []string{"never"}, nil, false},
+		{"only during", nil, []string{"always"}, false},
+		{"out of only", nil, []string{"never"}, true},
+		{"out of one of only", nil, []string{"never", "always"}, false},
+		{"missing excluded calendar", []string{"missing"}, nil, true},
+		{"missing only calendar", nil, []string{"always", "missing"}, true},
+	}
+
+	for _, tt := range tests {
+		j, _ := ShellJob("", "echo", "0 0 * * * *", 0, 0, 0)
+		j.Config.ExcludeCalendars = tt.exclude
+		j.Config.OnlyCalendars = tt.only
+		db.SetJob(j, "job")
+
+		skip := db.skipFunc("job", logger)
+		if got := skip(context.Background()); got != tt.skip {
+			t.Errorf("%s: skip = %v, want %v", tt.name, got, tt.skip)
+		}
+	}
+
+	j, _ := ShellJob("", "echo", "0 0 * * * *", 0, 0, 0)
+	j.Config.OnlyCalendars = []string{"always", "missing"}
+	if err := db.CheckCalendars(&j.Config); !errors.Is(err, calendars.ErrCalendarNotFound) {
+		t.Errorf("CheckCalendars of a missing calendar = %v", err)
+	}
+	j.Config.OnlyCalendars = []string{"always"}
+	if err := db.CheckCalendars(&j.Config); err != nil {
+		t.Errorf("CheckCalendars failed: %v", err)
+	}
+
+	// Runs of jobs with calendars are skipped without the store
+	noStore := New()
+	noStore.SetJob(j, "job")
+	if err := noStore.CheckCalendars(&j.Config); !errors.Is(err, ErrNoCalendars) {
+		t.Errorf("CheckCalendars without the store = %v", err)
+	}
+	if !noStore.skipFunc("job", logger)(context.Background()) {
+		t.Error("Run is not skipped without the calendars store")
+	}
+
+	// Skipped runs are not run as missed occurrences after a restart
+	ft, err := LoadFireTimes(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatalf("LoadFireTimes failed: %v", err)
+	}
+	db.SetFireTimes(ft)
+	excluded, _ := ShellJob("", "echo", "0 0 * * * *", 0, 0, 0)
+	excluded.Config.ExcludeCalendars = []string{"always"}
+	db.SetJob(excluded, "job")
+	if !db.skipFunc("job", logger)(context.Background()) {
+		t.Error("Run is not skipped by the calendar")
+	}
+	if _, exists := ft.Last("job"); !exists {
+		t.Error("Fire time of the skipped run is not recorded")
+	}
+
+	j, _ = ShellJob("", "echo", "0 0 * * * *", 0, 0, 0)
+	db.SetJob(j, "plain")
+	skip := db.skipFunc("plain", logger)
+	if skip(context.Background()) {
+		t.Error("Run of a job without calendars is skipped")
+	}
+
+	if _, err := db.Pause(time.Time{}, "migration", false, logger); err != nil {
+		t.Fatalf("Pause failed: %v", err)
+	}
+	if !skip(context.Background()) {
+		t.Error("Run is not skipped while the scheduler is paused")
+	}
+	db.Resume(logger)
+	if skip(context.Background()) {
+		t.Error("Run is skipped after Resume")
+	}
+}
@@ -1,8 +1,17 @@
 package storage
 
 import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
+	"cronshroom/extjob"
+
 	"github.com/reugn/go-quartz/quartz"
 )
 
@@ -15,6 +24,169 @@ type JobConfig struct {
 	Timeout        uint      `json:"timeout"`
 	MaxRetries     uint      `json:"max_retries"`
 	RetryInterval  uint      `json:"retry_interval"`
+	// Environment variables added to the environment of the program
+	Env map[string]string `json:"env,omitempty"`
+	// User and group (names or ids) to run the program as, the
+	// program must be run as root. Empty - the user of the program
+	User  string `json:"user,omitempty"`
+	Group string `json:"group,omitempty"`
+	// Limits of resources of the program, nil - no limits
+	Limits *JobLimits `json:"limits,omitempty"`
+	// Backoff and conditions of retries, nil - retries of
+	// any failure every RetryInterval seconds
+	Retry *RetryConfig `json:"retry,omitempty"`
+	// What to do with occurrences missed while the program was not
+	// running: ignore (default), run_once or run_all (up to
+	// MisfireMaxRuns runs, DefaultMisfireMaxRuns if it is 0)
+	MisfirePolicy  string `json:"misfire_policy,omitempty"`
+	MisfireMaxRuns uint   `json:"misfire_max_runs,omitempty"`
+	// Seconds of the delay of scheduled runs, so jobs with the same
+	// schedule do not start at once: StartSpread is a window for the
+	// stable offset of the job (by its name), StartJitter is the
+	// maximum random delay added to it on every run
+	StartSpread uint `json:"start_spread,omitempty"`
+	StartJitter uint `json:"start_jitter,omitempty"`
+	// Concurrency pool of the job (defined by the program options),
+	// empty - only the global limit. Queued runs with a higher
+	// Priority go first, a run is skipped after QueueMaxWait
+	// seconds in the queue (no maximum - 0 value)
+	Pool         string `json:"pool,omitempty"`
+	Priority     int    `json:"priority,omitempty"`
+	QueueMaxWait uint   `json:"queue_max_wait,omitempty"`
+	// Names of calendars: scheduled runs are skipped in any of
+	// ExcludeCalendars and out of all of OnlyCalendars
+	ExcludeCalendars []string `json:"exclude_calendars,omitempty"`
+	OnlyCalendars    []string `json:"only_calendars,omitempty"`
+}
+
+type RetryConfig struct {
+	// fixed (default) or exponential: RetryInterval is doubled every retry
+	Backoff string `json:"backoff,omitempty"`
+	// Maximum interval of exponential backoff in seconds, 0 - no maximum
+	MaxInterval uint `json:"max_interval,omitempty"`
+	// Part of the interval (0..1) added or subtracted at random
+	Jitter float64 `json:"jitter,omitempty"`
+	// Only these exit codes are retried, all if it is empty
+	ExitCodes []int `json:"exit_codes,omitempty"`
+	// These exit codes are not retried
+	SkipExitCodes []int `json:"skip_exit_codes,omitempty"`
+	// Runs stopped by the timeout are not retried
+	SkipTimeout bool `json:"skip_timeout,omitempty"`
+}
+
+// retryPolicy returns retries for the shell job
+
+func (c *JobConfig) retryPolicy() extjob.RetryPolicy {
+	policy := extjob.RetryPolicy{
+		MaxRetries: int(c.MaxRetries),
+		Interval:   time.Duration(c.RetryInterval) * time.Second,
+	}
+	if r := c.Retry; r != nil {
+		policy.Backoff = r.Backoff
+		policy.MaxInterval = time.Duration(r.MaxInterval) * time.Second
+		policy.Jitter = r.Jitter
+		policy.ExitCodes = r.ExitCodes
+		policy.SkipExitCodes = r.SkipExitCodes
+		policy.SkipTimeout = r.SkipTimeout
+	}
+	return policy
+}
+
+// StartOffset returns the stable delay of scheduled runs of the
+// job, the random delay (up to StartJitter) is added to it
+
+func (c *JobConfig) StartOffset(jobKey string) time.Duration {
+	return extjob.SpreadOffset(jobKey, time.Duration(c.StartSpread)*time.Second)
+}
+
+func (c *JobConfig) startDelay(jobKey string) extjob.StartDelay {
+	return extjob.StartDelay{
+		Offset: c.StartOffset(jobKey),
+		Jitter: time.Duration(c.StartJitter) * time.Second,
+	}
+}
+
+func (r *RetryConfig) validate() error {
+	switch r.Backoff {
+	case "", extjob.BackoffFixed, extjob.BackoffExponential:
+	default:
+		return fmt.Errorf("unknown backoff %q", r.Backoff)
+	}
+
+	if r.Jitter < 0 || r.Jitter > 1 {
+		return fmt.Errorf("jitter %v is out of range 0..1", r.Jitter)
+	}
+
+	for _, code := range append(slices.Clone(r.ExitCodes), r.SkipExitCodes...) {
+		if code < 0 || code > 255 {
+			return fmt.Errorf("exit code %d is out of range 0..255", code)
+		}
+	}
+
+	return nil
+}
+
+// JobLimits are applied on Linux, zero values are not applied
+
+type JobLimits struct {
+	// Address space in bytes
+	Memory uint64 `json:"memory,omitempty"`
+	// CPU time in seconds
+	CPU       uint64 `json:"cpu,omitempty"`
+	OpenFiles uint64 `json:"open_files,omitempty"`
+	// From -20 (the highest priority, root only) to 19
+	Nice int `json:"nice,omitempty"`
+	// Path of the cgroup v2 directory, it is created if it does not
+	// exist. memory.max and cpu.max are written to it if they are set
+	Cgroup          string `json:"cgroup,omitempty"`
+	CgroupMemoryMax string `json:"cgroup_memory_max,omitempty"`
+	CgroupCPUMax    string `json:"cgroup_cpu_max,omitempty"`
+}
+
+var (
+	cgroupMemoryMaxRegex = regexp.MustCompile(`^(max|[0-9]+[KMGkmg]?)$`)
+	cgroupCPUMaxRegex    = regexp.MustCompile(`^(max|[0-9]+)( [0-9]+)?$`)
+)
+
+// extjobLimits returns limits for the shell job
+
+func (c *JobConfig) extjobLimits() extjob.Limits {
+	if c.Limits == nil {
+		return extjob.Limits{}
+	}
+	return extjob.Limits{
+		Memory:          c.Limits.Memory,
+		CPU:             c.Limits.CPU,
+		OpenFiles:       c.Limits.OpenFiles,
+		Nice:            c.Limits.Nice,
+		Cgroup:          c.Limits.Cgroup,
+		CgroupMemoryMax: c.Limits.CgroupMemoryMax,
+		CgroupCPUMax:    c.Limits.CgroupCPUMax,
+	}
+}
+
+func (l *JobLimits) validate() error {
+	if l.Nice < -20 || l.Nice > 19 {
+		return fmt.Errorf("nice %d is out of range -20..19", l.Nice)
+	}
+
+	if l.Cgroup != "" {
+		clean := filepath.Clean(l.Cgroup)
+		if clean != l.Cgroup || !strings.HasPrefix(clean, extjob.CgroupRoot) {
+			return fmt.Errorf("cgroup path %q is not a clean path under %s", l.Cgroup, extjob.CgroupRoot)
+		}
+	}
+	if l.Cgroup == "" && (l.CgroupMemoryMax != "" || l.CgroupCPUMax != "") {
+		return errors.New("cgroup limits are set without the cgroup path")
+	}
+	if l.CgroupMemoryMax != "" && !cgroupMemoryMaxRegex.MatchString(l.CgroupMemoryMax) {
+		return fmt.Errorf("invalid cgroup memory.max %q", l.CgroupMemoryMax)
+	}
+	if l.CgroupCPUMax != "" && !cgroupCPUMaxRegex.MatchString(l.CgroupCPUMax) {
+		return fmt.Errorf("invalid cgroup cpu.max %q", l.CgroupCPUMax)
+	}
+
+	return nil
 }
 
 type Job struct {
@@ -50,3 +222,72 @@ func ShellJob(
 		},
 	}, nil
 }
+
+// Environ returns env of the job as sorted "KEY=value" pairs
+
+func (c *JobConfig) Environ() []string {
+	env := make([]string, 0, len(c.Env))
+	for k, v := range c.Env {
+		env = append(env, k+"="+v)
+	}
+	sort.Strings(env)
+	return env
+}
+
+// ValidateJob checks a job which comes from outside (import, API)
+
+func ValidateJob(name string, j *Job) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("job name is empty")
+	}
+
+	if j == nil {
+		return fmt.Errorf("job %s: empty job", name)
+	}
+
+	if strings.TrimSpace(j.Config.Command) == "" {
+		return fmt.Errorf("job %s: command is empty", name)
+	}
+
+	if err := quartz.ValidateCronExpression(j.Config.CronExpression); err != nil {
+		return fmt.Errorf("job %s: %w", name, err)
+	}
+
+	for k := range j.Config.Env {
+		if k == "" || strings.ContainsAny(k, "= \t\n") {
+			return fmt.Errorf("job %s: invalid env variable name %q", name, k)
+		}
+	}
+
+	for _, v := range []string{j.Config.User, j.Config.Group} {
+		if strings.ContainsAny(v, ": \t\n") {
+			return fmt.Errorf("job %s: invalid user or group %q", name, v)
+		}
+	}
+
+	if err := validateMisfire(&j.Config); err != nil {
+		return fmt.Errorf("job %s: %w", name, err)
+	}
+
+	if err := validatePool(&j.Config); err != nil {
+		return fmt.Errorf("job %s: %w", name, err)
+	}
+
+	if err := validateCalendars(&j.Config); err != nil {
+		return fmt.Errorf("job %s: %w", name, err)
+	}
+
+	if j.Config.Retry != nil {
+		if err := j.Config.Retry.validate(); err != nil {
+			return fmt.Errorf("job %s: %w", name, err)
+		}
+	}
+
+	if j.Config.Limits != nil {
+		if err := j.Config.Limits.validate(); err != nil {
+			return fmt.Errorf("job %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"log/slog"
+
+	"cronshroom/utils"
+)
+
+// NOTE: Log files of jobs: records about runs of a job are
+// written to its own file in addition to the main log
+
+// SetJobLogs enables log files of jobs, it must be
+// called before jobs are registered in the scheduler
+
+func (db *Database) SetJobLogs(jobLogs *utils.JobLogs) {
+	db.jobLogs = jobLogs
+}
+
+// JobLogs returns log files of jobs, nil if they are disabled
+
+func (db *Database) JobLogs() *utils.JobLogs {
+	return db.jobLogs
+}
+
+func (db *Database) jobLogger(jobKey string, logger *slog.Logger) *slog.Logger {
+	if db.jobLogs == nil {
+		return logger
+	}
+
+	jobHandler := db.jobLogs.Logger(jobKey).Handler()
+	if db.redactor != nil {
+		jobHandler = utils.NewRedactHandler(jobHandler, db.redactor)
+	}
+
+	return slog.New(utils.NewMultiHandler(
+		logger.Handler(),
+		jobHandler,
+	))
+}
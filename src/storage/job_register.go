@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"time"
 
@@ -38,28 +39,15 @@ func registerShellJob(
 	logger *slog.Logger,
 ) error {
 	j := db.Jobs[jobKey]
-
-	command := j.Config.Command
-	maxRetries := j.Config.MaxRetries
-	retryInterval := j.Config.RetryInterval
 	cronExpression := j.Config.CronExpression
-	timeout := j.Config.Timeout
-
-	beforeExec := createBeforeExecCallback(db, jobKey, logger)
-	afterExec := createAfterExecCallback(db, jobKey, logger)
 
-	quartzJob := extjob.NewShellJobWithCallbacks(
-		command,
-		time.Duration(timeout)*time.Second,
-		beforeExec,
-		afterExec,
-	)
+	quartzJob := newShellJob(db, jobKey, logger, true)
 
+	// Retries are made by the job, every attempt is a run
 	quartzJobOpts := &quartz.JobDetailOptions{
-		MaxRetries:    int(maxRetries),
-		RetryInterval: time.Duration(retryInterval) * time.Second,
-		Replace:       false,
-		Suspended:     false,
+		MaxRetries: 0,
+		Replace:    false,
+		Suspended:  false,
 	}
 
 	quartzJobDetail := quartz.NewJobDetailWithOptions(
@@ -78,15 +66,102 @@ func registerShellJob(
 		return err
 	}
 
+	if logger.Enabled(context.Background(), slog.LevelDebug) {
+		next, err := quartzCronTrigger.NextFireTime(time.Now().UnixNano())
+		if err == nil {
+			logger.Debug("Job is scheduled",
+				"name", jobKey,
+				"cron_expression", cronExpression,
+				"next_fire_time", time.Unix(0, next),
+			)
+		}
+	}
+
 	return nil
 }
 
+// newShellJob creates the shell job of the job from db. Runs of a
+// scheduled job are skipped (by the pause and calendars), delayed,
+// limited and retried and its fire times are recorded, runs started
+// by hand are not
+
+// WARN: BEFORE CALLING THIS, PLS THINK ABOUT TAKE DB MUTEX
+
+func newShellJob(
+	db *Database,
+	jobKey string,
+	logger *slog.Logger,
+	scheduled bool,
+) *extjob.ShellJob {
+	j := db.Jobs[jobKey]
+
+	beforeExec := createBeforeExecCallback(db, jobKey, logger, scheduled)
+	afterExec := createAfterExecCallback(db, jobKey, logger)
+
+	job := extjob.NewShellJobWithCallbacks(
+		j.Config.Command,
+		time.Duration(j.Config.Timeout)*time.Second,
+		beforeExec,
+		afterExec,
+	)
+	job.SetEnv(j.Config.Environ())
+	job.SetResolver(db.resolveSecrets)
+	job.SetCredential(j.Config.User, j.Config.Group)
+	job.SetLimits(j.Config.extjobLimits())
+	if scheduled {
+		job.SetRetryPolicy(j.Config.retryPolicy())
+		job.SetStartDelay(j.Config.startDelay(jobKey))
+		if db.limiter != nil {
+			job.SetAcquire(db.acquireFunc(jobKey, logger))
+		}
+		job.SetSkip(db.skipFunc(jobKey, logger))
+	}
+	db.registerSecrets(j)
+
+	return job
+}
+
+// skipFunc returns the function which reports whether a run of the
+// job is skipped: by the pause of the scheduler or by calendars of
+// the job. Fire times of skipped runs are recorded, so they are
+// not run as missed occurrences after a restart
+
+// WARN: BEFORE CALLING THIS, PLS THINK ABOUT TAKE DB MUTEX
+
+func (db *Database) skipFunc(
+	jobKey string,
+	logger *slog.Logger,
+) func(context.Context) bool {
+	byCalendars := db.calendarSkipFunc(jobKey)
+
+	return func(context.Context) bool {
+		logger := db.jobLogger(jobKey, logger)
+
+		skip := false
+		switch {
+		case db.paused():
+			logger.Info("Run is skipped, the scheduler is paused", "name", jobKey)
+			skip = true
+		case byCalendars != nil:
+			skip = byCalendars(logger)
+		}
+
+		if skip {
+			db.recordFire(jobKey)
+		}
+		return skip
+	}
+}
+
 func createBeforeExecCallback(
 	db *Database,
 	jobKey string,
 	logger *slog.Logger,
+	scheduled bool,
 ) func(context.Context, *extjob.ShellJob) {
 	return func(ctx context.Context, qj *extjob.ShellJob) {
+		logger := db.jobLogger(jobKey, logger)
+
 		db.Mu.Lock()
 
 		j, exists := db.Jobs[jobKey]
@@ -104,6 +179,19 @@ func createBeforeExecCallback(
 		command := j.Config.Command
 		cronExpression := j.Config.CronExpression
 
+		attempt, attempts := extjob.Attempt(ctx)
+		if scheduled && attempt == 1 {
+			db.recordFire(jobKey)
+		}
+		db.runs.start(
+			extjob.RunID(ctx),
+			jobKey,
+			db.redact(command),
+			extjob.RunCancel(ctx),
+			attempt,
+			attempts,
+		)
+
 		switch j.Config.Status {
 		case StatusEnable:
 			j.Config.Status = StatusActiveDuringEnable
@@ -112,12 +200,23 @@ func createBeforeExecCallback(
 		}
 		db.Mu.Unlock()
 
-		logger.Info("Start command execution",
+		attrs := []any{
 			"name", jobKey,
 			"description", description,
 			"command", command,
 			"cron_expression", cronExpression,
-		)
+		}
+		if attempts > 1 {
+			attrs = append(attrs, "attempt", fmt.Sprintf("%d/%d", attempt, attempts))
+		}
+		if delay := extjob.StartDelayOf(ctx); delay > 0 {
+			attrs = append(attrs, "start_delay", delay.Round(time.Millisecond).String())
+		}
+		if queued := extjob.Queued(ctx); queued > 0 {
+			attrs = append(attrs, "queued", queued.Round(time.Millisecond).String())
+		}
+
+		logger.Info("Start command execution", attrs...)
 	}
 }
 
@@ -127,6 +226,21 @@ func createAfterExecCallback(
 	logger *slog.Logger,
 ) func(context.Context, *extjob.ShellJob) {
 	return func(ctx context.Context, qj *extjob.ShellJob) {
+		logger := db.jobLogger(jobKey, logger)
+
+		status := qj.JobStatus()
+		stdout := qj.Stdout()
+		stderr := qj.Stderr()
+
+		// The run is finished even if the job is deleted meanwhile
+		db.runs.finish(
+			extjob.RunID(ctx),
+			status == extjob.StatusOK,
+			qj.ExitCode(),
+			db.redact(stdout),
+			db.redact(stderr),
+		)
+
 		db.Mu.Lock()
 
 		j, exists := db.Jobs[jobKey]
@@ -152,29 +266,29 @@ func createAfterExecCallback(
 		}
 		db.Mu.Unlock()
 
-		status := qj.JobStatus()
-		stdout := qj.Stdout()
-		stderr := qj.Stderr()
+		attrs := []any{
+			"name", jobKey,
+			"description", description,
+			"command", command,
+			"cron_expression", cronExpression,
+			"Stdout", stdout,
+			"Stderr", stderr,
+		}
+		if limitsHit := qj.LimitsHit(); len(limitsHit) > 0 {
+			attrs = append(attrs, "limits_hit", limitsHit)
+		}
+		if attempt, attempts := extjob.Attempt(ctx); attempts > 1 {
+			attrs = append(attrs, "attempt", fmt.Sprintf("%d/%d", attempt, attempts))
+		}
 
-		switch status {
-		case extjob.StatusOK:
-			logger.Info("Command completed successfully",
-				"name", jobKey,
-				"description", description,
-				"command", command,
-				"cron_expression", cronExpression,
-				"Stdout", stdout,
-				"Stderr", stderr,
-			)
-		case extjob.StatusFailure:
-			logger.Warn("Command failed",
-				"name", jobKey,
-				"description", description,
-				"command", command,
-				"cron_expression", cronExpression,
-				"Stdout", stdout,
-				"Stderr", stderr,
-			)
+		switch retryIn, retry := extjob.RetryIn(ctx); {
+		case status == extjob.StatusOK:
+			logger.Info("Command completed successfully", attrs...)
+		case retry:
+			attrs = append(attrs, "retry_in", retryIn.Round(time.Millisecond).String())
+			logger.Warn("Command failed, it will be retried", attrs...)
+		case status == extjob.StatusFailure:
+			logger.Warn("Command failed", attrs...)
 		}
 	}
 }
@@ -31,6 +31,20 @@ func (js JobStatus) String() string {
 	}
 }
 
+// Configured returns the status set by the user
+// without the "job is running" state
+
+func (js JobStatus) Configured() JobStatus {
+	switch js {
+	case StatusActiveDuringEnable:
+		return StatusEnable
+	case StatusActiveDuringDisable:
+		return StatusDisable
+	default:
+		return js
+	}
+}
+
 func (js JobStatus) MarshalJSON() ([]byte, error) {
 	return json.Marshal(js.String())
 }
@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+)
+
+// NOTE: JSON file store (the whole database in one file)
+
+type JSONStore struct {
+	path string
+
+	// State of the file after the last read or write by this store,
+	// used to distinguish our own writes from foreign ones
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+}
+
+var _ Store = (*JSONStore)(nil)
+
+func NewJSONStore(path string) *JSONStore {
+	return &JSONStore{
+		path: path,
+	}
+}
+
+func (s *JSONStore) Path() string {
+	return s.path
+}
+
+func (s *JSONStore) Load() (*Database, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.load()
+}
+
+func (s *JSONStore) load() (*Database, error) {
+	db, err := LoadFromFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+	s.rememberFileState()
+
+	return db, nil
+}
+
+// WARN: BEFORE CALLING THIS, PLS THINK ABOUT TAKE DB MUTEX
+
+func (s *JSONStore) Sync(db *Database, changed []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.save(db)
+}
+
+func (s *JSONStore) save(db *Database) error {
+	if err := db.SaveToFile(s.path); err != nil {
+		return err
+	}
+	s.rememberFileState()
+
+	return nil
+}
+
+func (s *JSONStore) List() (Jobs, error) {
+	db, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	return db.Jobs, nil
+}
+
+// Watch polls the modification time and the size of the file
+
+func (s *JSONStore) Watch(
+	ctx context.Context,
+	interval time.Duration,
+) <-chan struct{} {
+	changedCh := make(chan struct{}, 1)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if !s.fileStateChanged() {
+				continue
+			}
+
+			select {
+			case changedCh <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	return changedCh
+}
+
+func (s *JSONStore) Backup(path string) error {
+	databaseFileMutex.RLock()
+	defer databaseFileMutex.RUnlock()
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0o644)
+}
+
+func (s *JSONStore) Close() error {
+	return nil
+}
+
+func (s *JSONStore) rememberFileState() {
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime, s.size = info.ModTime(), info.Size()
+	}
+}
+
+func (s *JSONStore) fileStateChanged() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return false
+	}
+
+	if info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return false
+	}
+
+	s.modTime, s.size = info.ModTime(), info.Size()
+	return true
+}
@@ -0,0 +1,307 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/reugn/go-quartz/quartz"
+)
+
+// NOTE: Misfires: occurrences of jobs missed while the program was
+// not running. Last fire times are kept in their own file, so runs
+// do not change the database
+
+const (
+	MisfireIgnore  = "ignore"
+	MisfireRunOnce = "run_once"
+	MisfireRunAll  = "run_all"
+
+	// Default limit of runs of missed occurrences with run_all
+	DefaultMisfireMaxRuns = 10
+)
+
+// Interval of saving the time the program was last alive, it bounds
+// the part of a crash downtime that is not counted as missed
+
+const aliveInterval = time.Minute
+
+// FireTimes are last fire times of jobs, the time the program was
+// last alive and the pause of the scheduler. They are changed in
+// memory and saved by Flush
+
+type FireTimes struct {
+	mu      sync.Mutex
+	path    string
+	times   map[string]int64
+	aliveAt int64
+	pause   PauseState
+	savedAt int64
+	dirty   bool
+}
+
+// stateFile is the format of the file, the legacy format is a plain
+// map of fire times
+
+type stateFile struct {
+	FireTimes map[string]int64 `json:"fire_times"`
+	AliveAt   int64            `json:"alive_at,omitempty"`
+	Pause     *PauseState      `json:"pause,omitempty"`
+}
+
+// LoadFireTimes reads the file of fire times, a missing file is empty
+
+func LoadFireTimes(path string) (*FireTimes, error) {
+	ft := &FireTimes{path: path, times: map[string]int64{}}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ft, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("fire times file %s: %w", path, err)
+	}
+	if _, exists := fields["fire_times"]; !exists {
+		if err := json.Unmarshal(data, &ft.times); err != nil {
+			return nil, fmt.Errorf("fire times file %s: %w", path, err)
+		}
+		return ft, nil
+	}
+
+	var state stateFile
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("fire times file %s: %w", path, err)
+	}
+	if state.FireTimes != nil {
+		ft.times = state.FireTimes
+	}
+	ft.aliveAt = state.AliveAt
+	if state.Pause != nil {
+		ft.pause = *state.Pause
+	}
+	return ft, nil
+}
+
+// Last returns the last fire time of the job, false if it is unknown
+
+func (ft *FireTimes) Last(job string) (time.Time, bool) {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+
+	t, exists := ft.times[job]
+	if !exists {
+		return time.Time{}, false
+	}
+	return time.Unix(0, t), true
+}
+
+// Since returns the time missed occurrences of the job are counted
+// from: the later of its last fire time and the time the program was
+// last alive, so a job disabled, changed or added while the program
+// was running does not count that time as missed
+
+func (ft *FireTimes) Since(job string) (time.Time, bool) {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+
+	t, exists := ft.times[job]
+	if ft.aliveAt > t {
+		t, exists = ft.aliveAt, true
+	}
+	if !exists {
+		return time.Time{}, false
+	}
+	return time.Unix(0, t), true
+}
+
+// Record sets fire times of jobs, they are saved by the next Flush
+
+func (ft *FireTimes) Record(t time.Time, jobs ...string) {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+
+	for _, job := range jobs {
+		ft.times[job] = t.UnixNano()
+	}
+	ft.dirty = true
+}
+
+// Pause returns the saved pause of the scheduler
+
+func (ft *FireTimes) Pause() PauseState {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+	return ft.pause
+}
+
+// SetPause sets the pause of the scheduler, it is saved by the next Flush
+
+func (ft *FireTimes) SetPause(state PauseState) {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+
+	ft.pause = state
+	ft.dirty = true
+}
+
+// Flush marks the program alive at now and saves the file if fire
+// times were recorded or the alive time is older than aliveInterval
+
+func (ft *FireTimes) Flush(now time.Time) error {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+
+	ft.aliveAt = now.UnixNano()
+	if !ft.dirty && ft.aliveAt-ft.savedAt < int64(aliveInterval) {
+		return nil
+	}
+
+	state := stateFile{
+		FireTimes: ft.times,
+		AliveAt:   ft.aliveAt,
+	}
+	if ft.pause.Paused {
+		state.Pause = &ft.pause
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := ft.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, ft.path); err != nil {
+		return err
+	}
+
+	ft.savedAt = ft.aliveAt
+	ft.dirty = false
+	return nil
+}
+
+// SetFireTimes enables recording of fire times, it must be
+// called before jobs are registered in the scheduler
+
+func (db *Database) SetFireTimes(ft *FireTimes) {
+	db.fireTimes = ft
+}
+
+func (db *Database) recordFire(jobKey string) {
+	if db.fireTimes == nil {
+		return
+	}
+	db.fireTimes.Record(time.Now(), jobKey)
+}
+
+func validateMisfire(c *JobConfig) error {
+	switch c.MisfirePolicy {
+	case "", MisfireIgnore, MisfireRunOnce, MisfireRunAll:
+	default:
+		return fmt.Errorf("unknown misfire policy %q", c.MisfirePolicy)
+	}
+
+	if c.MisfireMaxRuns != 0 && c.MisfirePolicy != MisfireRunAll {
+		return errors.New("misfire_max_runs is set without the run_all misfire policy")
+	}
+	return nil
+}
+
+// missedRuns returns the number of runs of occurrences of the job
+// missed from last to now by its misfire policy
+
+func missedRuns(c *JobConfig, last, now time.Time) (int, error) {
+	limit := 0
+	switch c.MisfirePolicy {
+	case MisfireRunOnce:
+		limit = 1
+	case MisfireRunAll:
+		limit = DefaultMisfireMaxRuns
+		if c.MisfireMaxRuns != 0 {
+			limit = int(c.MisfireMaxRuns)
+		}
+	default:
+		return 0, nil
+	}
+
+	trigger, err := quartz.NewCronTrigger(c.CronExpression)
+	if err != nil {
+		return 0, err
+	}
+
+	missed := 0
+	fireTime := last.UnixNano()
+	for missed < limit {
+		fireTime, err = trigger.NextFireTime(fireTime)
+		if err != nil || fireTime > now.UnixNano() {
+			break
+		}
+		missed++
+	}
+	return missed, nil
+}
+
+// CatchUp runs occurrences of jobs missed while the program was not
+// running by misfire policies of jobs, it is called once on start after
+// RegisterJobs. Missed runs of a job go one after another in the
+// background. Missed occurrences are counted from FireTimes.Since, a
+// job without a fire time and alive time has nothing missed
+
+// WARN: BEFORE CALLING THIS, PLS THINK ABOUT TAKE DB MUTEX
+
+func CatchUp(ctx context.Context, db *Database, logger *slog.Logger) error {
+	if db.fireTimes == nil {
+		return nil
+	}
+
+	now := time.Now()
+
+	for jk, j := range db.Jobs {
+		if j.Config.Status.Configured() == StatusDisable {
+			continue
+		}
+		since, known := db.fireTimes.Since(jk)
+		if !known {
+			continue
+		}
+
+		missed, err := missedRuns(&j.Config, since, now)
+		if err != nil {
+			return fmt.Errorf("job %s: %w", jk, err)
+		}
+		if missed == 0 {
+			continue
+		}
+
+		logger.Info("Running missed occurrences of job",
+			"name", jk,
+			"misfire_policy", j.Config.MisfirePolicy,
+			"since", since,
+			"runs", missed,
+		)
+
+		job := newShellJob(db, jk, logger, true)
+		go func() {
+			for range missed {
+				if ctx.Err() != nil {
+					return
+				}
+				_ = job.Execute(ctx)
+			}
+		}()
+	}
+
+	return nil
+}
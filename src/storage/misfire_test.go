@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMissedRuns(t *testing.T) {
+	// Every hour at minute 0
+	c := JobConfig{CronExpression: "0 0 * * * *"}
+	last := time.Date(2025, 1, 1, 10, 0, 0, 0, time.Local)
+	now := last.Add(5*time.Hour + 30*time.Minute)
+
+	tests := []struct {
+		policy  string
+		maxRuns uint
+		want    int
+	}{
+		{"", 0, 0},
+		{MisfireIgnore, 0, 0},
+		{MisfireRunOnce, 0, 1},
+		{MisfireRunAll, 0, 5},
+		{MisfireRunAll, 3, 3},
+	}
+
+	for _, tt := range tests {
+		c.MisfirePolicy = tt.policy
+		c.MisfireMaxRuns = tt.maxRuns
+
+		got, err := missedRuns(&c, last, now)
+		if err != nil {
+			t.Fatalf("missedRuns(%q) failed: %v", tt.policy, err)
+		}
+		if got != tt.want {
+			t.Errorf("missedRuns(%q, %d) = %d, want %d", tt.policy, tt.maxRuns, got, tt.want)
+		}
+	}
+
+	c.MisfirePolicy = MisfireRunAll
+	c.MisfireMaxRuns = 0
+	if got, _ := missedRuns(&c, last, last.Add(30*time.Minute)); got != 0 {
+		t.Errorf("missedRuns without missed occurrences = %d, want 0", got)
+	}
+	if got, _ := missedRuns(&c, last, last.Add(100*time.Hour)); got != DefaultMisfireMaxRuns {
+		t.Errorf("missedRuns of a long downtime = %d, want %d", got, DefaultMisfireMaxRuns)
+	}
+}
+
+func TestFireTimes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	ft, err := LoadFireTimes(path)
+	if err != nil {
+		t.Fatalf("LoadFireTimes of a missing file failed: %v", err)
+	}
+	if _, known := ft.Since("job"); known {
+		t.Fatal("Fire time of a job is known in an empty file")
+	}
+
+	now := time.Now()
+	ft.Record(now.Add(-time.Hour), "job", "other")
+	if err := ft.Flush(now); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	ft, err = LoadFireTimes(path)
+	if err != nil {
+		t.Fatalf("LoadFireTimes failed: %v", err)
+	}
+	last, known := ft.Last("other")
+	if !known || !last.Equal(now.Add(-time.Hour).Round(0)) {
+		t.Errorf("Last = %v (%v), want %v", last, known, now.Add(-time.Hour))
+	}
+
+	// Missed occurrences are counted from the alive time
+	for _, job := range []string{"other", "never fired"} {
+		since, known := ft.Since(job)
+		if !known || !since.Equal(now.Round(0)) {
+			t.Errorf("Since(%q) = %v (%v), want %v", job, since, known, now)
+		}
+	}
+
+	// Legacy file of fire times only
+	if err := os.WriteFile(path, []byte(`{"job": 1}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ft, err = LoadFireTimes(path)
+	if err != nil {
+		t.Fatalf("LoadFireTimes of a legacy file failed: %v", err)
+	}
+	if last, known := ft.Since("job"); !known || last.UnixNano() != 1 {
+		t.Errorf("Since of a legacy file = %v (%v), want 1ns", last, known)
+	}
+}
+
+func TestCatchUpDisabledGap(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	ft, err := LoadFireTimes(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The job was disabled for ten days while the program was running,
+	// it was enabled again and the program was stopped 2.5 hours ago
+	now := time.Now()
+	ft.Record(now.Add(-10*24*time.Hour), "job")
+	if err := ft.Flush(now.Add(-2*time.Hour - 30*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	db := New()
+	db.SetFireTimes(ft)
+	j, _ := ShellJob("", "echo", "0 0 * * * *", 0, 0, 0)
+	j.Config.MisfirePolicy = MisfireRunAll
+	j.Config.MisfireMaxRuns = 100
+	db.SetJob(j, "job")
+
+	// Missed runs are not executed with a canceled context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := CatchUp(ctx, db, logger); err != nil {
+		t.Fatalf("CatchUp failed: %v", err)
+	}
+
+	if !strings.Contains(logs.String(), "runs=2") &&
+		!strings.Contains(logs.String(), "runs=3") {
+		t.Errorf("CatchUp counted the disabled period as missed: %s", logs.String())
+	}
+}
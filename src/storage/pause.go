@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// NOTE: Pause of the scheduler (maintenance mode): scheduled runs are
+// skipped until it is resumed, statuses of jobs are not changed. The
+// pause is kept in the state file with fire times, so a restarted
+// program stays paused
+
+var ErrPaused = errors.New("scheduler is paused")
+
+type PauseState struct {
+	Paused bool      `json:"paused"`
+	Since  time.Time `json:"since,omitzero"`
+	// Zero - until it is resumed
+	Until  time.Time `json:"until,omitzero"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// Pause pauses the scheduler until the time (zero - until Resume),
+// a repeated call replaces the pause. Running runs are canceled if
+// cancelRunning is set, otherwise they finish. It returns the
+// number of canceled runs
+
+func (db *Database) Pause(
+	until time.Time,
+	reason string,
+	cancelRunning bool,
+	logger *slog.Logger,
+) (int, error) {
+	if !until.IsZero() && !until.After(time.Now()) {
+		return 0, errors.New("resume time is in the past")
+	}
+
+	db.pauseMu.Lock()
+	since := db.pause.Since
+	if !db.pause.Paused {
+		since = time.Now()
+	}
+	db.setPause(PauseState{
+		Paused: true,
+		Since:  since,
+		Until:  until,
+		Reason: reason,
+	}, logger)
+	db.pauseMu.Unlock()
+	db.savePause(logger)
+
+	canceled := 0
+	if cancelRunning {
+		canceled = db.runs.cancelAll()
+	}
+
+	logger.Warn("Scheduler is paused",
+		"until", until,
+		"reason", reason,
+		"canceled_runs", canceled,
+	)
+	return canceled, nil
+}
+
+// Resume resumes the scheduler, false if it is not paused
+
+func (db *Database) Resume(logger *slog.Logger) bool {
+	return db.resume(logger, time.Time{})
+}
+
+// resume resumes the pause which ends at until (any pause if
+// it is zero), so an old timer does not end a newer pause
+
+func (db *Database) resume(logger *slog.Logger, until time.Time) bool {
+	db.pauseMu.Lock()
+	if !db.pause.Paused || (!until.IsZero() && !db.pause.Until.Equal(until)) {
+		db.pauseMu.Unlock()
+		return false
+	}
+
+	since := db.pause.Since
+	db.setPause(PauseState{}, logger)
+	db.pauseMu.Unlock()
+	db.savePause(logger)
+
+	logger.Warn("Scheduler is resumed",
+		"paused_for", time.Since(since).Round(time.Second).String(),
+		"auto", !until.IsZero(),
+	)
+	return true
+}
+
+// RestorePause pauses the scheduler by the pause saved in the state
+// file, a pause which ended while the program was not running is
+// resumed. It must be called after SetFireTimes
+
+func (db *Database) RestorePause(logger *slog.Logger) {
+	if db.fireTimes == nil {
+		return
+	}
+	state := db.fireTimes.Pause()
+	if !state.Paused {
+		return
+	}
+
+	if !state.Until.IsZero() && !state.Until.After(time.Now()) {
+		db.fireTimes.SetPause(PauseState{})
+		db.savePause(logger)
+		logger.Warn("Scheduler is resumed",
+			"paused_for", state.Until.Sub(state.Since).Round(time.Second).String(),
+			"auto", true,
+		)
+		return
+	}
+
+	db.pauseMu.Lock()
+	db.setPause(state, logger)
+	db.pauseMu.Unlock()
+
+	logger.Warn("Scheduler is paused",
+		"since", state.Since,
+		"until", state.Until,
+		"reason", state.Reason,
+		"restored", true,
+	)
+}
+
+// setPause replaces the pause and the timer of its end, the pause is
+// set in the state file under the mutex, so the file gets the last one
+
+// WARN: BEFORE CALLING THIS, PLS THINK ABOUT TAKE PAUSE MUTEX
+
+func (db *Database) setPause(state PauseState, logger *slog.Logger) {
+	db.pause = state
+	if db.fireTimes != nil {
+		db.fireTimes.SetPause(state)
+	}
+	if db.resumeTimer != nil {
+		db.resumeTimer.Stop()
+		db.resumeTimer = nil
+	}
+	if until := state.Until; state.Paused && !until.IsZero() {
+		db.resumeTimer = time.AfterFunc(time.Until(until), func() {
+			db.resume(logger, until)
+		})
+	}
+}
+
+// savePause writes the pause to the state file at once, a pause
+// is not frequent
+
+func (db *Database) savePause(logger *slog.Logger) {
+	if db.fireTimes == nil {
+		return
+	}
+	if err := db.fireTimes.Flush(time.Now()); err != nil {
+		logger.Warn("Failed to save pause of scheduler", "error", err)
+	}
+}
+
+func (db *Database) PauseState() PauseState {
+	db.pauseMu.Lock()
+	defer db.pauseMu.Unlock()
+	return db.pause
+}
+
+func (db *Database) paused() bool {
+	db.pauseMu.Lock()
+	defer db.pauseMu.Unlock()
+	return db.pause.Paused
+}
@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPause(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+
+	db := New()
+	if db.PauseState().Paused {
+		t.Fatal("New database is paused")
+	}
+	if db.Resume(logger) {
+		t.Error("Resume of the running scheduler returns true")
+	}
+	if _, err := db.Pause(time.Now().Add(-time.Minute), "", false, logger); err == nil {
+		t.Error("Expected error for a resume time in the past")
+	}
+
+	// Runs started by hand are not skipped, they are canceled on request
+	sleep, _ := ShellJob("", "sleep 10", "0 0 * * * *", 0, 0, 0)
+	db.SetJob(sleep, "sleep")
+	id, err := db.ExecJob("sleep", ctx, logger)
+	if err != nil {
+		t.Fatalf("ExecJob failed: %v", err)
+	}
+
+	canceled, err := db.Pause(time.Time{}, "upgrade", true, logger)
+	if err != nil {
+		t.Fatalf("Pause failed: %v", err)
+	}
+	if canceled != 1 {
+		t.Errorf("Canceled runs: got %d, want 1", canceled)
+	}
+	if run := waitRun(t, db, id); run.Status != RunCanceled {
+		t.Errorf("Run status: got %s, want %s", run.Status, RunCanceled)
+	}
+
+	state := db.PauseState()
+	if !state.Paused || state.Reason != "upgrade" || !state.Until.IsZero() || state.Since.IsZero() {
+		t.Errorf("Unexpected pause state: %+v", state)
+	}
+
+	// A repeated pause keeps the start of the pause
+	until := time.Now().Add(100 * time.Millisecond)
+	if _, err := db.Pause(until, "upgrade", false, logger); err != nil {
+		t.Fatalf("Pause failed: %v", err)
+	}
+	if got := db.PauseState(); !got.Since.Equal(state.Since) || !got.Until.Equal(until) {
+		t.Errorf("Unexpected pause state after a repeated pause: %+v", got)
+	}
+
+	// The stale timer of the replaced pause does not resume the scheduler
+	if db.resume(logger, until.Add(time.Second)) {
+		t.Error("Scheduler is resumed by a stale timer")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for db.PauseState().Paused && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if db.PauseState().Paused {
+		t.Fatal("Scheduler is not resumed at the resume time")
+	}
+
+	if _, err := db.Pause(time.Time{}, "", false, logger); err != nil {
+		t.Fatalf("Pause failed: %v", err)
+	}
+	if !db.Resume(logger) || db.PauseState().Paused {
+		t.Error("Scheduler is not resumed")
+	}
+}
+
+func TestRestorePause(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	load := func() *Database {
+		ft, err := LoadFireTimes(path)
+		if err != nil {
+			t.Fatalf("LoadFireTimes failed: %v", err)
+		}
+		db := New()
+		db.SetFireTimes(ft)
+		db.RestorePause(logger)
+		return db
+	}
+
+	db := load()
+	if db.PauseState().Paused {
+		t.Fatal("Database is paused without a saved pause")
+	}
+	until := time.Now().Add(300 * time.Millisecond)
+	if _, err := db.Pause(until, "upgrade", false, logger); err != nil {
+		t.Fatalf("Pause failed: %v", err)
+	}
+	// The program is stopped
+	db.resumeTimer.Stop()
+
+	// The pause and its resume timer are restored after a restart
+	restored := load()
+	state := restored.PauseState()
+	if !state.Paused || state.Reason != "upgrade" || !state.Until.Equal(until.Round(0)) {
+		t.Fatalf("Unexpected restored pause: %+v", state)
+	}
+	j, _ := ShellJob("", "echo", "0 0 * * * *", 0, 0, 0)
+	restored.SetJob(j, "job")
+	if !restored.skipFunc("job", logger)(context.Background()) {
+		t.Error("Run is not skipped by the restored pause")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for restored.PauseState().Paused && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if restored.PauseState().Paused {
+		t.Fatal("Restored pause is not resumed at the resume time")
+	}
+	if load().PauseState().Paused {
+		t.Error("Resumed pause is restored")
+	}
+
+	// A pause which ended while the program was not running is resumed
+	ft, err := LoadFireTimes(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ft.SetPause(PauseState{
+		Paused: true,
+		Since:  time.Now().Add(-time.Hour),
+		Until:  time.Now().Add(-time.Minute),
+	})
+	if err := ft.Flush(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if load().PauseState().Paused {
+		t.Error("Ended pause is restored")
+	}
+	if load().PauseState().Paused {
+		t.Error("Ended pause is not removed from the state file")
+	}
+}
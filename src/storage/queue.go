@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"cronshroom/extjob"
+)
+
+// NOTE: Concurrency limits of scheduled runs: the global one and
+// named pools of jobs, runs over limits wait in the queue
+
+// SetLimiter enables concurrency limits, it must be
+// called before jobs are registered in the scheduler
+
+func (db *Database) SetLimiter(limiter *extjob.Limiter) {
+	db.limiter = limiter
+}
+
+// Queue returns the state of concurrency limits,
+// false if they are not configured
+
+func (db *Database) Queue() (extjob.QueueState, bool) {
+	if db.limiter == nil {
+		return extjob.QueueState{}, false
+	}
+	return db.limiter.State(), true
+}
+
+func validatePool(c *JobConfig) error {
+	if strings.ContainsAny(c.Pool, "= \t\n") {
+		return fmt.Errorf("invalid pool name %q", c.Pool)
+	}
+	return nil
+}
+
+// acquireFunc returns the function which waits for slots of runs of
+// the job, a run which waits longer than QueueMaxWait is skipped
+
+// WARN: BEFORE CALLING THIS, PLS THINK ABOUT TAKE DB MUTEX
+
+func (db *Database) acquireFunc(
+	jobKey string,
+	logger *slog.Logger,
+) func(context.Context) (func(), time.Duration, error) {
+	c := db.Jobs[jobKey].Config
+	req := extjob.QueueRequest{
+		Job:      jobKey,
+		Pool:     c.Pool,
+		Priority: c.Priority,
+		MaxWait:  time.Duration(c.QueueMaxWait) * time.Second,
+	}
+
+	if req.Pool != "" && !db.limiter.HasPool(req.Pool) {
+		logger.Warn("Pool of job is not defined, only the global limit applies",
+			"name", jobKey,
+			"pool", req.Pool,
+		)
+		req.Pool = ""
+	}
+
+	return func(ctx context.Context) (func(), time.Duration, error) {
+		logger := db.jobLogger(jobKey, logger)
+
+		release, waited, err := db.limiter.Acquire(ctx, req)
+		if errors.Is(err, extjob.ErrQueueTimeout) {
+			logger.Warn("Run is skipped, it waited in the queue too long",
+				"name", jobKey,
+				"pool", req.Pool,
+				"queue_max_wait", req.MaxWait.String(),
+			)
+		}
+		// The scheduler could be paused while the run was queued
+		if err == nil && waited > 0 && db.paused() {
+			release()
+			logger.Info("Run is skipped, the scheduler is paused", "name", jobKey)
+			return nil, waited, ErrPaused
+		}
+		return release, waited, err
+	}
+}
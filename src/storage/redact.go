@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"cronshroom/utils"
+)
+
+// NOTE: Masking of secrets in runs and log files of jobs
+
+// SetRedactor enables masking of secrets, it must be
+// called before jobs are registered in the scheduler
+
+func (db *Database) SetRedactor(redactor *utils.Redactor) {
+	db.redactor = redactor
+}
+
+// redact returns s with masked secrets, s as is if masking is disabled
+
+func (db *Database) redact(s string) string {
+	return db.redactor.Redact(s)
+}
+
+// registerSecrets registers values of env variables of the
+// job which look like secrets (PGPASSWORD, GITHUB_TOKEN)
+
+func (db *Database) registerSecrets(j *Job) {
+	if db.redactor == nil {
+		return
+	}
+
+	for k, v := range j.Config.Env {
+		if utils.IsSecretName(k) {
+			db.redactor.Register(v)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// NOTE: Runs of jobs. They are kept in memory only: all running
+// ones and the last finished ones
+
+type RunStatus string
+
+const (
+	RunRunning  RunStatus = "running"
+	RunOK       RunStatus = "ok"
+	RunFailed   RunStatus = "failed"
+	RunCanceled RunStatus = "canceled"
+)
+
+const maxFinishedRuns = 100
+
+var ErrRunNotFound = errors.New("run not found")
+
+type Run struct {
+	ID      uint64 `json:"id"`
+	Job     string `json:"job"`
+	Command string `json:"command"`
+	// Attempt of the execution from 1 and the number of attempts
+	Attempt    int       `json:"attempt"`
+	Attempts   int       `json:"attempts"`
+	Status     RunStatus `json:"status"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+	ExitCode   int       `json:"exit_code"`
+	Stdout     string    `json:"stdout,omitempty"`
+	Stderr     string    `json:"stderr,omitempty"`
+}
+
+type runEntry struct {
+	run      Run
+	cancel   context.CancelFunc
+	canceled bool
+}
+
+type runRegistry struct {
+	mu   sync.Mutex
+	runs map[uint64]*runEntry
+	// Ids of finished runs from the oldest
+	finished []uint64
+	// Numbers of all finished runs by status
+	counts map[RunStatus]uint64
+}
+
+// start registers the run, a run which is already registered
+// (started by ExecJob) is kept
+
+func (r *runRegistry) start(
+	id uint64,
+	job, command string,
+	cancel context.CancelFunc,
+	attempt, attempts int,
+) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.runs == nil {
+		r.runs = make(map[uint64]*runEntry)
+	}
+	if _, exists := r.runs[id]; exists {
+		return
+	}
+	r.runs[id] = &runEntry{
+		run: Run{
+			ID:        id,
+			Job:       job,
+			Command:   command,
+			Attempt:   attempt,
+			Attempts:  attempts,
+			Status:    RunRunning,
+			StartedAt: time.Now(),
+		},
+		cancel: cancel,
+	}
+}
+
+// finish records the result of the run, a run which is already
+// finished is kept
+
+func (r *runRegistry) finish(id uint64, ok bool, exitCode int, stdout, stderr string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, exists := r.runs[id]
+	if !exists || e.run.Status != RunRunning {
+		return
+	}
+
+	e.run.FinishedAt = time.Now()
+	e.run.ExitCode = exitCode
+	e.run.Stdout, e.run.Stderr = stdout, stderr
+	switch {
+	case e.canceled:
+		e.run.Status = RunCanceled
+	case ok:
+		e.run.Status = RunOK
+	default:
+		e.run.Status = RunFailed
+	}
+	e.cancel = nil
+
+	if r.counts == nil {
+		r.counts = make(map[RunStatus]uint64)
+	}
+	r.counts[e.run.Status]++
+
+	r.finished = append(r.finished, id)
+	if len(r.finished) > maxFinishedRuns {
+		delete(r.runs, r.finished[0])
+		r.finished = r.finished[1:]
+	}
+}
+
+// Runs returns runs of the job (all jobs if job is empty) from
+// the newest, without output
+
+func (db *Database) Runs(job string) []Run {
+	db.runs.mu.Lock()
+	defer db.runs.mu.Unlock()
+
+	runs := make([]Run, 0, len(db.runs.runs))
+	for _, e := range db.runs.runs {
+		if job != "" && e.run.Job != job {
+			continue
+		}
+		run := e.run
+		run.Stdout, run.Stderr = "", ""
+		runs = append(runs, run)
+	}
+
+	sort.Slice(runs, func(i, k int) bool { return runs[i].ID > runs[k].ID })
+	return runs
+}
+
+// RunStats are numbers of runs since the start of the program
+
+type RunStats struct {
+	Running  int
+	Finished map[RunStatus]uint64
+}
+
+func (db *Database) RunStats() RunStats {
+	db.runs.mu.Lock()
+	defer db.runs.mu.Unlock()
+
+	stats := RunStats{Finished: map[RunStatus]uint64{}}
+	for _, e := range db.runs.runs {
+		if e.run.Status == RunRunning {
+			stats.Running++
+		}
+	}
+	for status, n := range db.runs.counts {
+		stats.Finished[status] = n
+	}
+	return stats
+}
+
+func (db *Database) GetRun(id uint64) (Run, error) {
+	db.runs.mu.Lock()
+	defer db.runs.mu.Unlock()
+
+	e, exists := db.runs.runs[id]
+	if !exists {
+		return Run{}, ErrRunNotFound
+	}
+	return e.run, nil
+}
+
+// cancelAll stops programs of all running runs,
+// it returns the number of canceled runs
+
+func (r *runRegistry) cancelAll() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	canceled := 0
+	for _, e := range r.runs {
+		if e.cancel == nil || e.canceled {
+			continue
+		}
+		e.canceled = true
+		e.cancel()
+		canceled++
+	}
+	return canceled
+}
+
+// CancelRun stops the running program of the run,
+// the canceled run is not retried
+
+func (db *Database) CancelRun(id uint64) error {
+	db.runs.mu.Lock()
+	defer db.runs.mu.Unlock()
+
+	e, exists := db.runs.runs[id]
+	if !exists {
+		return ErrRunNotFound
+	}
+	if e.cancel == nil {
+		return errors.New("run is already finished")
+	}
+
+	e.canceled = true
+	e.cancel()
+	return nil
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func waitRun(t *testing.T, db *Database, id uint64) Run {
+	t.Helper()
+
+	for range 100 {
+		run, err := db.GetRun(id)
+		if err != nil {
+			t.Fatalf("GetRun failed: %v", err)
+		}
+		if run.Status != RunRunning {
+			return run
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	t.Fatalf("Run %d is not finished", id)
+	return Run{}
+}
+
+func TestRuns(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+
+	db := New()
+	echo, _ := ShellJob("", "echo $GREETING", "0 0 * * * *", 0, 0, 0)
+	echo.Config.Env = map[string]string{"GREETING": "hello"}
+	sleep, _ := ShellJob("", "sleep 10", "0 0 * * * *", 0, 0, 0)
+	db.SetJob(echo, "echo")
+	db.SetJob(sleep, "sleep")
+
+	if _, err := db.ExecJob("missing", ctx, logger); err == nil {
+		t.Errorf("Expected error for a missing job")
+	}
+
+	id, err := db.ExecJob("echo", ctx, logger)
+	if err != nil {
+		t.Fatalf("ExecJob failed: %v", err)
+	}
+	run := waitRun(t, db, id)
+	if run.Status != RunOK || run.Stdout != "hello\n" || run.Job != "echo" {
+		t.Errorf("Unexpected run: %+v", run)
+	}
+
+	id, err = db.ExecJob("sleep", ctx, logger)
+	if err != nil {
+		t.Fatalf("ExecJob failed: %v", err)
+	}
+	if err := db.CancelRun(id); err != nil {
+		t.Fatalf("CancelRun failed: %v", err)
+	}
+	if run := waitRun(t, db, id); run.Status != RunCanceled {
+		t.Errorf("Expected canceled run, got %s", run.Status)
+	}
+
+	// The job is deleted during the run
+	id, err = db.ExecJob("sleep", ctx, logger)
+	if err != nil {
+		t.Fatalf("ExecJob failed: %v", err)
+	}
+	if err := db.DeleteJob("sleep"); err != nil {
+		t.Fatalf("DeleteJob failed: %v", err)
+	}
+	if err := db.CancelRun(id); err != nil {
+		t.Fatalf("CancelRun failed: %v", err)
+	}
+	if run := waitRun(t, db, id); run.Status != RunCanceled {
+		t.Errorf("Expected canceled run of the deleted job, got %s", run.Status)
+	}
+	db.SetJob(sleep, "sleep")
+
+	if runs := db.Runs("echo"); len(runs) != 1 || runs[0].Stdout != "" {
+		t.Errorf("Unexpected runs of the job: %+v", runs)
+	}
+	if runs := db.Runs(""); len(runs) != 3 || runs[0].Job != "sleep" {
+		t.Errorf("Expected 3 runs from the newest, got %+v", runs)
+	}
+
+	stats := db.RunStats()
+	if stats.Running != 0 || stats.Finished[RunOK] != 1 || stats.Finished[RunCanceled] != 2 {
+		t.Errorf("Unexpected run stats: %+v", stats)
+	}
+}
@@ -0,0 +1,232 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NOTE: Database schema versioning
+
+// SchemaVersion is the version of the database
+// layout written by this build of the program
+const SchemaVersion = "1.10"
+
+var ErrUnsupportedVersion = errors.New("unsupported database version")
+
+type MigrationInfo struct {
+	From   string
+	To     string
+	Backup string
+}
+
+type migration struct {
+	from    string
+	to      string
+	migrate func(doc map[string]any) error
+}
+
+// Migrations are applied one by one starting from the one whose
+// from matches the version of the document. Append new steps to
+// the end and bump SchemaVersion, never change existing ones
+
+var migrations = []migration{
+	{
+		// Same layout, only the version is bumped
+		from:    "1.0",
+		to:      "1.1",
+		migrate: func(doc map[string]any) error { return nil },
+	},
+	{
+		// The database mutex was serialized as "Mu": {}
+		from: "1.1",
+		to:   "1.2",
+		migrate: func(doc map[string]any) error {
+			delete(doc, "Mu")
+			return nil
+		},
+	},
+	{
+		// New optional job field config.env
+		from:    "1.2",
+		to:      "1.3",
+		migrate: func(doc map[string]any) error { return nil },
+	},
+	{
+		// New optional job fields config.user and config.group
+		from:    "1.3",
+		to:      "1.4",
+		migrate: func(doc map[string]any) error { return nil },
+	},
+	{
+		// New optional job field config.limits
+		from:    "1.4",
+		to:      "1.5",
+		migrate: func(doc map[string]any) error { return nil },
+	},
+	{
+		// New optional job field config.retry
+		from:    "1.5",
+		to:      "1.6",
+		migrate: func(doc map[string]any) error { return nil },
+	},
+	{
+		// New optional job fields config.misfire_policy and config.misfire_max_runs
+		from:    "1.6",
+		to:      "1.7",
+		migrate: func(doc map[string]any) error { return nil },
+	},
+	{
+		// New optional job fields config.start_spread and config.start_jitter
+		from:    "1.7",
+		to:      "1.8",
+		migrate: func(doc map[string]any) error { return nil },
+	},
+	{
+		// New optional job fields config.pool, config.priority and config.queue_max_wait
+		from:    "1.8",
+		to:      "1.9",
+		migrate: func(doc map[string]any) error { return nil },
+	},
+	{
+		// New optional job fields config.exclude_calendars and config.only_calendars
+		from:    "1.9",
+		to:      "1.10",
+		migrate: func(doc map[string]any) error { return nil },
+	},
+}
+
+// MigrateDocument upgrades a serialized database to SchemaVersion.
+// It returns the upgraded document and the version it had before
+
+func MigrateDocument(data []byte) (migrated []byte, fromVersion string, err error) {
+	var doc map[string]any
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, "", err
+	}
+
+	fromVersion, _ = doc["version"].(string)
+
+	cmp, err := compareVersions(fromVersion, SchemaVersion)
+	if err != nil {
+		return nil, fromVersion, err
+	}
+	if cmp == 0 {
+		return data, fromVersion, nil
+	}
+	if cmp > 0 {
+		return nil, fromVersion, fmt.Errorf(
+			"%w: database version %s is newer than %s supported"+
+				" by this program, please upgrade the program",
+			ErrUnsupportedVersion, fromVersion, SchemaVersion,
+		)
+	}
+
+	version := fromVersion
+	for {
+		if cmp, _ := compareVersions(version, SchemaVersion); cmp == 0 {
+			break
+		}
+
+		m, found := findMigration(version)
+		if !found {
+			return nil, fromVersion, fmt.Errorf(
+				"%w: no migration from database version %s",
+				ErrUnsupportedVersion, version,
+			)
+		}
+
+		if err := m.migrate(doc); err != nil {
+			return nil, fromVersion, fmt.Errorf(
+				"migration from %s to %s failed: %w",
+				m.from, m.to, err,
+			)
+		}
+
+		version = m.to
+		doc["version"] = version
+	}
+
+	migrated, err = json.Marshal(doc)
+	if err != nil {
+		return nil, fromVersion, err
+	}
+
+	return migrated, fromVersion, nil
+}
+
+func findMigration(version string) (migration, bool) {
+	for _, m := range migrations {
+		if cmp, err := compareVersions(m.from, version); err == nil && cmp == 0 {
+			return m, true
+		}
+	}
+	return migration{}, false
+}
+
+// compareVersions compares dotted numeric versions,
+// missing parts are zeros ("1.1" == "1.1.0")
+
+func compareVersions(a, b string) (int, error) {
+	pa, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	pb, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := 0; i < max(len(pa), len(pb)); i++ {
+		var va, vb int
+		if i < len(pa) {
+			va = pa[i]
+		}
+		if i < len(pb) {
+			vb = pb[i]
+		}
+		if va != vb {
+			if va < vb {
+				return -1, nil
+			}
+			return 1, nil
+		}
+	}
+
+	return 0, nil
+}
+
+func parseVersion(v string) ([]int, error) {
+	if v == "" {
+		return nil, fmt.Errorf("%w: version is missing", ErrUnsupportedVersion)
+	}
+
+	parts := strings.Split(v, ".")
+	nums := make([]int, len(parts))
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%w: invalid version %q", ErrUnsupportedVersion, v)
+		}
+		nums[i] = n
+	}
+
+	return nums, nil
+}
+
+// migrationBackupPath returns the path of the copy of
+// the database made before migration from version
+
+func migrationBackupPath(path, version string) string {
+	return fmt.Sprintf(
+		"%s.v%s-%s.bak",
+		path, version, time.Now().Format("20060102-150405"),
+	)
+}
@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.etcd.io/bbolt"
+)
+
+func TestMigrateDocument(t *testing.T) {
+	tests := []struct {
+		name        string
+		jsonInput   string
+		fromVersion string
+		expectError error
+	}{
+		{
+			name:        "current version",
+			jsonInput:   `{"version": "1.10", "metadata": {"updated_at": 1}, "jobs": {}}`,
+			fromVersion: "1.10",
+		},
+		{
+			name:        "version 1.9",
+			jsonInput:   `{"version": "1.9", "metadata": {"updated_at": 1}, "jobs": {}}`,
+			fromVersion: "1.9",
+		},
+		{
+			name:        "version 1.8",
+			jsonInput:   `{"version": "1.8", "metadata": {"updated_at": 1}, "jobs": {}}`,
+			fromVersion: "1.8",
+		},
+		{
+			name:        "version 1.7",
+			jsonInput:   `{"version": "1.7", "metadata": {"updated_at": 1}, "jobs": {}}`,
+			fromVersion: "1.7",
+		},
+		{
+			name:        "version 1.6",
+			jsonInput:   `{"version": "1.6", "metadata": {"updated_at": 1}, "jobs": {}}`,
+			fromVersion: "1.6",
+		},
+		{
+			name:        "version 1.5",
+			jsonInput:   `{"version": "1.5", "metadata": {"updated_at": 1}, "jobs": {}}`,
+			fromVersion: "1.5",
+		},
+		{
+			name:        "version 1.4",
+			jsonInput:   `{"version": "1.4", "metadata": {"updated_at": 1}, "jobs": {}}`,
+			fromVersion: "1.4",
+		},
+		{
+			name:        "version 1.3",
+			jsonInput:   `{"version": "1.3", "metadata": {"updated_at": 1}, "jobs": {}}`,
+			fromVersion: "1.3",
+		},
+		{
+			name:        "version 1.2",
+			jsonInput:   `{"version": "1.2", "metadata": {"updated_at": 1}, "jobs": {}}`,
+			fromVersion: "1.2",
+		},
+		{
+			name:        "version 1.1 with serialized mutex",
+			jsonInput:   `{"Mu": {}, "version": "1.1", "metadata": {"updated_at": 1}, "jobs": {}}`,
+			fromVersion: "1.1",
+		},
+		{
+			name:        "version 1.0.0",
+			jsonInput:   `{"version": "1.0.0", "metadata": {"updated_at": 1}, "jobs": {}}`,
+			fromVersion: "1.0.0",
+		},
+		{
+			name:        "newer version",
+			jsonInput:   `{"version": "99.0", "metadata": {"updated_at": 1}, "jobs": {}}`,
+			fromVersion: "99.0",
+			expectError: ErrUnsupportedVersion,
+		},
+		{
+			name:        "missing version",
+			jsonInput:   `{"metadata": {"updated_at": 1}, "jobs": {}}`,
+			expectError: ErrUnsupportedVersion,
+		},
+		{
+			name:        "unknown old version",
+			jsonInput:   `{"version": "0.9", "metadata": {"updated_at": 1}, "jobs": {}}`,
+			fromVersion: "0.9",
+			expectError: ErrUnsupportedVersion,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrated, fromVersion, err := MigrateDocument([]byte(tt.jsonInput))
+			if fromVersion != tt.fromVersion {
+				t.Errorf("Expected from version %q, got %q", tt.fromVersion, fromVersion)
+			}
+			if tt.expectError != nil {
+				if !errors.Is(err, tt.expectError) {
+					t.Fatalf("Expected error %v, got %v", tt.expectError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("MigrateDocument failed: %v", err)
+			}
+
+			db, err := Deserialize(migrated)
+			if err != nil {
+				t.Fatalf("Deserialize failed: %v", err)
+			}
+			if cmp, _ := compareVersions(db.Version, SchemaVersion); cmp != 0 {
+				t.Errorf("Expected version %s, got %s", SchemaVersion, db.Version)
+			}
+		})
+	}
+}
+
+func TestLoadFromFileMigration(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "db.json")
+	original := `{"Mu": {}, "version": "1.1", "metadata": {"updated_at": 1}, "jobs": {}}`
+
+	if err := os.WriteFile(path, []byte(original), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	db, err := LoadFromFile(path)
+	if err != nil {
+		t.Fatalf("LoadFromFile failed: %v", err)
+	}
+	if db.Migrated == nil || db.Migrated.From != "1.1" {
+		t.Fatalf("Expected migration info, got %+v", db.Migrated)
+	}
+
+	backup, err := os.ReadFile(db.Migrated.Backup)
+	if err != nil {
+		t.Fatalf("Backup is not written: %v", err)
+	}
+	if string(backup) != original {
+		t.Errorf("Backup content mismatch")
+	}
+
+	// The migrated file is written back and loads without migration
+	db, err = LoadFromFile(path)
+	if err != nil {
+		t.Fatalf("LoadFromFile failed: %v", err)
+	}
+	if db.Migrated != nil {
+		t.Errorf("Unexpected second migration: %+v", db.Migrated)
+	}
+}
+
+func TestBoltStoreMigration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.db")
+
+	store, err := NewBoltStore(path)
+	if err != nil {
+		t.Fatalf("NewBoltStore failed: %v", err)
+	}
+	if err := store.Sync(testStoreDatabase(t), nil); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	err = store.db.Update(func(tx *bbolt.Tx) error {
+		return putBoltHeader(tx, "1.1", Metadata{UpdatedAt: 1})
+	})
+	if err != nil {
+		t.Fatalf("Header update failed: %v", err)
+	}
+	_ = store.Close()
+
+	store, err = NewBoltStore(path)
+	if err != nil {
+		t.Fatalf("NewBoltStore failed: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+
+	db, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if db.Migrated == nil || db.Version != SchemaVersion || len(db.Jobs) != 3 {
+		t.Fatalf("Unexpected migrated database: %+v", db)
+	}
+	if _, err := os.Stat(db.Migrated.Backup); err != nil {
+		t.Errorf("Backup is not written: %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"errors"
+
+	"cronshroom/secrets"
+)
+
+// NOTE: Secrets referenced in commands and env of jobs, they
+// are given to the program only at execution time
+
+var ErrNoSecrets = errors.New("secrets store is not configured")
+
+// SetSecrets enables references to secrets, it must be
+// called before jobs are registered in the scheduler
+
+func (db *Database) SetSecrets(store *secrets.Store) {
+	db.secrets = store
+}
+
+// Secrets returns the secrets store, nil if it is not configured
+
+func (db *Database) Secrets() *secrets.Store {
+	return db.secrets
+}
+
+// resolveSecrets replaces references to secrets in the command (with
+// env variables of their values) and the env, values of secrets are
+// masked in logs
+
+func (db *Database) resolveSecrets(cmd string, env []string) (string, []string, error) {
+	lookup := func(name string) (string, error) {
+		if db.secrets == nil {
+			return "", ErrNoSecrets
+		}
+		return db.secrets.Get(name)
+	}
+
+	used := map[string]string{}
+	cmd, secretEnv, err := secrets.ExpandCommand(cmd, lookup, used)
+	if err != nil {
+		return "", nil, err
+	}
+
+	resolved := make([]string, len(env), len(env)+len(secretEnv))
+	for i, kv := range env {
+		if resolved[i], err = secrets.Expand(kv, lookup, used); err != nil {
+			return "", nil, err
+		}
+	}
+	resolved = append(resolved, secretEnv...)
+
+	for _, value := range used {
+		db.redactor.Register(value)
+	}
+
+	return cmd, resolved, nil
+}
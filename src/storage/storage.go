@@ -10,7 +10,10 @@ import (
 	"sync"
 	"time"
 
+	"cronshroom/calendars"
 	"cronshroom/extjob"
+	"cronshroom/secrets"
+	"cronshroom/utils"
 )
 
 // A Mutex for safe operation with a database stored on disk
@@ -23,15 +26,43 @@ type Metadata struct {
 }
 
 type Database struct {
-	Mu       sync.RWMutex
-	Version  string   `json:"version"`
-	Metadata Metadata `json:"metadata"`
-	Jobs     Jobs     `json:"jobs"`
+	Mu       sync.RWMutex `json:"-"`
+	Version  string       `json:"version"`
+	Metadata Metadata     `json:"metadata"`
+	Jobs     Jobs         `json:"jobs"`
+
+	// Set on load if the stored database was upgraded from an older version
+	Migrated *MigrationInfo `json:"-"`
+
+	// Names of jobs changed since the last sync with the store
+	changed map[string]struct{}
+	// The whole database must be written on the next sync
+	changedAll bool
+
+	// Runs of jobs, not stored
+	runs runRegistry
+	// Log files of jobs, nil if they are disabled
+	jobLogs *utils.JobLogs
+	// Masking of secrets, nil if it is disabled
+	redactor *utils.Redactor
+	// Secrets referenced by jobs, nil if they are not configured
+	secrets *secrets.Store
+	// Last fire times of jobs, nil if misfires are not tracked
+	fireTimes *FireTimes
+	// Concurrency limits of runs, nil if runs are not limited
+	limiter *extjob.Limiter
+	// Calendars referenced by jobs, nil if they are not configured
+	calendars *calendars.Store
+
+	// Pause of the scheduler and the timer of its end
+	pauseMu     sync.Mutex
+	pause       PauseState
+	resumeTimer *time.Timer
 }
 
 func New() *Database {
 	return &Database{
-		Version: "1.1",
+		Version: SchemaVersion,
 		Metadata: Metadata{
 			UpdatedAt: time.Now().Unix(),
 		},
@@ -39,7 +70,7 @@ func New() *Database {
 	}
 }
 
-func (db *Database) ToggleJob(name string) {
+func (db *Database) ToggleJob(name string) error {
 	db.Mu.Lock()
 	defer db.Mu.Unlock()
 
@@ -47,7 +78,7 @@ func (db *Database) ToggleJob(name string) {
 	var exists bool
 
 	if j, exists = db.Jobs[name]; !exists {
-		return
+		return ErrJobNotFound
 	}
 
 	switch j.Config.Status {
@@ -57,48 +88,54 @@ func (db *Database) ToggleJob(name string) {
 		j.Config.Status = StatusEnable
 	}
 
+	db.markChanged(name)
 	db.Metadata.UpdatedAt = time.Now().Unix()
+	return nil
 }
 
+// ExecJob starts the job out of schedule, returns the id of the run
+
 func (db *Database) ExecJob(
 	name string,
 	ctx context.Context,
 	logger *slog.Logger,
-) {
+) (uint64, error) {
 	db.Mu.RLock()
 	defer db.Mu.RUnlock()
 
 	if _, exists := db.Jobs[name]; !exists {
-		return
+		return 0, ErrJobNotFound
 	}
 
 	j := db.Jobs[name]
+	job := newShellJob(db, name, logger, false)
 
-	beforeExec := createBeforeExecCallback(db, name, logger)
-	afterExec := createAfterExecCallback(db, name, logger)
-
-	job := extjob.NewShellJobWithCallbacks(
-		j.Config.Command,
-		time.Duration(j.Config.Timeout)*time.Second,
-		beforeExec,
-		afterExec,
-	)
+	// The run is registered before the start, so it can
+	// be requested by the id right after the return
+	runCtx, runID := extjob.NewRunContext(ctx)
+	db.runs.start(runID, name, db.redact(j.Config.Command), extjob.RunCancel(runCtx), 1, 1)
 
+	// The run is finished if the after callback is not called
 	go func() {
-		_ = job.Execute(ctx)
+		err := job.Execute(runCtx)
+		db.runs.finish(runID, err == nil, job.ExitCode(), "", "")
 	}()
+
+	return runID, nil
 }
 
-func (db *Database) DeleteJob(name string) {
+func (db *Database) DeleteJob(name string) error {
 	db.Mu.Lock()
 	defer db.Mu.Unlock()
 
 	if _, exists := db.Jobs[name]; !exists {
-		return
+		return ErrJobNotFound
 	}
 
 	delete(db.Jobs, name)
+	db.markChanged(name)
 	db.Metadata.UpdatedAt = time.Now().Unix()
+	return nil
 }
 
 func (db *Database) SetJob(j *Job, k string) {
@@ -106,9 +143,87 @@ func (db *Database) SetJob(j *Job, k string) {
 	defer db.Mu.Unlock()
 
 	db.Jobs[k] = j
+	db.markChanged(k)
+	db.Metadata.UpdatedAt = time.Now().Unix()
+}
+
+// SetJobs sets and removes several jobs at once
+
+func (db *Database) SetJobs(set Jobs, remove []string) {
+	db.Mu.Lock()
+	defer db.Mu.Unlock()
+
+	for k, j := range set {
+		db.Jobs[k] = j
+		db.markChanged(k)
+	}
+	for _, k := range remove {
+		delete(db.Jobs, k)
+		db.markChanged(k)
+	}
+	db.Metadata.UpdatedAt = time.Now().Unix()
+}
+
+// Replace swaps the content of the database with the content of
+// other (e.g. reloaded from the store after an external change)
+
+func (db *Database) Replace(other *Database) {
+	db.Mu.Lock()
+	defer db.Mu.Unlock()
+
+	db.replace(other)
+}
+
+// Restore is Replace for content which is not in the store
+// yet (e.g. a snapshot), the next sync writes the whole database
+
+func (db *Database) Restore(other *Database) {
+	db.Mu.Lock()
+	defer db.Mu.Unlock()
+
+	db.replace(other)
+	db.changedAll = true
+}
+
+func (db *Database) replace(other *Database) {
+	db.Version = other.Version
+	db.Jobs = other.Jobs
+	db.changed = nil
+	db.changedAll = false
 	db.Metadata.UpdatedAt = time.Now().Unix()
 }
 
+// NOTE: Tracking of changes for partial store sync
+
+// WARN: BEFORE CALLING THIS, PLS THINK ABOUT TAKE DB MUTEX
+
+func (db *Database) markChanged(name string) {
+	if db.changed == nil {
+		db.changed = make(map[string]struct{})
+	}
+	db.changed[name] = struct{}{}
+}
+
+// Changes returns names of jobs changed since the last ResetChanges,
+// nil if the whole database is changed
+
+func (db *Database) Changes() []string {
+	if db.changedAll {
+		return nil
+	}
+
+	changes := make([]string, 0, len(db.changed))
+	for name := range db.changed {
+		changes = append(changes, name)
+	}
+	return changes
+}
+
+func (db *Database) ResetChanges() {
+	db.changed = nil
+	db.changedAll = false
+}
+
 // NOTE: Serialize storage structure in byte array
 
 func (db *Database) SerializeWithLock() ([]byte, error) {
@@ -127,10 +242,7 @@ func (db *Database) Serialize() ([]byte, error) {
 func Deserialize(data []byte) (*Database, error) {
 	var db Database
 
-	decoder := json.NewDecoder(bytes.NewReader(data))
-	decoder.DisallowUnknownFields()
-
-	err := decoder.Decode(&db)
+	err := decodeStrict(data, &db)
 	if err != nil {
 		return nil, err
 	}
@@ -138,22 +250,55 @@ func Deserialize(data []byte) (*Database, error) {
 	return &db, nil
 }
 
+func decodeStrict(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
 // NOTE: Load database from file
 
+// If the file has an older version it is migrated, the original
+// file is kept as a backup next to it
+
 func LoadFromFile(filepath string) (*Database, error) {
-	databaseFileMutex.RLock()
-	defer databaseFileMutex.RUnlock()
+	databaseFileMutex.Lock()
+	defer databaseFileMutex.Unlock()
 
 	data, err := os.ReadFile(filepath)
 	if err != nil {
 		return nil, err
 	}
 
-	db, err := Deserialize(data)
+	migrated, fromVersion, err := MigrateDocument(data)
 	if err != nil {
 		return nil, err
 	}
 
+	db, err := Deserialize(migrated)
+	if err != nil {
+		return nil, err
+	}
+
+	if fromVersion == db.Version {
+		return db, nil
+	}
+
+	backupPath := migrationBackupPath(filepath, fromVersion)
+	if err := os.WriteFile(backupPath, data, 0o644); err != nil {
+		return nil, err
+	}
+
+	if err := db.saveToFile(filepath); err != nil {
+		return nil, err
+	}
+
+	db.Migrated = &MigrationInfo{
+		From:   fromVersion,
+		To:     db.Version,
+		Backup: backupPath,
+	}
+
 	return db, nil
 }
 
@@ -165,11 +310,12 @@ func (db *Database) SaveToFile(filepath string) error {
 	databaseFileMutex.Lock()
 	defer databaseFileMutex.Unlock()
 
+	return db.saveToFile(filepath)
+}
+
+func (db *Database) saveToFile(filepath string) error {
 	for jk := range db.Jobs {
-		if db.Jobs[jk].Config.Status == StatusActiveDuringEnable ||
-			db.Jobs[jk].Config.Status == StatusActiveDuringDisable {
-			db.Jobs[jk].Config.Status = StatusEnable
-		}
+		db.Jobs[jk].Config.Status = db.Jobs[jk].Config.Status.Configured()
 	}
 
 	data, err := db.Serialize()
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// NOTE: Storage backend
+
+const (
+	BackendJSON = "json"
+	BackendBolt = "bolt"
+)
+
+var ErrJobNotFound = errors.New("job not found")
+
+// Store is a persistent backend of the database. The daemon keeps
+// the database in memory and periodically syncs it with the store
+type Store interface {
+	// Load reads the whole database from the store
+	Load() (*Database, error)
+	// Sync writes the database to the store. If the backend supports
+	// partial writes only jobs from changed are written (removed ones
+	// are deleted), nil changed means all jobs
+	Sync(db *Database, changed []string) error
+	// List reads jobs of the store
+	List() (Jobs, error)
+	// Watch returns a channel which receives a value every time
+	// the store is changed by someone else (another process, text
+	// editor). Stores which cannot be changed outside never send
+	Watch(ctx context.Context, interval time.Duration) <-chan struct{}
+	// Backup writes a consistent copy of the store to path
+	Backup(path string) error
+	Close() error
+}
+
+func OpenStore(backend, path string) (Store, error) {
+	switch backend {
+	case BackendJSON:
+		return NewJSONStore(path), nil
+	case BackendBolt:
+		return NewBoltStore(path)
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", backend)
+	}
+}
+
+// InitStore creates a store with an empty database
+
+func InitStore(backend, path string) error {
+	store, err := OpenStore(backend, path)
+	if err != nil {
+		return err
+	}
+
+	if err := store.Sync(New(), nil); err != nil {
+		_ = store.Close()
+		return err
+	}
+
+	return store.Close()
+}
+
+// CopyStore copies the whole database from src to dst,
+// jobs which are missing in src are deleted from dst
+
+func CopyStore(dst, src Store) (*Database, error) {
+	db, err := src.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := dst.Sync(db, nil); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func testStoreDatabase(t *testing.T) *Database {
+	t.Helper()
+
+	db := New()
+	for _, name := range []string{"job1", "job2", "job3"} {
+		j, err := ShellJob("test "+name, "echo "+name, "0 * * * * *", 30, 3, 10)
+		if err != nil {
+			t.Fatalf("ShellJob failed: %v", err)
+		}
+		db.Jobs[name] = j
+	}
+	return db
+}
+
+func TestStoreBackends(t *testing.T) {
+	tests := []struct {
+		backend string
+		file    string
+	}{
+		{backend: BackendJSON, file: "db.json"},
+		{backend: BackendBolt, file: "db.db"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.backend, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := InitStore(tt.backend, path); err != nil {
+				t.Fatalf("InitStore failed: %v", err)
+			}
+
+			store, err := OpenStore(tt.backend, path)
+			if err != nil {
+				t.Fatalf("OpenStore failed: %v", err)
+			}
+			defer func() { _ = store.Close() }()
+
+			db := testStoreDatabase(t)
+			if err := store.Sync(db, nil); err != nil {
+				t.Fatalf("Sync failed: %v", err)
+			}
+
+			// Partial sync: one changed, one deleted
+			db.SetJob(db.Jobs["job1"], "job4")
+			db.DeleteJob("job2")
+			db.ToggleJob("job3")
+			if err := store.Sync(db, db.Changes()); err != nil {
+				t.Fatalf("Sync failed: %v", err)
+			}
+			db.ResetChanges()
+
+			restored, err := store.Load()
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if len(restored.Jobs) != 3 {
+				t.Fatalf("Expected 3 jobs, got %d", len(restored.Jobs))
+			}
+			if restored.Jobs["job3"].Config.Status != StatusDisable {
+				t.Errorf("Toggled job status is not saved")
+			}
+
+			jobs, err := store.List()
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			if _, exists := jobs["job2"]; exists || len(jobs) != 3 {
+				t.Errorf("Unexpected jobs after the partial sync: %v", jobs)
+			}
+
+			// A disabled job which is running is stored as disabled
+			running, _ := ShellJob("", "echo", "0 * * * * *", 0, 0, 0)
+			running.Config.Status = StatusActiveDuringDisable
+			db.SetJob(running, "running")
+			if err := store.Sync(db, db.Changes()); err != nil {
+				t.Fatalf("Sync failed: %v", err)
+			}
+			jobs, err = store.List()
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			if stored := jobs["running"]; stored == nil || stored.Config.Status != StatusDisable {
+				t.Errorf("Stored job: got %+v, want status %s", stored, StatusDisable)
+			}
+		})
+	}
+}
+
+func TestCopyStore(t *testing.T) {
+	dir := t.TempDir()
+
+	src := NewJSONStore(filepath.Join(dir, "db.json"))
+	if err := src.Sync(testStoreDatabase(t), nil); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	dst, err := NewBoltStore(filepath.Join(dir, "db.db"))
+	if err != nil {
+		t.Fatalf("NewBoltStore failed: %v", err)
+	}
+	defer func() { _ = dst.Close() }()
+
+	if _, err := CopyStore(dst, src); err != nil {
+		t.Fatalf("CopyStore failed: %v", err)
+	}
+
+	back := NewJSONStore(filepath.Join(dir, "back.json"))
+	if _, err := CopyStore(back, dst); err != nil {
+		t.Fatalf("CopyStore failed: %v", err)
+	}
+
+	jobs, err := back.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(jobs) != 3 || jobs["job2"].Config.Command != "echo job2" {
+		t.Errorf("Jobs mismatch after round-trip migration: %v", jobs)
+	}
+}
@@ -0,0 +1,255 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"cronshroom/calendars"
+	"cronshroom/extjob"
+
+	"github.com/reugn/go-quartz/quartz"
+)
+
+// NOTE: Offline validation of database files. Unlike LoadFromFile
+// it does not stop on the first error and does not write the file
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+type Problem struct {
+	Severity string `json:"severity"`
+	Job      string `json:"job,omitempty"`
+	Message  string `json:"message"`
+}
+
+type ValidationReport struct {
+	File     string    `json:"file,omitempty"`
+	Version  string    `json:"version"`
+	Jobs     int       `json:"jobs"`
+	Valid    bool      `json:"valid"`
+	Errors   int       `json:"errors"`
+	Warnings int       `json:"warnings"`
+	Problems []Problem `json:"problems"`
+}
+
+func (r *ValidationReport) add(severity, job, format string, args ...any) {
+	r.Problems = append(r.Problems, Problem{
+		Severity: severity,
+		Job:      job,
+		Message:  fmt.Sprintf(format, args...),
+	})
+	if severity == SeverityError {
+		r.Errors++
+	} else {
+		r.Warnings++
+	}
+	r.Valid = r.Errors == 0
+}
+
+func ValidateDatabaseFile(path string, store *calendars.Store) *ValidationReport {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		r := &ValidationReport{File: path, Problems: []Problem{}}
+		r.add(SeverityError, "", "%v", err)
+		return r
+	}
+
+	r := ValidateDatabase(data, store)
+	r.File = path
+	return r
+}
+
+// ValidateDatabase checks the serialized database: the layout, every
+// job and suspicious values (warnings). Calendars of jobs must be in
+// the store
+
+func ValidateDatabase(data []byte, store *calendars.Store) *ValidationReport {
+	r := &ValidationReport{Valid: true, Problems: []Problem{}}
+
+	migrated, fromVersion, err := MigrateDocument(data)
+	r.Version = fromVersion
+	if err != nil {
+		r.add(SeverityError, "", "%v", err)
+		return r
+	}
+	if fromVersion != SchemaVersion {
+		r.add(SeverityWarning, "",
+			"database version %s is migrated to %s on load",
+			fromVersion, SchemaVersion,
+		)
+	}
+
+	var doc struct {
+		Version  string          `json:"version"`
+		Metadata Metadata        `json:"metadata"`
+		Jobs     json.RawMessage `json:"jobs"`
+	}
+	if err := decodeStrict(migrated, &doc); err != nil {
+		r.add(SeverityError, "", "%v", err)
+		return r
+	}
+
+	var jobs map[string]json.RawMessage
+	if err := json.Unmarshal(doc.Jobs, &jobs); err != nil {
+		r.add(SeverityError, "", "jobs: %v", err)
+		return r
+	}
+	r.Jobs = len(jobs)
+
+	// Migration decodes the document and loses duplicate
+	// names, so they are checked in the original one
+	var original struct {
+		Jobs json.RawMessage `json:"jobs"`
+	}
+	if err := json.Unmarshal(data, &original); err == nil {
+		validateJobKeys(r, original.Jobs)
+	}
+
+	for _, jk := range sortedJobKeys(jobs) {
+		var j Job
+		if err := decodeStrict(jobs[jk], &j); err != nil {
+			r.add(SeverityError, jk, "%v", err)
+			continue
+		}
+		validateJobValues(r, jk, &j, store)
+	}
+
+	return r
+}
+
+// validateJobKeys checks names of jobs, duplicates are
+// visible only in the raw document (the last one wins)
+
+func validateJobKeys(r *ValidationReport, raw json.RawMessage) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	if _, err := decoder.Token(); err != nil {
+		return
+	}
+
+	seen := map[string]struct{}{}
+	folded := map[string]string{}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return
+		}
+		jk, _ := token.(string)
+
+		var skip json.RawMessage
+		if err := decoder.Decode(&skip); err != nil {
+			return
+		}
+
+		if _, exists := seen[jk]; exists {
+			r.add(SeverityError, jk, "duplicate job name, only the last job is loaded")
+			continue
+		}
+		seen[jk] = struct{}{}
+
+		switch {
+		case strings.TrimSpace(jk) == "":
+			r.add(SeverityError, jk, "empty job name")
+		case strings.ContainsFunc(jk, unicode.IsControl):
+			r.add(SeverityError, jk, "job name has control characters")
+		case strings.TrimSpace(jk) != jk:
+			r.add(SeverityWarning, jk, "job name has leading or trailing spaces")
+		}
+
+		lower := strings.ToLower(jk)
+		if other, exists := folded[lower]; exists {
+			r.add(SeverityWarning, jk, "job name differs from %q only in case", other)
+		}
+		folded[lower] = jk
+	}
+}
+
+func validateJobValues(r *ValidationReport, jk string, j *Job, store *calendars.Store) {
+	// An empty name is reported by validateJobKeys,
+	// the rest of the job is still checked
+	name := jk
+	if strings.TrimSpace(name) == "" {
+		name = "<empty>"
+	}
+	if err := ValidateJob(name, j); err != nil {
+		r.add(SeverityError, jk, "%v", err)
+		return
+	}
+
+	c := j.Config
+
+	if err := checkCalendars(store, &c); err != nil {
+		r.add(SeverityError, jk, "%v", err)
+	}
+
+	switch c.Status {
+	case StatusActiveDuringEnable, StatusActiveDuringDisable:
+		r.add(SeverityWarning, jk,
+			"status %s (running) is saved, it is loaded as %s",
+			c.Status, c.Status.Configured(),
+		)
+	}
+
+	if c.MaxRetries == 0 && c.RetryInterval > 0 {
+		r.add(SeverityWarning, jk, "retry_interval is %d, but max_retries is 0", c.RetryInterval)
+	}
+	if c.MaxRetries == 0 && c.Retry != nil {
+		r.add(SeverityWarning, jk, "retry is set, but max_retries is 0")
+	}
+	if c.MaxRetries > 0 && c.RetryInterval == 0 &&
+		(c.Retry == nil || c.Retry.Backoff != extjob.BackoffExponential) {
+		r.add(SeverityWarning, jk, "max_retries is %d with retry_interval 0, retries start immediately", c.MaxRetries)
+	}
+
+	trigger, err := quartz.NewCronTrigger(c.CronExpression)
+	if err != nil {
+		r.add(SeverityError, jk, "%v", err)
+		return
+	}
+	now := time.Now().UnixNano()
+	next, err := trigger.NextFireTime(now)
+	if err != nil {
+		// The scheduler refuses enabled jobs which never fire
+		severity := SeverityError
+		if c.Status.Configured() == StatusDisable {
+			severity = SeverityWarning
+		}
+		r.add(severity, jk, "cron expression %q never fires", c.CronExpression)
+		return
+	}
+	if after, err := trigger.NextFireTime(next); err == nil {
+		interval := time.Duration(after - next)
+		if c.Timeout > 0 && time.Duration(c.Timeout)*time.Second > interval {
+			r.add(SeverityWarning, jk,
+				"timeout %ds is longer than the interval between runs %s, runs may overlap",
+				c.Timeout, interval,
+			)
+		}
+		if delay := c.StartSpread + c.StartJitter; delay > 0 && time.Duration(delay)*time.Second >= interval {
+			r.add(SeverityWarning, jk,
+				"start delay up to %ds is not shorter than the interval between runs %s, runs may overlap",
+				delay, interval,
+			)
+		}
+	}
+
+	if j.Metadata.UpdatedAt > time.Now().Add(time.Hour).Unix() {
+		r.add(SeverityWarning, jk, "updated_at is in the future")
+	}
+}
+
+func sortedJobKeys(jobs map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(jobs))
+	for k := range jobs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
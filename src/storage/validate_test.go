@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"cronshroom/calendars"
+)
+
+func validateTestJob(command, cron, status string, timeout, maxRetries, retryInterval uint) string {
+	return fmt.Sprintf(
+		`{"type": "shell", "description": "", "config": {"command": %q, "cron_expression": %q, "status": %q, `+
+			`"timeout": %d, "max_retries": %d, "retry_interval": %d}, "metadata": {"updated_at": 1}}`,
+		command, cron, status, timeout, maxRetries, retryInterval,
+	)
+}
+
+func TestValidateDatabase(t *testing.T) {
+	valid := validateTestJob("echo", "0 0 * * * ?", "E", 30, 3, 10)
+
+	store, err := calendars.Open(filepath.Join(t.TempDir(), "calendars.json"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := store.Set("holidays", &calendars.Calendar{Dates: []string{"2025-12-25"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		jobs     string
+		errors   int
+		warnings int
+	}{
+		{
+			name: "valid",
+			jobs: `"a": ` + valid + `, "b": ` + valid,
+		},
+		{
+			name:   "duplicate name",
+			jobs:   `"a": ` + valid + `, "a": ` + valid,
+			errors: 1,
+		},
+		{
+			name:   "empty name",
+			jobs:   `" ": ` + valid,
+			errors: 1,
+		},
+		{
+			name:   "invalid cron expression",
+			jobs:   `"a": ` + validateTestJob("echo", "* * *", "E", 0, 0, 0),
+			errors: 1,
+		},
+		{
+			name:   "empty command",
+			jobs:   `"a": ` + validateTestJob("", "0 0 * * * ?", "E", 0, 0, 0),
+			errors: 1,
+		},
+		{
+			name:   "unknown status",
+			jobs:   `"a": ` + validateTestJob("echo", "0 0 * * * ?", "X", 0, 0, 0),
+			errors: 1,
+		},
+		{
+			name:   "unknown field",
+			jobs:   `"a": {"type": "shell", "config": {"comand": "echo"}}`,
+			errors: 1,
+		},
+		{
+			name: "invalid limits",
+			jobs: `"a": {"type": "shell", "config": {"command": "echo", "cron_expression": "0 0 * * * ?", ` +
+				`"status": "E", "limits": {"nice": 30, "cgroup_cpu_max": "50%"}}}`,
+			errors: 1,
+		},
+		{
+			name: "cgroup outside of the cgroup root",
+			jobs: `"a": {"type": "shell", "config": {"command": "echo", "cron_expression": "0 0 * * * ?", ` +
+				`"status": "E", "limits": {"cgroup": "/sys/fs/cgroup/../../etc"}}}`,
+			errors: 1,
+		},
+		{
+			name: "known calendar",
+			jobs: `"a": {"type": "shell", "config": {"command": "echo", "cron_expression": "0 0 * * * ?", ` +
+				`"status": "E", "exclude_calendars": ["holidays"]}}`,
+		},
+		{
+			name: "unknown calendar",
+			jobs: `"a": {"type": "shell", "config": {"command": "echo", "cron_expression": "0 0 * * * ?", ` +
+				`"status": "E", "only_calendars": ["workdays"]}}`,
+			errors: 1,
+		},
+		{
+			name:     "retry interval without retries",
+			jobs:     `"a": ` + validateTestJob("echo", "0 0 * * * ?", "E", 0, 0, 10),
+			warnings: 1,
+		},
+		{
+			name:     "timeout longer than interval",
+			jobs:     `"a": ` + validateTestJob("echo", "0 * * * * ?", "E", 120, 0, 0),
+			warnings: 1,
+		},
+		{
+			name: "start delay longer than interval",
+			jobs: `"a": {"type": "shell", "config": {"command": "echo", "cron_expression": "0 * * * * ?", ` +
+				`"status": "E", "start_spread": 50, "start_jitter": 10}}`,
+			warnings: 1,
+		},
+		{
+			name:     "names differ in case",
+			jobs:     `"a": ` + valid + `, "A": ` + valid,
+			warnings: 1,
+		},
+		{
+			name:     "disabled job never fires",
+			jobs:     `"a": ` + validateTestJob("echo", "0 0 0 1 1 ? 2001", "D", 0, 0, 0),
+			warnings: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := fmt.Sprintf(
+				`{"version": %q, "metadata": {"updated_at": 1}, "jobs": {%s}}`,
+				SchemaVersion, tt.jobs,
+			)
+
+			r := ValidateDatabase([]byte(data), store)
+			if r.Errors != tt.errors || r.Warnings != tt.warnings {
+				t.Errorf("Expected %d errors and %d warnings, got %+v",
+					tt.errors, tt.warnings, r.Problems,
+				)
+			}
+			if r.Valid != (tt.errors == 0) {
+				t.Errorf("Unexpected valid flag %v", r.Valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// NOTE: Health of the program for liveness and readiness probes
+
+const probeTimeout = 2 * time.Second
+
+type HealthCheck struct {
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
+
+type HealthReport struct {
+	OK     bool                   `json:"ok"`
+	Checks map[string]HealthCheck `json:"checks"`
+}
+
+func (r *HealthReport) add(name string, err error) {
+	check := HealthCheck{OK: err == nil}
+	if err != nil {
+		check.Message = err.Error()
+		r.OK = false
+	}
+	r.Checks[name] = check
+}
+
+type Health struct {
+	syncInterval    time.Duration
+	maxSyncFailures uint32
+
+	databaseLoaded atomic.Bool
+	// Unix nano time of the last tick of the sync loop
+	lastSyncTick atomic.Int64
+	syncFailures atomic.Uint32
+
+	mu            sync.Mutex
+	lastSyncError error
+	lastSavedAt   time.Time
+	// Returns an error if the scheduler is not started or hangs
+	schedulerProbe func() error
+}
+
+func NewHealth(syncInterval time.Duration, maxSyncFailures uint32) *Health {
+	h := &Health{
+		syncInterval:    syncInterval,
+		maxSyncFailures: maxSyncFailures,
+	}
+	h.lastSyncTick.Store(time.Now().UnixNano())
+	return h
+}
+
+func (h *Health) SetDatabaseLoaded() {
+	h.databaseLoaded.Store(true)
+}
+
+func (h *Health) SetSchedulerProbe(probe func() error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.schedulerProbe = probe
+}
+
+// SyncTick marks that the sync loop is alive
+
+func (h *Health) SyncTick() {
+	h.lastSyncTick.Store(time.Now().UnixNano())
+}
+
+func (h *Health) SyncFailures() uint32 {
+	return h.syncFailures.Load()
+}
+
+func (h *Health) SyncFailed(err error) {
+	h.syncFailures.Add(1)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastSyncError = err
+}
+
+// SyncSucceeded marks that the database is saved to the store
+
+func (h *Health) SyncSucceeded() {
+	h.syncFailures.Store(0)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastSyncError = nil
+	h.lastSavedAt = time.Now()
+}
+
+// Liveness checks that the program is not stuck: the scheduler
+// answers and the sync loop ticks
+
+func (h *Health) Liveness() HealthReport {
+	r := HealthReport{OK: true, Checks: map[string]HealthCheck{}}
+	r.add("scheduler", h.probeScheduler())
+
+	var err error
+	// The sync loop may wait for locks of the database
+	// or the store, a few missed ticks are allowed
+	maxDelay := 3*h.syncInterval + 5*time.Second
+	if delay := time.Since(time.Unix(0, h.lastSyncTick.Load())); delay > maxDelay {
+		err = fmt.Errorf("sync loop did not tick for %s", delay.Round(time.Second))
+	}
+	r.add("sync_loop", err)
+
+	return r
+}
+
+// Readiness checks that jobs are loaded, scheduled and saved
+
+func (h *Health) Readiness() HealthReport {
+	r := HealthReport{OK: true, Checks: map[string]HealthCheck{}}
+
+	var err error
+	if !h.databaseLoaded.Load() {
+		err = errors.New("database is not loaded")
+	}
+	r.add("database", err)
+
+	r.add("scheduler", h.probeScheduler())
+
+	h.mu.Lock()
+	lastSyncError, lastSavedAt := h.lastSyncError, h.lastSavedAt
+	h.mu.Unlock()
+	if lastSyncError != nil {
+		r.add("last_sync", fmt.Errorf("last sync failed: %w", lastSyncError))
+	} else if !lastSavedAt.IsZero() {
+		r.Checks["last_sync"] = HealthCheck{
+			OK:      true,
+			Message: "saved at " + lastSavedAt.Format(time.RFC3339),
+		}
+	} else {
+		r.add("last_sync", nil)
+	}
+
+	// Failures below the maximum are reported,
+	// but the program is still ready
+	failures := h.syncFailures.Load()
+	check := HealthCheck{OK: failures < h.maxSyncFailures}
+	if failures > 0 {
+		check.Message = fmt.Sprintf("%d sync failures in a row, max %d", failures, h.maxSyncFailures)
+	}
+	if !check.OK {
+		r.OK = false
+	}
+	r.Checks["sync_failures"] = check
+
+	return r
+}
+
+// probeScheduler calls the probe with a timeout, the probe
+// hangs if the scheduler is locked
+
+func (h *Health) probeScheduler() error {
+	h.mu.Lock()
+	probe := h.schedulerProbe
+	h.mu.Unlock()
+
+	if probe == nil {
+		return errors.New("scheduler is not started")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- probe() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("scheduler did not answer in %s", probeTimeout)
+	}
+}
@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	h := NewHealth(time.Second, 2)
+
+	if r := h.Readiness(); r.OK || r.Checks["database"].OK || r.Checks["scheduler"].OK {
+		t.Fatalf("Readiness failed: expected not ready before start, got %+v", r)
+	}
+
+	h.SetDatabaseLoaded()
+	h.SetSchedulerProbe(func() error { return nil })
+	if r := h.Readiness(); !r.OK {
+		t.Fatalf("Readiness failed: %+v", r)
+	}
+	if r := h.Liveness(); !r.OK {
+		t.Fatalf("Liveness failed: %+v", r)
+	}
+
+	h.SyncFailed(errors.New("disk is full"))
+	if r := h.Readiness(); r.OK || r.Checks["last_sync"].OK || !r.Checks["sync_failures"].OK {
+		t.Fatalf("Readiness failed: expected failed last sync, got %+v", r)
+	}
+	h.SyncFailed(errors.New("disk is full"))
+	if r := h.Readiness(); r.Checks["sync_failures"].OK {
+		t.Fatalf("Readiness failed: expected too many failures, got %+v", r)
+	}
+
+	h.SyncSucceeded()
+	if r := h.Readiness(); !r.OK {
+		t.Fatalf("Readiness failed: %+v", r)
+	}
+
+	h.SetSchedulerProbe(func() error {
+		time.Sleep(probeTimeout + time.Second)
+		return nil
+	})
+	h.lastSyncTick.Store(time.Now().Add(-time.Minute).UnixNano())
+	if r := h.Liveness(); r.OK || r.Checks["scheduler"].OK || r.Checks["sync_loop"].OK {
+		t.Fatalf("Liveness failed: expected stuck program, got %+v", r)
+	}
+}
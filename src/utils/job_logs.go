@@ -0,0 +1,270 @@
+package utils
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// NOTE: Log files of jobs, one file per job in the directory.
+// Files of recently logging jobs are kept open, the least recently
+// used ones are closed, so the number of jobs is not limited by the
+// number of open files. Writes of a job are serialized by its file
+
+// Number of log files of jobs kept open
+const maxOpenJobLogs = 64
+
+type JobLogs struct {
+	mu     sync.Mutex
+	dir    string
+	opts   RotateOptions
+	format string
+	level  slog.Leveler
+
+	// Open files, the most recently used first
+	files map[string]*list.Element
+	lru   *list.List
+}
+
+type jobLogFile struct {
+	name string
+	rf   *RotatingFile
+	// Writes in progress, the file is not closed while they go
+	refs int
+}
+
+func NewJobLogs(
+	dir string,
+	opts RotateOptions,
+	format string,
+	level slog.Leveler,
+) (*JobLogs, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &JobLogs{
+		dir:    dir,
+		opts:   opts,
+		format: format,
+		level:  level,
+		files:  map[string]*list.Element{},
+		lru:    list.New(),
+	}, nil
+}
+
+// Close closes open log files
+
+func (jl *JobLogs) Close() error {
+	jl.mu.Lock()
+	defer jl.mu.Unlock()
+
+	var errs []error
+	for e := jl.lru.Front(); e != nil; e = e.Next() {
+		errs = append(errs, e.Value.(*jobLogFile).rf.Close())
+	}
+	jl.files = map[string]*list.Element{}
+	jl.lru.Init()
+	return errors.Join(errs...)
+}
+
+// Path returns the log file of the job, the name is escaped
+// so any job name is a single file in the directory
+
+func (jl *JobLogs) Path(name string) string {
+	return filepath.Join(jl.dir, url.PathEscape(name)+".log")
+}
+
+// Logger returns the logger which writes to the log file of the job
+
+func (jl *JobLogs) Logger(name string) *slog.Logger {
+	w := &jobLogWriter{jl: jl, name: name}
+	return slog.New(NewLogHandler(w, jl.format, jl.level))
+}
+
+type jobLogWriter struct {
+	jl   *JobLogs
+	name string
+}
+
+func (w *jobLogWriter) Write(p []byte) (int, error) {
+	f, err := w.jl.acquire(w.name)
+	if err != nil {
+		return 0, err
+	}
+	defer w.jl.release(f)
+
+	return f.rf.Write(p)
+}
+
+// acquire returns the open log file of the job, release
+// must be called after the write
+
+func (jl *JobLogs) acquire(name string) (*jobLogFile, error) {
+	jl.mu.Lock()
+	defer jl.mu.Unlock()
+
+	if e, exists := jl.files[name]; exists {
+		jl.lru.MoveToFront(e)
+		f := e.Value.(*jobLogFile)
+		f.refs++
+		return f, nil
+	}
+
+	rf, err := OpenRotatingFile(jl.Path(name), jl.opts)
+	if err != nil {
+		return nil, err
+	}
+	f := &jobLogFile{name: name, rf: rf, refs: 1}
+	jl.files[name] = jl.lru.PushFront(f)
+
+	// Files with writes in progress are closed later
+	for e := jl.lru.Back(); e != nil && jl.lru.Len() > maxOpenJobLogs; {
+		prev := e.Prev()
+		if old := e.Value.(*jobLogFile); old.refs == 0 {
+			_ = old.rf.Close()
+			delete(jl.files, old.name)
+			jl.lru.Remove(e)
+		}
+		e = prev
+	}
+	return f, nil
+}
+
+func (jl *JobLogs) release(f *jobLogFile) {
+	jl.mu.Lock()
+	defer jl.mu.Unlock()
+	f.refs--
+}
+
+// Tail returns the last n lines of the log of the job (the whole
+// log if n is 0) and the size of the log file
+
+func (jl *JobLogs) Tail(name string, n int) ([]byte, int64, error) {
+	f, size, err := jl.open(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = f.Close() }()
+
+	if n <= 0 {
+		data, err := io.ReadAll(f)
+		return data, size, err
+	}
+
+	data, err := tailLines(f, size, n)
+	return data, size, err
+}
+
+// ReadFrom returns the log of the job from the offset and the size
+// of the log file. The log is read from the start if the offset is
+// beyond the size (the file was rotated)
+
+func (jl *JobLogs) ReadFrom(name string, offset int64) ([]byte, int64, error) {
+	f, size, err := jl.open(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = f.Close() }()
+
+	if offset > size {
+		offset = 0
+	}
+	data := make([]byte, size-offset)
+	if _, err := f.ReadAt(data, offset); err != nil && !errors.Is(err, io.EOF) {
+		return nil, 0, err
+	}
+	return data, size, nil
+}
+
+func (jl *JobLogs) open(name string) (*os.File, int64, error) {
+	f, err := os.Open(jl.Path(name))
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, 0, err
+	}
+	return f, info.Size(), nil
+}
+
+func tailLines(f *os.File, size int64, n int) ([]byte, error) {
+	const chunkSize = 64 * 1024
+
+	// Read chunks from the end until there are n full lines
+	var data []byte
+	offset := size
+	for offset > 0 && bytes.Count(data, []byte{'\n'}) <= n {
+		step := min(chunkSize, offset)
+		offset -= step
+		chunk := make([]byte, step)
+		if _, err := f.ReadAt(chunk, offset); err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		data = append(chunk, data...)
+	}
+
+	end := len(data)
+	if end > 0 && data[end-1] == '\n' {
+		end--
+	}
+	i := end
+	for range n {
+		if i = bytes.LastIndexByte(data[:i], '\n'); i < 0 {
+			return data, nil
+		}
+	}
+	return data[i+1:], nil
+}
+
+// NOTE: Handler which writes records to several handlers
+
+type multiHandler []slog.Handler
+
+func NewMultiHandler(handlers ...slog.Handler) slog.Handler {
+	return multiHandler(handlers)
+}
+
+func (mh multiHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range mh {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (mh multiHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range mh {
+		if h.Enabled(ctx, r.Level) {
+			errs = append(errs, h.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (mh multiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(multiHandler, len(mh))
+	for i, h := range mh {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (mh multiHandler) WithGroup(name string) slog.Handler {
+	handlers := make(multiHandler, len(mh))
+	for i, h := range mh {
+		handlers[i] = h.WithGroup(name)
+	}
+	return handlers
+}
@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestJobLogs(t *testing.T) {
+	dir := t.TempDir()
+	jl, err := NewJobLogs(dir, RotateOptions{}, LogFormatText, slog.LevelInfo)
+	if err != nil {
+		t.Fatalf("NewJobLogs failed: %v", err)
+	}
+
+	if path := jl.Path("../a/b"); filepath.Dir(path) != dir {
+		t.Fatalf("Path failed: %s is outside of %s", path, dir)
+	}
+
+	logger := jl.Logger("backup/db")
+	for _, msg := range []string{"one", "two", "three"} {
+		logger.Info(msg)
+	}
+	logger.Debug("hidden")
+
+	data, size, err := jl.Tail("backup/db", 2)
+	if err != nil {
+		t.Fatalf("Tail failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "msg=two") || !strings.Contains(lines[1], "msg=three") {
+		t.Fatalf("Tail failed: unexpected lines %q", lines)
+	}
+
+	logger.Info("four")
+	data, next, err := jl.ReadFrom("backup/db", size)
+	if err != nil {
+		t.Fatalf("ReadFrom failed: %v", err)
+	}
+	if !strings.Contains(string(data), "msg=four") || strings.Contains(string(data), "msg=three") {
+		t.Fatalf("ReadFrom failed: unexpected data %q", data)
+	}
+
+	// The offset after the rotation is reset
+	if data, _, _ := jl.ReadFrom("backup/db", next+100); !strings.Contains(string(data), "msg=one") {
+		t.Fatalf("ReadFrom failed: expected the whole log, got %q", data)
+	}
+
+	if _, _, err := jl.Tail("missing", 0); !os.IsNotExist(err) {
+		t.Fatalf("Tail failed: expected not exist error, got %v", err)
+	}
+}
+
+func TestJobLogsOpenFiles(t *testing.T) {
+	jl, err := NewJobLogs(t.TempDir(), RotateOptions{}, LogFormatText, slog.LevelInfo)
+	if err != nil {
+		t.Fatalf("NewJobLogs failed: %v", err)
+	}
+
+	jobs := maxOpenJobLogs + 10
+	var wg sync.WaitGroup
+	for i := range jobs {
+		wg.Go(func() {
+			logger := jl.Logger(fmt.Sprintf("job%d", i))
+			for j := range 20 {
+				logger.Info("line", "n", j)
+			}
+		})
+	}
+	wg.Wait()
+
+	if n := jl.lru.Len(); n > maxOpenJobLogs || n != len(jl.files) {
+		t.Errorf("Expected at most %d open files, got %d (%d in the map)", maxOpenJobLogs, n, len(jl.files))
+	}
+	for i := range jobs {
+		data, _, err := jl.Tail(fmt.Sprintf("job%d", i), 0)
+		if err != nil {
+			t.Fatalf("Tail failed: %v", err)
+		}
+		if lines := strings.Count(string(data), "\n"); lines != 20 {
+			t.Errorf("job%d: expected 20 lines, got %d", i, lines)
+		}
+	}
+
+	if err := jl.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	if len(jl.files) != 0 {
+		t.Error("Files are open after Close")
+	}
+}
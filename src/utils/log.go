@@ -4,7 +4,6 @@ import (
 	"context"
 	"io"
 	"log/slog"
-	"os"
 	"sync"
 )
 
@@ -77,6 +76,24 @@ func (bh *SlogBufferedHandler) GetLastRecords(n int) []slog.Record {
 	return bh.buffer[start:]
 }
 
+// Log handlers
+
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// NewLogHandler returns the handler which writes records of
+// the level and above in the format (text or json)
+
+func NewLogHandler(w io.Writer, format string, level slog.Leveler) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level}
+	if format == LogFormatJSON {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
+
 // Some recipes
 
 func MaybeLogger(logger *slog.Logger, enabled bool) *slog.Logger {
@@ -85,14 +102,3 @@ func MaybeLogger(logger *slog.Logger, enabled bool) *slog.Logger {
 	}
 	return logger
 }
-
-func OpenLogFile(path string, maxSizeBytes int64) (*os.File, error) {
-	if info, err := os.Stat(path); err == nil {
-		if info.Size() >= maxSizeBytes {
-			if err := os.WriteFile(path, nil, 0o644); err != nil {
-				return nil, err
-			}
-		}
-	}
-	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
-}
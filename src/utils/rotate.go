@@ -0,0 +1,271 @@
+package utils
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NOTE: Log file with rotation. Rotated generations are
+// compressed: file.1.gz (the newest), file.2.gz, ...
+
+type RotateOptions struct {
+	// Rotate when the file reaches the size. Disable - 0 value
+	MaxSize int64
+	// Number of kept generations, 0 - the rotated file is deleted
+	MaxBackups int
+	// Rotate on the first write of a new day (local time)
+	Daily bool
+}
+
+type RotatingFile struct {
+	mu   sync.Mutex
+	path string
+	opts RotateOptions
+
+	file *os.File
+	size int64
+	// Local date of the file content, for daily rotation
+	day string
+	// A failed rotation or reopen is not repeated on every write
+	retryAt time.Time
+	// The content is in the 1st generation, but the file is not
+	// truncated yet, since it was not reopened after the rotation
+	rotated bool
+	closed  bool
+}
+
+const rotateRetryInterval = time.Minute
+
+func OpenRotatingFile(path string, opts RotateOptions) (*RotatingFile, error) {
+	rf := &RotatingFile{path: path, opts: opts}
+	if err := rf.open(false); err != nil {
+		return nil, err
+	}
+
+	// Rotate what is left from the previous start
+	if rf.needsRotation(0, time.Now()) {
+		if err := rf.rotate(); err != nil {
+			if rf.file != nil {
+				_ = rf.file.Close()
+			}
+			return nil, err
+		}
+	}
+
+	return rf, nil
+}
+
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.closed {
+		return 0, os.ErrClosed
+	}
+
+	now := time.Now()
+	if rf.file == nil {
+		// The file was not reopened after a rotation
+		if now.Before(rf.retryAt) {
+			return 0, os.ErrClosed
+		}
+		if err := rf.open(rf.rotated); err != nil {
+			rf.retryAt = now.Add(rotateRetryInterval)
+			return 0, err
+		}
+	}
+
+	if now.After(rf.retryAt) && rf.needsRotation(int64(len(p)), now) {
+		// The record is written to the current file
+		// if the rotation failed
+		if err := rf.rotate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Log file rotation failed: %v\n", err)
+			rf.retryAt = now.Add(rotateRetryInterval)
+			if rf.file == nil {
+				return 0, err
+			}
+		}
+	}
+
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+func (rf *RotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.closed {
+		return os.ErrClosed
+	}
+	rf.closed = true
+	if rf.file == nil {
+		return nil
+	}
+	err := rf.file.Close()
+	rf.file = nil
+	return err
+}
+
+// Rotate rotates the file now
+
+func (rf *RotatingFile) Rotate() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.closed || rf.file == nil {
+		return os.ErrClosed
+	}
+	return rf.rotate()
+}
+
+// open opens the file for appends, truncate empties it after the
+// content is compressed by a rotation
+
+func (rf *RotatingFile) open(truncate bool) error {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if truncate {
+		flags |= os.O_TRUNC
+	}
+	file, err := os.OpenFile(rf.path, flags, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	rf.file = file
+	rf.rotated = false
+	rf.size = info.Size()
+	rf.day = info.ModTime().Format(time.DateOnly)
+	if rf.size == 0 {
+		rf.day = time.Now().Format(time.DateOnly)
+	}
+	return nil
+}
+
+func (rf *RotatingFile) needsRotation(writeSize int64, now time.Time) bool {
+	if rf.size == 0 {
+		return false
+	}
+	if rf.opts.Daily && now.Format(time.DateOnly) != rf.day {
+		return true
+	}
+	return rf.opts.MaxSize > 0 && rf.size+writeSize > rf.opts.MaxSize
+}
+
+// rotate shifts generations, compresses the current file to the
+// 1st generation and truncates it. If it fails, writes continue to
+// the current file. If the file is not reopened, Write retries it
+
+func (rf *RotatingFile) rotate() error {
+	err := rf.file.Close()
+	rf.file = nil
+	if err == nil {
+		err = rf.shiftGenerations()
+		rf.rotated = err == nil
+	}
+	if openErr := rf.open(rf.rotated); err == nil {
+		err = openErr
+	}
+	return err
+}
+
+func (rf *RotatingFile) shiftGenerations() error {
+	if rf.opts.MaxBackups > 0 {
+		for i := rf.opts.MaxBackups - 1; i > 0; i-- {
+			err := os.Rename(rf.generation(i), rf.generation(i+1))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if err := compressFile(rf.path, rf.generation(1)); err != nil {
+			return err
+		}
+	}
+	rf.removeExtraGenerations()
+	return nil
+}
+
+// removeExtraGenerations deletes generations left
+// after the number of kept ones was decreased
+
+func (rf *RotatingFile) removeExtraGenerations() {
+	files, err := RotatedFiles(rf.path)
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		var i int
+		suffix := strings.TrimPrefix(filepath.Base(file), filepath.Base(rf.path))
+		_, err := fmt.Sscanf(suffix, ".%d.gz", &i)
+		if err == nil && i > rf.opts.MaxBackups {
+			_ = os.Remove(file)
+		}
+	}
+}
+
+func (rf *RotatingFile) generation(i int) string {
+	return fmt.Sprintf("%s.%d.gz", rf.path, i)
+}
+
+func compressFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, in)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, dst)
+}
+
+// RotatedFiles returns compressed generations of the log file
+
+func RotatedFiles(path string) ([]string, error) {
+	return filepath.Glob(filepath.Join(
+		filepath.Dir(path),
+		glob(filepath.Base(path))+".*.gz",
+	))
+}
+
+// glob escapes special characters of the pattern
+func glob(s string) string {
+	var escaped []rune
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', '\\':
+			escaped = append(escaped, '\\')
+		}
+		escaped = append(escaped, r)
+	}
+	return string(escaped)
+}
@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readGzip(t *testing.T, path string) string {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("gzip.NewReader failed: %v", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	return string(data)
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+
+	rf, err := OpenRotatingFile(path, RotateOptions{MaxSize: 10, MaxBackups: 2})
+	if err != nil {
+		t.Fatalf("OpenRotatingFile failed: %v", err)
+	}
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := rf.Write([]byte(line)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := rf.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if data, _ := os.ReadFile(path); string(data) != "fourth\n" {
+		t.Errorf("Expected the last line in the file, got %q", data)
+	}
+	if got := readGzip(t, path+".1.gz"); got != "third\n" {
+		t.Errorf("Expected third line in generation 1, got %q", got)
+	}
+	if got := readGzip(t, path+".2.gz"); got != "second\n" {
+		t.Errorf("Expected second line in generation 2, got %q", got)
+	}
+	if files, _ := RotatedFiles(path); len(files) != 2 {
+		t.Errorf("Expected 2 generations, got %v", files)
+	}
+
+	// Fewer generations and the file of yesterday
+	// on the next start
+	yesterday := time.Now().Add(-24 * time.Hour)
+	if err := os.Chtimes(path, yesterday, yesterday); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+	rf, err = OpenRotatingFile(path, RotateOptions{MaxBackups: 1, Daily: true})
+	if err != nil {
+		t.Fatalf("OpenRotatingFile failed: %v", err)
+	}
+	_ = rf.Close()
+
+	if got := readGzip(t, path+".1.gz"); got != "fourth\n" {
+		t.Errorf("Expected rotation of the old file on start, got %q", got)
+	}
+	if files, _ := RotatedFiles(path); len(files) != 1 || !strings.HasSuffix(files[0], ".1.gz") {
+		t.Errorf("Expected 1 generation, got %v", files)
+	}
+}
+
+func TestRotatingFileReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+
+	rf, err := OpenRotatingFile(path, RotateOptions{MaxSize: 100, MaxBackups: 1})
+	if err != nil {
+		t.Fatalf("OpenRotatingFile failed: %v", err)
+	}
+	defer func() { _ = rf.Close() }()
+	if _, err := rf.Write([]byte("compressed\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	// The content is compressed, but the file was not reopened
+	_ = rf.file.Close()
+	rf.file = nil
+	rf.rotated = true
+	rf.retryAt = time.Now().Add(time.Minute)
+
+	if _, err := rf.Write([]byte("lost\n")); err == nil {
+		t.Error("Expected error before the retry time")
+	}
+
+	rf.retryAt = time.Now().Add(-time.Second)
+	if _, err := rf.Write([]byte("next\n")); err != nil {
+		t.Fatalf("Write after the retry time failed: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "next\n" {
+		t.Errorf("Expected the reopened file to be truncated, got %q", data)
+	}
+
+	if err := rf.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := rf.Write([]byte("closed\n")); err == nil {
+		t.Error("Expected error of a write after Close")
+	}
+}